@@ -5,12 +5,14 @@ import (
 	"errors"
 	"github.com/snipa22/monerocnutils/base58"
 	"github.com/snipa22/monerocnutils/crypto"
+	"github.com/snipa22/monerocnutils/serialization"
 )
 
 const ChecksumSize = 4
 
-var Tag byte
-var Tags []byte
+// Tag is the varint prefix addresses are encoded with, and Tags the prefixes of each AddressType.
+var Tag uint64
+var Tags []uint64
 
 type Coin int
 type Network int
@@ -32,22 +34,18 @@ const (
 	Subaddress AddressType = 2
 )
 
-func SetValidTags(c Coin, n Network) {
-	switch c {
-	case Monero:
-		switch n {
-		case Mainnet:
-			Tags = []byte{byte(0x12), byte(0x13), byte(0x2A)}
-			break
-		case Stagenet:
-			Tags = []byte{byte(0x18), byte(0x19), byte(0x24)}
-			break
-		case Testnet:
-			Tags = []byte{byte(0x35), byte(0x36), byte(0x3F)}
-			break
-		}
-		break
+// SetValidTags sets the address tags of a registered coin's network, see RegisterCoin.
+func SetValidTags(c Coin, n Network) error {
+	p, err := LookupCoin(c)
+	if err != nil {
+		return err
+	}
+	prefixes, ok := p.Prefixes[n]
+	if !ok {
+		return UnknownNetwork
 	}
+	Tags = []uint64{prefixes.Standard, prefixes.Integrated, prefixes.Subaddress}
+	return nil
 }
 
 func SetActiveTag(a AddressType) error {
@@ -83,35 +81,23 @@ type Address struct {
 }
 
 func (a *Address) MarshalBinary() (data []byte, err error) {
-	// make this long enough to hold a full hash on the end
-	data = make([]byte, 112)
+	// make this long enough to hold a ten byte tag and a full hash on the end
+	data = make([]byte, 0, 114)
 	// copy tag
-	n := 1
-	data[0] = Tag
+	data = serialization.WriteUint(data, Tag)
 
 	//copy keys
-	copy(data[n:], a.spend[:])
+	data = append(data, a.spend[:]...)
+	data = append(data, a.view[:]...)
 	if Tag == Tags[Integrated] {
-		copy(data[n+32:n+64], a.view[:])
-		copy(data[n+64:], a.paymentID[:])
-	} else {
-		copy(data[n+32:], a.view[:])
+		data = append(data, a.paymentID[:]...)
 	}
 
-	// checksum
+	// checksum, hashed straight to the slice
+	n := len(data)
 	hash := crypto.NewHash()
-	if Tag == Tags[Integrated] {
-		hash.Write(data[:n+72])
-		// hash straight to the slice
-		hash.Sum(data[:n+72])
-		return data[:n+76], nil
-	} else {
-		hash.Write(data[:n+64])
-		// hash straight to the slice
-		hash.Sum(data[:n+64])
-		return data[:n+68], nil
-	}
-
+	hash.Write(data)
+	return hash.Sum(data)[:n+ChecksumSize], nil
 }
 
 func (a *Address) UnmarshalBinary(data []byte) error {
@@ -130,12 +116,11 @@ func (a *Address) UnmarshalBinary(data []byte) error {
 	}
 
 	// check address prefix
-	if data[0] != Tag {
+	tag, data, err := serialization.ReadUint(data)
+	if err != nil || tag != Tag {
 		return InvalidAddressTag
 	}
 
-	data = data[1:]
-
 	if len(data) == 64 {
 		copy(a.spend[:], data[0:32])
 		copy(a.view[:], data[32:64])
@@ -1,6 +1,7 @@
 package monerocnutils
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -52,3 +53,42 @@ func TestDecodeEncodeAddressSubaddress(t *testing.T) {
 		t.Errorf("Decoding and encoding failed,\nwanted %s,\ngot    %s", subaddress, addr)
 	}
 }
+
+func TestMultiByteAddressTags(t *testing.T) {
+	const aeon Coin = 1003
+	RegisterCoin(aeon, CoinParams{Name: "aeon", Prefixes: map[Network]AddressPrefixes{
+		Mainnet: {Standard: 0xb2, Integrated: 0x06cd, Subaddress: 0x400b},
+	}})
+	defer SetValidTags(Monero, Mainnet)
+
+	SetValidTags(Monero, Mainnet)
+	SetActiveTag(Normal)
+	addr, err := DecodeAddress(normal)
+	if err != nil {
+		t.Fatal("Error decoding address,", err)
+	}
+
+	if err = SetValidTags(aeon, Mainnet); err != nil {
+		t.Fatal("Error setting tags,", err)
+	}
+	SetActiveTag(Normal)
+	data, _ := addr.MarshalBinary()
+	if len(data) != 70 || data[0] != 0xb2 || data[1] != 0x01 {
+		t.Fatalf("Expected a two byte varint prefix, got %x", data)
+	}
+	// Aeon addresses start with Wm
+	encoded := addr.String()
+	if !strings.HasPrefix(encoded, "Wm") {
+		t.Fatalf("Expected an Aeon address, got %s", encoded)
+	}
+	back, err := DecodeAddress(encoded)
+	if err != nil || back.String() != encoded {
+		t.Fatalf("Expected %s back, got %v, %v", encoded, back, err)
+	}
+
+	SetValidTags(Monero, Mainnet)
+	SetActiveTag(Normal)
+	if _, err = DecodeAddress(encoded); err != InvalidAddressTag {
+		t.Fatalf("Expected InvalidAddressTag for a Monero address, got %v", err)
+	}
+}
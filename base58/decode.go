@@ -2,12 +2,12 @@ package base58
 
 import (
 	"bytes"
-	"encoding/binary"
 	"fmt"
 	"io"
 	"math/big"
 
 	"github.com/snipa22/monerocnutils/crypto"
+	"github.com/snipa22/monerocnutils/serialization"
 )
 
 func decodeBlock(dst, src []byte) (int, error) {
@@ -54,10 +54,10 @@ func decodeAddr(s string) (tag uint64, data []byte) {
 		return
 	}
 
-	var n int
-	tag, n = binary.Uvarint(b)
-	data = b[n:]
-
+	tag, data, err = serialization.ReadUint(b)
+	if err != nil {
+		return 0, nil
+	}
 	return
 }
 
@@ -0,0 +1,153 @@
+package monerocnutils
+
+import (
+	"errors"
+	"github.com/snipa22/monerocnutils/crypto"
+	"github.com/snipa22/monerocnutils/serialization"
+)
+
+// Other CryptoNote coins lay their blocks out differently from Monero, so pools pick the parser and hashing blob to
+// use by the coin's blob type.  Original: convert_blob and construct_block_blob in node-cryptonote-util
+
+// BlobType is a block layout.
+type BlobType int
+
+const (
+	BlobTypeCryptonote BlobType = iota // Monero, and forks that kept its layout such as Aeon
+	BlobTypeForknote                   // Bytecoin and its forks such as TurtleCoin, with parent blocks from version 2
+	BlobTypeWownero                    // Monero's layout with a miner signature and vote in the header
+	BlobTypeOxen                       // Loki and Oxen, with service node fields in transactions and pulse blocks
+)
+
+var (
+	UnknownBlobType       = errors.New("unknown blob type")
+	InvalidParentBlock    = serialization.InvalidParentBlock
+	MergeMiningTagTooDeep = serialization.MergeMiningTagTooDeep
+)
+
+// CoinBlobType returns the blob type of coin's blocks.
+func CoinBlobType(c Coin) (BlobType, error) {
+	p, err := LookupCoin(c)
+	return p.BlobType, err
+}
+
+// HashingBlob returns the hashing blob of a block blob of type t, the blob its proof of work is computed on.
+func (t BlobType) HashingBlob(blob []byte) ([]byte, error) {
+	switch t {
+	case BlobTypeCryptonote:
+		b, err := ParseBlock(blob)
+		if err != nil {
+			return nil, err
+		}
+		return GetBlockHashingBlob(b)
+	case BlobTypeForknote:
+		b, err := ParseForknoteBlock(blob)
+		if err != nil {
+			return nil, err
+		}
+		return GetForknoteHashingBlob(b)
+	case BlobTypeWownero:
+		b, err := ParseWowneroBlock(blob)
+		if err != nil {
+			return nil, err
+		}
+		return GetWowneroHashingBlob(b)
+	case BlobTypeOxen:
+		b, err := ParseOxenBlock(blob)
+		if err != nil {
+			return nil, err
+		}
+		return GetOxenHashingBlob(b)
+	}
+	return nil, UnknownBlobType
+}
+
+// NonceOffset returns the offset of the nonce in a block blob of type t.  Hashing blobs of every type start with a
+// Monero block header, use NonceOffset for those.
+func (t BlobType) NonceOffset(blob []byte) (int, error) {
+	switch t {
+	case BlobTypeCryptonote, BlobTypeWownero, BlobTypeOxen:
+		return NonceOffset(blob)
+	case BlobTypeForknote:
+		r := serialization.NewReader(blob)
+		major, err := r.ReadVarint()
+		if err != nil {
+			return 0, archiveError(err)
+		}
+		if major < serialization.ForknoteParentBlockVersion {
+			return NonceOffset(blob)
+		}
+		// The minor version and previous id, then the parent block starts with a full header
+		if _, err = r.ReadVarint(); err != nil {
+			return 0, archiveError(err)
+		}
+		if _, err = r.ReadBlob(32); err != nil {
+			return 0, archiveError(err)
+		}
+		parentOffset := r.Offset()
+		offset, err := NonceOffset(blob[parentOffset:])
+		return parentOffset + offset, err
+	}
+	return 0, UnknownBlobType
+}
+
+// SetNonce writes nonce into a block blob of type t in place, building the block to submit.
+func (t BlobType) SetNonce(blob []byte, nonce [NonceSize]byte) error {
+	offset, err := t.NonceOffset(blob)
+	if err != nil {
+		return err
+	}
+	copy(blob[offset:offset+NonceSize], nonce[:])
+	return nil
+}
+
+// ParseForknoteBlock parses a Bytecoin style block blob.
+func ParseForknoteBlock(blob []byte) (serialization.ForknoteBlock, error) {
+	var b serialization.ForknoteBlock
+	err := b.ReadArchive(serialization.NewReader(blob))
+	return b, archiveError(err)
+}
+
+// GetForknoteHashingBlob returns the hashing blob of b.  From version 2 that's the parent block's, its header, merkle
+// root and transaction count.  Original: get_parent_block_hashing_blob in cryptonote_format_utils.cpp
+func GetForknoteHashingBlob(b serialization.ForknoteBlock) ([]byte, error) {
+	if b.MajorVersion < serialization.ForknoteParentBlockVersion {
+		return GetBlockHashingBlob(serialization.Block{BlockHeader: b.BlockHeader, MinerTxn: b.MinerTxn, TxnHashes: b.TxnHashes})
+	}
+	pb := b.Parent
+	root := crypto.TreeHashFromBranch(pb.MinerTxnBranch, getTransactionHash(pb.MinerTxn), nil)
+	blob := pb.SerializeHeader()
+	blob = append(blob, root[:]...)
+	return serialization.WriteUint(blob, pb.TxCount), nil
+}
+
+// ParseWowneroBlock parses a Wownero block blob.
+func ParseWowneroBlock(blob []byte) (serialization.WowneroBlock, error) {
+	var b serialization.WowneroBlock
+	err := b.ReadArchive(serialization.NewReader(blob))
+	return b, archiveError(err)
+}
+
+// GetWowneroHashingBlob returns the hashing blob of b, which has the signature and vote in its header.
+func GetWowneroHashingBlob(b serialization.WowneroBlock) ([]byte, error) {
+	blob := b.SerializeHeader()
+	root := getBlockMerkleTreeHash(b.Block)
+	blob = append(blob, root[:]...)
+	return serialization.WriteUint(blob, uint64(len(b.TxnHashes)+1)), nil
+}
+
+// ParseOxenBlock parses a Loki or Oxen block blob.
+func ParseOxenBlock(blob []byte) (serialization.OxenBlock, error) {
+	var b serialization.OxenBlock
+	err := b.ReadArchive(serialization.NewReader(blob))
+	return b, archiveError(err)
+}
+
+// GetOxenHashingBlob returns the hashing blob of b, which has the pulse header in its header.  Original:
+// get_block_hashing_blob in Oxen's cryptonote_format_utils.cpp
+func GetOxenHashingBlob(b serialization.OxenBlock) ([]byte, error) {
+	blob := b.SerializeHeader()
+	root := crypto.TreeHash(append([][32]byte{getOxenTransactionHash(b.MinerTxn)}, b.TxnHashes...))
+	blob = append(blob, root[:]...)
+	return serialization.WriteUint(blob, uint64(len(b.TxnHashes)+1)), nil
+}
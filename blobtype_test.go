@@ -0,0 +1,249 @@
+package monerocnutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/snipa22/monerocnutils/crypto"
+	"github.com/snipa22/monerocnutils/serialization"
+	"testing"
+)
+
+func TestCryptonoteBlobType(t *testing.T) {
+	blob, _ := hex.DecodeString(minerTXBlockTemplate2)
+	hashingBlob, err := BlobTypeCryptonote.HashingBlob(blob)
+	if err != nil {
+		t.Fatal("Error building the hashing blob,", err)
+	}
+	if fmt.Sprintf("%x", hashingBlob) != minerTXBlockTemplate2HashingBlob {
+		t.Fatal("Hashing blob doesn't match the daemon's")
+	}
+	if bt, err := CoinBlobType(Monero); err != nil || bt != BlobTypeCryptonote {
+		t.Fatalf("Expected Monero to be a cryptonote blob, got %v, %v", bt, err)
+	}
+	if _, err = BlobType(100).HashingBlob(blob); err != UnknownBlobType {
+		t.Fatalf("Expected UnknownBlobType, got %v", err)
+	}
+}
+
+// testForknoteBlock merge mines the miner transaction of a Monero template under a parent block with the given number
+// of transactions.
+func testForknoteBlock(t *testing.T, parentTxCount int) (serialization.ForknoteBlock, [][32]byte) {
+	b, err := ParseBlockFromTemplateBlob(minerTXBlockTemplate2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fb := serialization.ForknoteBlock{BlockHeader: b.BlockHeader, MinerTxn: b.MinerTxn, TxnHashes: b.TxnHashes}
+	fb.MajorVersion, fb.Timestamp, fb.Nonce = 2, 0, 0
+
+	chains := testAuxChains()
+	tag, err := AuxMerkleRoot(chains)
+	if err != nil {
+		t.Fatal(err)
+	}
+	parentTxn := b.MinerTxn
+	extra := parentTxn.ExtraFields()
+	extra.SetMergeMiningTag(tag)
+	parentTxn.Extra = extra.Serialize()
+
+	hashes := [][32]byte{getTransactionHash(parentTxn)}
+	for i := 1; i < parentTxCount; i++ {
+		hashes = append(hashes, crypto.KeccakOneShot([]byte{byte(i)}))
+	}
+	branch, _, _ := crypto.TreeBranch(hashes, 0)
+	auxBranch, _ := AuxBranch(chains, chains[0].ChainID)
+	fb.Parent = serialization.ParentBlock{
+		MajorVersion:     1,
+		Timestamp:        1600000000,
+		PreviousID:       [32]byte{9},
+		Nonce:            0x01020304,
+		TxCount:          uint64(parentTxCount),
+		MinerTxnBranch:   branch,
+		MinerTxn:         parentTxn,
+		BlockchainBranch: auxBranch,
+	}
+	return fb, hashes
+}
+
+func TestForknoteBlobType(t *testing.T) {
+	for _, count := range []int{1, 2, 3, 5, 8} {
+		fb, hashes := testForknoteBlock(t, count)
+		blob := fb.Serialize()
+
+		parsed, err := ParseForknoteBlock(blob)
+		if err != nil {
+			t.Fatal("Error parsing the block,", err)
+		}
+		if !bytes.Equal(parsed.Serialize(), blob) || parsed.Parent.Nonce != fb.Parent.Nonce {
+			t.Fatalf("Block with %d parent transactions didn't serialize back to itself", count)
+		}
+
+		hashingBlob, err := BlobTypeForknote.HashingBlob(blob)
+		if err != nil {
+			t.Fatal("Error building the hashing blob,", err)
+		}
+		root := crypto.TreeHash(hashes)
+		expected := append(fb.Parent.SerializeHeader(), root[:]...)
+		expected = serialization.WriteUint(expected, uint64(count))
+		if !bytes.Equal(hashingBlob, expected) {
+			t.Fatalf("Hashing blob with %d parent transactions isn't the parent's", count)
+		}
+
+		nonce := [NonceSize]byte{0xaa, 0xbb, 0xcc, 0xdd}
+		if err = BlobTypeForknote.SetNonce(blob, nonce); err != nil {
+			t.Fatal("Error setting the nonce,", err)
+		}
+		parsed, _ = ParseForknoteBlock(blob)
+		if parsed.Parent.Nonce != 0xddccbbaa {
+			t.Fatalf("Nonce wasn't written to the parent block, got %x", parsed.Parent.Nonce)
+		}
+		hashingBlob, _ = BlobTypeForknote.HashingBlob(blob)
+		if read, _ := BlobNonce(hashingBlob); read != nonce {
+			t.Fatal("Nonce doesn't show up in the hashing blob")
+		}
+	}
+
+	// Version 1 blocks are laid out as Monero's
+	b, _ := ParseBlockFromTemplateBlob(minerTXBlockTemplate2)
+	b.MajorVersion = 1
+	hashingBlob, err := BlobTypeForknote.HashingBlob(b.Serialize())
+	expected, _ := GetBlockHashingBlob(b)
+	if err != nil || !bytes.Equal(hashingBlob, expected) {
+		t.Fatal("Version 1 hashing blob doesn't match Monero's")
+	}
+}
+
+func TestForknoteParentErrors(t *testing.T) {
+	fb, _ := testForknoteBlock(t, 2)
+	fb.Parent.MinerTxn.Extra = nil
+	if _, err := ParseForknoteBlock(fb.Serialize()); err != MissingMergeMiningTag {
+		t.Fatalf("Expected MissingMergeMiningTag, got %v", err)
+	}
+	fb, _ = testForknoteBlock(t, 2)
+	blob := fb.Serialize()
+	if _, err := ParseForknoteBlock(blob[:len(blob)-40]); err != InvalidBlobLength {
+		t.Fatalf("Expected InvalidBlobLength, got %v", err)
+	}
+}
+
+func TestWowneroBlobType(t *testing.T) {
+	b, _ := ParseBlockFromTemplateBlob(minerTXBlockTemplate2)
+
+	// Before miner signatures a Wownero block is a Monero block
+	wb := serialization.WowneroBlock{Block: b}
+	hashingBlob, err := BlobTypeWownero.HashingBlob(wb.Serialize())
+	if err != nil || fmt.Sprintf("%x", hashingBlob) != minerTXBlockTemplate2HashingBlob {
+		t.Fatalf("Expected Monero's hashing blob, got %x, %v", hashingBlob, err)
+	}
+
+	wb.MajorVersion = serialization.WowneroMinerSignatureVersion
+	wb.Signature[0], wb.Signature[63], wb.Vote = 1, 2, 0x0102
+	blob := wb.Serialize()
+	parsed, err := ParseWowneroBlock(blob)
+	if err != nil {
+		t.Fatal("Error parsing the block,", err)
+	}
+	if !bytes.Equal(parsed.Serialize(), blob) || parsed.Signature != wb.Signature || parsed.Vote != wb.Vote {
+		t.Fatal("Block didn't serialize back to itself")
+	}
+	hashingBlob, _ = BlobTypeWownero.HashingBlob(blob)
+	if !bytes.HasPrefix(hashingBlob, wb.SerializeHeader()) || len(hashingBlob) != len(wb.SerializeHeader())+32+1 {
+		t.Fatal("Hashing blob doesn't carry the signature and vote")
+	}
+
+	nonce := [NonceSize]byte{1, 2, 3, 4}
+	if err = BlobTypeWownero.SetNonce(blob, nonce); err != nil {
+		t.Fatal("Error setting the nonce,", err)
+	}
+	if parsed, _ = ParseWowneroBlock(blob); parsed.Nonce != 0x04030201 || parsed.Signature != wb.Signature {
+		t.Fatal("Nonce wasn't written ahead of the signature")
+	}
+}
+
+func TestOxenBlobType(t *testing.T) {
+	b, _ := ParseBlockFromTemplateBlob(minerTXBlockTemplate2)
+
+	// Before version 3 transactions an Oxen block is a Monero block
+	ob := serialization.OxenBlock{BlockHeader: b.BlockHeader, MinerTxn: serialization.OxenTransaction{Transaction: b.MinerTxn}, TxnHashes: b.TxnHashes}
+	hashingBlob, err := BlobTypeOxen.HashingBlob(ob.Serialize())
+	if err != nil || fmt.Sprintf("%x", hashingBlob) != minerTXBlockTemplate2HashingBlob {
+		t.Fatalf("Expected Monero's hashing blob, got %x, %v", hashingBlob, err)
+	}
+
+	for _, version := range []uint64{serialization.OxenTxVersionOutputUnlockTimes, serialization.OxenTxVersionTypes} {
+		ob.MinerTxn.Version = version
+		ob.MinerTxn.OutputUnlockTimes = []uint64{ob.MinerTxn.UnlockTime}
+		ob.MinerTxn.Type = serialization.OxenTxTypeStateChange
+		prefix := ob.MinerTxn.SerializePrefix()
+		// The version, one unlock time and then the flag or, after the extra, the type
+		unlockTime := serialization.WriteUint(nil, ob.MinerTxn.UnlockTime)
+		if !bytes.HasPrefix(prefix, append([]byte{byte(version), 1}, unlockTime...)) {
+			t.Fatalf("Version %d: expected the unlock times after the version, got %x", version, prefix)
+		}
+		flag := prefix[2+len(unlockTime)]
+		if version == serialization.OxenTxVersionOutputUnlockTimes && flag != 1 || version == serialization.OxenTxVersionTypes && prefix[len(prefix)-1] != 1 {
+			t.Fatalf("Version %d: expected the state change flag or type, got %x", version, prefix)
+		}
+
+		blob := ob.Serialize()
+		parsed, err := ParseOxenBlock(blob)
+		if err != nil {
+			t.Fatalf("Version %d: error parsing the block, %v", version, err)
+		}
+		if !bytes.Equal(parsed.Serialize(), blob) || parsed.MinerTxn.Type != serialization.OxenTxTypeStateChange {
+			t.Fatalf("Version %d: block didn't serialize back to itself", version)
+		}
+		hashingBlob, _ = BlobTypeOxen.HashingBlob(blob)
+		root := crypto.TreeHash(append([][32]byte{getOxenTransactionHash(ob.MinerTxn)}, ob.TxnHashes...))
+		if !bytes.Contains(hashingBlob, root[:]) || getOxenTransactionHash(ob.MinerTxn) == getTransactionHash(ob.MinerTxn.Transaction) {
+			t.Fatalf("Version %d: hashing blob isn't built on the Oxen miner transaction hash", version)
+		}
+	}
+
+	// Pulse blocks carry the pulse header in the hashing blob, and the quorum's signatures after the transactions
+	ob.MajorVersion = serialization.OxenPulseVersion
+	ob.Pulse = serialization.OxenPulse{RandomValue: [16]byte{1, 15: 2}, Round: 3, ValidatorBitset: 0x0405}
+	ob.Signatures = []serialization.OxenQuorumSignature{{VoterIndex: 7, Signature: [64]byte{8}}, {VoterIndex: 9}}
+	blob := ob.Serialize()
+	parsed, err := ParseOxenBlock(blob)
+	if err != nil {
+		t.Fatal("Error parsing the pulse block,", err)
+	}
+	if !bytes.Equal(parsed.Serialize(), blob) || parsed.Pulse != ob.Pulse || len(parsed.Signatures) != 2 || parsed.Signatures[0] != ob.Signatures[0] {
+		t.Fatal("Pulse block didn't serialize back to itself")
+	}
+	header := ob.SerializeHeader()
+	if len(header) != len(ob.BlockHeader.Serialize())+16+1+2 {
+		t.Fatalf("Expected the pulse header after the nonce, got %x", header)
+	}
+	hashingBlob, _ = BlobTypeOxen.HashingBlob(blob)
+	if !bytes.HasPrefix(hashingBlob, header) || len(hashingBlob) != len(header)+32+1 {
+		t.Fatal("Hashing blob doesn't carry the pulse header")
+	}
+	nonce := [NonceSize]byte{1, 2, 3, 4}
+	if err = BlobTypeOxen.SetNonce(blob, nonce); err != nil {
+		t.Fatal("Error setting the nonce,", err)
+	}
+	if parsed, _ = ParseOxenBlock(blob); parsed.Nonce != 0x04030201 || parsed.Pulse != ob.Pulse {
+		t.Fatal("Nonce wasn't written ahead of the pulse header")
+	}
+}
+
+func TestOxenTransactionErrors(t *testing.T) {
+	b, _ := ParseBlockFromTemplateBlob(minerTXBlockTemplate2)
+	tx := serialization.OxenTransaction{Transaction: b.MinerTxn}
+	tx.Version = serialization.OxenTxVersionOutputUnlockTimes
+	tx.OutputUnlockTimes = []uint64{1, 2}
+	var parsed serialization.OxenTransaction
+	if err := serialization.Unmarshal(tx.Serialize(), &parsed); !errors.Is(err, serialization.OutputUnlockTimesMismatch) {
+		t.Fatalf("Expected OutputUnlockTimesMismatch, got %v", err)
+	}
+
+	tx.OutputUnlockTimes = []uint64{1}
+	blob := tx.Serialize()
+	blob[3] = 2
+	if err := serialization.Unmarshal(blob, &parsed); !errors.Is(err, serialization.InvalidStateChangeFlag) {
+		t.Fatalf("Expected InvalidStateChangeFlag, got %v", err)
+	}
+}
@@ -1,8 +1,8 @@
 package monerocnutils
 
 import (
-	"encoding/binary"
 	"encoding/hex"
+	"errors"
 	"github.com/snipa22/monerocnutils/crypto"
 	"github.com/snipa22/monerocnutils/serialization"
 )
@@ -12,151 +12,103 @@ import (
 // 1.1 parse_and_validate_block_from_blob -> Created a Block struct from a blob of data provided by the Get Block Template RPC call, that is then modified to suit usages
 // 1.2 get_block_hashing_blob -> Converts the blob into a block hashing blob
 
-func ParseBlockFromTemplateBlob(blob string) (serialization.Block, error) {
-	var b serialization.Block
-	blobInBytes, err := hex.DecodeString(blob)
-	if err != nil {
-		return b, err
-	}
-	// Get the Major Version, uint8
-	val, blobInBytes, err := serialization.ReadUint(blobInBytes)
-	if err != nil {
-		return b, err
-	}
-	b.MajorVersion = uint8(val)
-
-	// Get the Minor Version, uint8
-	val, blobInBytes, err = serialization.ReadUint(blobInBytes)
-	if err != nil {
-		return b, err
-	}
-	b.MinorVersion = uint8(val)
-
-	// Get the Timestamp, uint64
-	val, blobInBytes, err = serialization.ReadUint(blobInBytes)
-	if err != nil {
-		return b, err
-	}
-	b.Timestamp = val
-
-	// Get the previous hash, which is an array of 32 bytes in uint8 form, stored as 32 bytes in the array
-	bytesCopied := copy(b.PreviousID[:], blobInBytes[0:32])
-	blobInBytes = blobInBytes[bytesCopied:]
-
-	// Get the nonce, uint32, but is stored as a block of 4 bytes...  Jackassery.
-	b.Nonce = binary.BigEndian.Uint32(blobInBytes[0:4])
-	blobInBytes = blobInBytes[4:]
-
-	// Start Transaction Processing (Miner Transaction)
-	var t serialization.Transaction
+var (
+	InvalidBlobLength = serialization.InvalidBlobLength
+)
 
-	// Get Version, uint64
-	val, blobInBytes, err = serialization.ReadUint(blobInBytes)
-	if err != nil {
-		return b, err
+// archiveError returns the error wrapped in a serialization.ArchiveError, so that the errors this package returns can be
+// compared with ==.  Read the blob with the serialization package for the offset it failed at.
+func archiveError(err error) error {
+	if ae, ok := err.(*serialization.ArchiveError); ok {
+		return ae.Err
 	}
-	t.Version = val
+	return err
+}
 
-	// Get UnlockTime, uint64 -- Could be a timestamp OR a block ID
-	val, blobInBytes, err = serialization.ReadUint(blobInBytes)
+// ParseBlockFromTemplateBlob parses a hex encoded block, such as the blocktemplate_blob from get_block_template.
+func ParseBlockFromTemplateBlob(blob string) (serialization.Block, error) {
+	blobInBytes, err := hex.DecodeString(blob)
 	if err != nil {
-		return b, err
+		return serialization.Block{}, err
 	}
-	t.UnlockTime = val
-
-	// Start processing the t.vin fields
-	// These are the Variant In fields.
-
-	// Move forwards by 1 as the array is one object in length
-	blobInBytes = blobInBytes[1:]
-
-	// Move forwards by 1 as the key type for the array is going to be the TransactionInGenesis type
-	blobInBytes = blobInBytes[1:]
-
-	// Load the resulting blob data into the correct portion of Transaction/TransactionsIn
-	var tig serialization.TransactionInGenesis
-	var ti serialization.TransactionIn
+	return ParseBlock(blobInBytes)
+}
 
-	// Get the genesis height
-	val, blobInBytes, err = serialization.ReadUint(blobInBytes)
-	if err != nil {
-		return b, err
-	}
-	tig.Height = val
-	tig.Used = true
-	ti.Genesis = tig
+// ParseBlock parses a block blob.  The returned block's extra refers back into blob.
+func ParseBlock(blobInBytes []byte) (serialization.Block, error) {
+	var b serialization.Block
+	err := b.ReadArchive(serialization.NewReader(blobInBytes))
+	return b, archiveError(err)
+}
 
-	t.TransactionsIn = append(t.TransactionsIn, ti)
+func GetBlockHashingBlob(b serialization.Block) ([]byte, error) {
+	// Source: cryptonote_format_utils.cpp
+	// Original: get_block_hashing_blob(const block& b, blobdata& blob) - Line: 678-ish
+	var sbh []byte = b.BlockHeader.Serialize()
+	bmt := getBlockMerkleTreeHash(b)
+	sbh = append(sbh, bmt[:]...)
+	sbh = serialization.WriteUint(sbh, uint64(len(b.TxnHashes)+1))
+	return sbh, nil
+}
 
-	// Move forwards by 1 as the array is one object in length
-	blobInBytes = blobInBytes[1:]
+var (
+	InvalidBlockID = errors.New("block id for 202612 found with an incorrect block blob")
+)
 
-	// Load the resulting blob data into the correct portion of Transaction/TransactionsOut
-	var to serialization.TransactionOut
+// Block 202612 was mined with a hashing blob that collides with a different block id due to a tree hash bug, the
+// daemon hard codes the id it reported at the time.
+const (
+	correctBlobHash202612 = "3a8a2b3a29b50fc86ff73dd087ea43c6f0d6b8f936c849194d5c84c737903966"
+	existingBlockID202612 = "bbd604d2ba11ba27935e006ed39c9bfdd99b76bf4a50654bc1e1e61217962698"
+)
 
-	val, blobInBytes, err = serialization.ReadUint(blobInBytes)
+// BlockHash returns the block ID of b, as reported by the daemon.
+func BlockHash(b serialization.Block) ([32]byte, error) {
+	// Original: calculate_block_hash(const block& b, crypto::hash& res) - cryptonote_format_utils.cpp:1270-ish
+	var id [32]byte
+	blob, err := GetBlockHashingBlob(b)
 	if err != nil {
-		return b, err
+		return id, err
 	}
-	to.Amount = val
-
-	// Outbound type is to key, or 0x02.  Increment bytes by one
-	blobInBytes = blobInBytes[1:]
-
-	// Write a new TransactionOutToKey
-	var totk serialization.TransactionOutToKey
-	bytesCopied = copy(totk.PublicKey[:], blobInBytes[0:32])
-	blobInBytes = blobInBytes[bytesCopied:]
-	totk.Used = true
-	to.Key = totk
+	id = getObjectHash(blob)
 
-	t.TransactionsOut = append(t.TransactionsOut, to)
-
-	// Get the number of bytes to read into "extra"
-	val, blobInBytes, err = serialization.ReadUint(blobInBytes)
-	if err != nil {
-		return b, err
+	if len(b.MinerTxn.TransactionsIn) == 1 && b.MinerTxn.TransactionsIn[0].Genesis.Used {
+		if b.MinerTxn.TransactionsIn[0].Genesis.Height != 202612 {
+			return id, nil
+		}
 	}
 
-	// With val set to the # of bytes to read, slice and go
-	t.Extra = blobInBytes[0:val]
-	blobInBytes = blobInBytes[val:]
-
-	// RingCT Type is 0.  Advance one byte
-	blobInBytes = blobInBytes[1:]
-
-	// Miner Transaction Complete!  Load to the main storage.  AYE AYE CAPTAIN!
-	b.MinerTxn = t
+	return blockID202612(id, crypto.KeccakOneShot(b.Serialize()))
+}
 
-	// Get the number of hashes in the tx_hashes field
-	val, blobInBytes, err = serialization.ReadUint(blobInBytes)
-	if err != nil {
-		return b, err
+// blockID202612 applies the exception for block 202612 to id, the hash of a block's hashing blob, given blobHash, the
+// hash of the whole block blob.
+func blockID202612(id, blobHash [32]byte) ([32]byte, error) {
+	// EXCEPTION FOR BLOCK 202612
+	if hex.EncodeToString(blobHash[:]) == correctBlobHash202612 {
+		blockID, _ := hex.DecodeString(existingBlockID202612)
+		copy(id[:], blockID)
+		return id, nil
 	}
 
-	// Attempt to get <val> hashes and append to the main store
-	for ; val > 0; val-- {
-		var iSlice [32]byte
-		bytesCopied = copy(iSlice[:], blobInBytes[0:32])
-		b.TxnHashes = append(b.TxnHashes, iSlice)
-		blobInBytes = blobInBytes[bytesCopied:]
+	// Make sure that we aren't looking at a block with the 202612 block id but not the correct blob data
+	if hex.EncodeToString(id[:]) == existingBlockID202612 {
+		return [32]byte{}, InvalidBlockID
 	}
-
-	return b, nil
-}
-
-func GetBlockHashingBlob(b serialization.Block) ([]byte, error) {
-	// Source: cryptonote_format_utils.cpp
-	// Original: get_block_hashing_blob(const block& b, blobdata& blob) - Line: 678-ish
-	var sbh []byte = b.BlockHeader.Serialize()
-	bmt := getBlockMerkleTreeHash(b)
-	sbh = append(sbh, bmt[:]...)
-	sbh = serialization.WriteUint(sbh, uint64(len(b.TxnHashes)+1))
-	return sbh, nil
+	return id, nil
 }
 
 // Supporting hash systems
 
+func getObjectHash(blob []byte) [32]byte {
+	// Original: get_object_hash(const t_object& o, crypto::hash& res) - cryptonote_format_utils.h
+	// The object's blob is prefixed with its length as a varint before being hashed.
+	var s []byte
+	s = serialization.WriteUint(s, uint64(len(blob)))
+	s = append(s, blob...)
+	return crypto.KeccakOneShot(s)
+}
+
 func getBlockMerkleTreeHash(b serialization.Block) [32]byte {
 	// Original: get_tx_tree_hash(const block& b) - cryptonote_format_utils.cpp:875-ish
 
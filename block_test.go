@@ -1,6 +1,7 @@
 package monerocnutils
 
 import (
+	"encoding/hex"
 	"fmt"
 	"testing"
 )
@@ -77,3 +78,100 @@ func TestBlockBlobSerialization(t *testing.T) {
 		t.Fatal("Failed to properly convert block back into an identical original blob")
 	}
 }
+
+// Mainnet genesis block, nonce 10000 and the GENESIS_TX from cryptonote_config.h
+const genesisBlock = "010000000000000000000000000000000000000000000000000000000000000000000010270000013c01ff0001ffffffffffff03029b2e4c0281c0b02e7c53291a94d1d0cbff8883f8024f5142ee494ffbbd08807121017767aafcde9be00dcfd098715ebcf7f410daebc582fda69d24a28e9d0bc890d100"
+const genesisBlockID = "418015bb9ae982a1975da7d79277c2705727a56894ba0fb246adaabb1f4632e3"
+
+func TestBlockHash(t *testing.T) {
+	b, err := ParseBlockFromTemplateBlob(genesisBlock)
+	if err != nil {
+		t.Fatal("Error parsing genesis block,", err)
+	}
+	if fmt.Sprintf("%x", b.GetBlob()) != genesisBlock {
+		t.Fatal("Failed to properly convert the genesis block back into an identical original blob")
+	}
+	if b.Nonce != 10000 {
+		t.Fatalf("Expected the genesis nonce to be 10000, got %d", b.Nonce)
+	}
+	id, err := BlockHash(b)
+	if err != nil {
+		t.Fatal("Error hashing genesis block,", err)
+	}
+	if fmt.Sprintf("%x", id) != genesisBlockID {
+		t.Fatalf("Genesis block id mismatch, wanted %s, got %x", genesisBlockID, id)
+	}
+}
+
+// Mainnet block 2751506 and its id, from get_block
+const block2751506 = "1010c58bab9b06b27bdecfc6cd0a46172d136c08831cf67660377ba992332363228b1b722781e7807e07f502cef8a70101ff92f8a7010180e0a596bb1103d7cbf826b665d7a532c316982dc8dbc24f285cbc18bbcc27c7164cd9b3277a85d034019f629d8b36bd16a2bfce3ea80c31dc4d8762c67165aec21845494e32b7582fe00211000000297a787a000000000000000000000000"
+const block2751506ID = "43bd1f2b6556dcafa413d8372974af59e4e8f37dbf74dc6b2a9b7212d0577428"
+
+func TestBlockHashMainnet(t *testing.T) {
+	b, err := ParseBlockFromTemplateBlob(block2751506)
+	if err != nil {
+		t.Fatal("Error parsing block 2751506,", err)
+	}
+	if minerTxHash := getTransactionHash(b.MinerTxn); fmt.Sprintf("%x", minerTxHash) != "e49b854c5f339d7410a77f2a137281d8042a0ffc7ef9ab24cd670b67139b24cd" {
+		t.Fatalf("Miner transaction hash mismatch, got %x", minerTxHash)
+	}
+	id, err := BlockHash(b)
+	if err != nil || fmt.Sprintf("%x", id) != block2751506ID {
+		t.Fatalf("Block id mismatch, wanted %s, got %x, %v", block2751506ID, id, err)
+	}
+
+	// Any block at height 202612 other than the one the chain has goes through the check of the whole blob and keeps
+	// its computed id
+	b.MinerTxn.TransactionsIn[0].Genesis.Height = 202612
+	blob, _ := GetBlockHashingBlob(b)
+	if id, err = BlockHash(b); err != nil || id != getObjectHash(blob) || fmt.Sprintf("%x", id) == existingBlockID202612 {
+		t.Fatalf("Expected the computed id at height 202612, got %x, %v", id, err)
+	}
+}
+
+func TestBlockID202612(t *testing.T) {
+	// Block 202612's blob isn't at hand, so the exception is checked on the two hashes the daemon compares
+	var id, blobHash, existing [32]byte
+	hex.Decode(existing[:], []byte(existingBlockID202612))
+	hex.Decode(blobHash[:], []byte(correctBlobHash202612))
+	id[0] = 1
+	if got, err := blockID202612(id, blobHash); err != nil || got != existing {
+		t.Fatalf("Expected the existing id for the recorded blob, got %x, %v", got, err)
+	}
+	if got, err := blockID202612(existing, id); err != InvalidBlockID || got != [32]byte{} {
+		t.Fatalf("Expected %v for the existing id with another blob, got %x, %v", InvalidBlockID, got, err)
+	}
+	if got, err := blockID202612(id, id); err != nil || got != id {
+		t.Fatalf("Expected the computed id for any other block, got %x, %v", got, err)
+	}
+}
+
+func TestBlockBlobSerializationManyTransactions(t *testing.T) {
+	// Counts past 127 need a second varint byte, counts past 255 no longer fit in a single byte at all.
+	for _, count := range []int{128, 300} {
+		b, _ := ParseBlockFromTemplateBlob(onlyMinerBlockTemplate)
+		b.TxnHashes = nil
+		for i := 0; i < count; i++ {
+			var h [32]byte
+			h[0], h[1] = byte(i), byte(i>>8)
+			b.TxnHashes = append(b.TxnHashes, h)
+		}
+
+		blob := fmt.Sprintf("%x", b.GetBlob())
+		b2, err := ParseBlockFromTemplateBlob(blob)
+		if err != nil {
+			t.Fatalf("Failed to parse a block with %d transactions, %v", count, err)
+		}
+		if len(b2.TxnHashes) != count {
+			t.Fatalf("Block with %d transactions came back with %d", count, len(b2.TxnHashes))
+		}
+		for i := range b.TxnHashes {
+			if b.TxnHashes[i] != b2.TxnHashes[i] {
+				t.Fatalf("Transaction hash %d changed during a round trip of a block with %d transactions", i, count)
+			}
+		}
+		if fmt.Sprintf("%x", b2.GetBlob()) != blob {
+			t.Fatalf("Failed to properly convert a block with %d transactions back into an identical blob", count)
+		}
+	}
+}
@@ -0,0 +1,197 @@
+package monerocnutils
+
+import (
+	"errors"
+	"github.com/snipa22/monerocnutils/serialization"
+	"io"
+	"math/bits"
+	"sort"
+	"time"
+)
+
+// CoinbaseBlobReservedSize is the weight kept free for the miner transaction when choosing transactions.  Source:
+// cryptonote_config.h
+const CoinbaseBlobReservedSize = 600
+
+// coinbaseWeightRounds is how many times the reward is reworked for a changed miner transaction weight before giving up
+const coinbaseWeightRounds = 10
+
+var (
+	CoinbaseWeightUnstable = errors.New("miner transaction weight didn't settle")
+)
+
+// MempoolTransaction is a transaction offered for a block template, as listed by the daemon's get_transaction_pool.
+type MempoolTransaction struct {
+	ID     [32]byte
+	Weight uint64
+	Fee    uint64
+}
+
+// BlockTemplateParams describes a block template for BuildBlockTemplate.
+type BlockTemplateParams struct {
+	Height                uint64
+	MajorVersion          uint8
+	MinorVersion          uint8
+	PreviousID            [32]byte
+	Timestamp             uint64 // The current time if 0
+	AlreadyGeneratedCoins uint64
+	MedianWeight          uint64 // The effective median, see EffectiveMedianWeight
+	Address               *Address
+	ReserveSize           int // As reserve_size for get_block_template, PoolNonceSize for a PoolNonce
+	Mempool               []MempoolTransaction
+	Random                io.Reader // Source of the miner transaction key, crypto/rand if nil
+}
+
+// BlockTemplate is a locally built block template, with what get_block_template would have returned alongside it.
+type BlockTemplate struct {
+	Block          serialization.Block
+	Blob           []byte
+	HashingBlob    []byte
+	ReservedOffset int
+	ExpectedReward uint64 // Block reward plus fees
+	Fees           uint64
+	Weight         uint64
+	TxSecretKey    [32]byte // The miner transaction's secret key
+}
+
+// BuildBlockTemplate assembles a block template, filling it with the mempool transactions that pay the most per unit
+// of weight as long as they add more in fees than they cost in penalty.  Original: Blockchain::create_block_template
+// and tx_memory_pool::fill_block_template
+func BuildBlockTemplate(coin CoinParams, p BlockTemplateParams) (*BlockTemplate, error) {
+	bt := new(BlockTemplate)
+	medianWeight := p.MedianWeight
+	if fullRewardZone := MinBlockWeight(p.MajorVersion); medianWeight < fullRewardZone {
+		medianWeight = fullRewardZone
+	}
+
+	txs, txsWeight, fees := selectTransactions(coin, p.Mempool, medianWeight, p.AlreadyGeneratedCoins, p.MajorVersion)
+	bt.Fees = fees
+
+	// The miner transaction is first built with a reward for the transactions alone, then rebuilt for the weight of the
+	// whole block, which depends on the reward through the varint of its amount
+	reward, err := BlockReward(coin, medianWeight, txsWeight, p.AlreadyGeneratedCoins, p.MajorVersion)
+	if err != nil {
+		return nil, err
+	}
+	minerTxn, txSecret, err := BuildCoinbase(coin, CoinbaseParams{
+		Height:         p.Height,
+		Reward:         reward + fees,
+		Address:        p.Address,
+		MajorVersion:   p.MajorVersion,
+		ExtraNonceSize: p.ReserveSize,
+		Random:         p.Random,
+	})
+	if err != nil {
+		return nil, err
+	}
+	bt.TxSecretKey = txSecret
+	if reward, err = settleCoinbase(coin, &minerTxn, medianWeight, txsWeight, fees, p.AlreadyGeneratedCoins, p.MajorVersion); err != nil {
+		return nil, err
+	}
+	bt.ExpectedReward = reward + fees
+	bt.Weight = txsWeight + TransactionWeight(minerTxn)
+
+	timestamp := p.Timestamp
+	if timestamp == 0 {
+		timestamp = uint64(time.Now().Unix())
+	}
+	bt.Block = serialization.Block{
+		BlockHeader: serialization.BlockHeader{
+			MajorVersion: p.MajorVersion,
+			MinorVersion: p.MinorVersion,
+			Timestamp:    timestamp,
+			PreviousID:   p.PreviousID,
+		},
+		MinerTxn: minerTxn,
+	}
+	for _, tx := range txs {
+		bt.Block.TxnHashes = append(bt.Block.TxnHashes, tx.ID)
+	}
+
+	bt.Blob = bt.Block.Serialize()
+	if bt.HashingBlob, err = GetBlockHashingBlob(bt.Block); err != nil {
+		return nil, err
+	}
+	if p.ReserveSize > 0 {
+		bt.ReservedOffset, _, _ = extraNonceOffset(bt.Block)
+	}
+	return bt, nil
+}
+
+// settleCoinbase pays the reward for the weight of the block into minerTxn, growing the weight it's worked out for
+// while the miner transaction outgrows it, and padding the extra when the miner transaction shrinks below it.  It
+// returns the reward, without fees.  Original: the second phase of Blockchain::create_block_template
+func settleCoinbase(coin CoinParams, minerTxn *serialization.Transaction, medianWeight, txsWeight, fees, alreadyGeneratedCoins uint64, version uint8) (uint64, error) {
+	extra := minerTxn.Extra
+	cumulativeWeight := txsWeight + TransactionWeight(*minerTxn)
+	for i := 0; i < coinbaseWeightRounds; i++ {
+		reward, err := BlockReward(coin, medianWeight, cumulativeWeight, alreadyGeneratedCoins, version)
+		if err != nil {
+			return 0, err
+		}
+		minerTxn.TransactionsOut[0].Amount = reward + fees
+		minerTxn.Extra = extra
+		coinbaseWeight := TransactionWeight(*minerTxn)
+		if coinbaseWeight > cumulativeWeight-txsWeight {
+			cumulativeWeight = txsWeight + coinbaseWeight
+			continue
+		}
+		if delta := cumulativeWeight - txsWeight - coinbaseWeight; delta > 0 {
+			minerTxn.Extra = append(append([]byte(nil), extra...), make([]byte, delta)...)
+			// The extra's length can gain a byte, in which case one less byte of padding has to do
+			if txsWeight+TransactionWeight(*minerTxn) != cumulativeWeight {
+				minerTxn.Extra = minerTxn.Extra[:len(minerTxn.Extra)-1]
+				if txsWeight+TransactionWeight(*minerTxn) != cumulativeWeight {
+					cumulativeWeight += delta - 1
+					continue
+				}
+			}
+		}
+		return reward, nil
+	}
+	return 0, CoinbaseWeightUnstable
+}
+
+// selectTransactions picks transactions by fee per weight, skipping any that would go over the maximum weight.  From
+// block version 5 it also skips any that would cost more in penalty than they pay in fees, before then it stops once
+// the transactions are over the median.  Original: tx_memory_pool::fill_block_template
+func selectTransactions(coin CoinParams, mempool []MempoolTransaction, medianWeight, alreadyGeneratedCoins uint64, version uint8) ([]MempoolTransaction, uint64, uint64) {
+	sorted := append([]MempoolTransaction(nil), mempool...)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		// fee_i / weight_i > fee_j / weight_j, without dividing
+		hi1, lo1 := bits.Mul64(sorted[i].Fee, sorted[j].Weight)
+		hi2, lo2 := bits.Mul64(sorted[j].Fee, sorted[i].Weight)
+		return hi1 > hi2 || (hi1 == hi2 && lo1 > lo2)
+	})
+
+	maxWeight := 130*medianWeight/100 - CoinbaseBlobReservedSize
+	if version >= 5 {
+		maxWeight = 2*medianWeight - CoinbaseBlobReservedSize
+	}
+	bestCoinbase, _ := BlockReward(coin, medianWeight, 0, alreadyGeneratedCoins, version)
+
+	var selected []MempoolTransaction
+	var weight, fees uint64
+	for _, tx := range sorted {
+		if tx.Weight > maxWeight-weight {
+			continue
+		}
+		if version >= 5 {
+			reward, err := BlockReward(coin, medianWeight, weight+tx.Weight, alreadyGeneratedCoins, version)
+			if err != nil {
+				continue
+			}
+			coinbase := reward + fees + tx.Fee
+			if coinbase < bestCoinbase {
+				continue
+			}
+			bestCoinbase = coinbase
+		} else if weight > medianWeight {
+			break
+		}
+		selected = append(selected, tx)
+		weight += tx.Weight
+		fees += tx.Fee
+	}
+	return selected, weight, fees
+}
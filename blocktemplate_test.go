@@ -0,0 +1,201 @@
+package monerocnutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func testTemplateParams(mempool []MempoolTransaction) BlockTemplateParams {
+	return BlockTemplateParams{
+		Height:                3000000,
+		MajorVersion:          16,
+		MinorVersion:          16,
+		PreviousID:            [32]byte{0xaa},
+		Timestamp:             1700000000,
+		AlreadyGeneratedCoins: MoneySupply - 600000000000<<19,
+		MedianWeight:          FullRewardZoneV5,
+		Address:               testAddress(),
+		ReserveSize:           PoolNonceSize,
+		Mempool:               mempool,
+		Random:                bytes.NewReader(bytes.Repeat([]byte{9}, 64)),
+	}
+}
+
+func TestBuildBlockTemplate(t *testing.T) {
+	mempool := []MempoolTransaction{
+		{ID: [32]byte{1}, Weight: 1500, Fee: 30000000},
+		{ID: [32]byte{2}, Weight: 2000, Fee: 80000000},   // Best fee per weight
+		{ID: [32]byte{3}, Weight: 700000, Fee: 1 << 40},  // Too big for any block
+		{ID: [32]byte{4}, Weight: 298000, Fee: 10000000}, // Costs more in penalty than it pays
+	}
+	p := testTemplateParams(mempool)
+	bt, err := BuildBlockTemplate(MoneroParams, p)
+	if err != nil {
+		t.Fatal("Error building a block template,", err)
+	}
+
+	if len(bt.Block.TxnHashes) != 2 || bt.Block.TxnHashes[0] != mempool[1].ID || bt.Block.TxnHashes[1] != mempool[0].ID {
+		t.Fatalf("Expected transactions 2 and 1, got %x", bt.Block.TxnHashes)
+	}
+	if bt.Fees != 110000000 || bt.ExpectedReward != 600000000000+bt.Fees {
+		t.Fatalf("Got fees %d and reward %d", bt.Fees, bt.ExpectedReward)
+	}
+	if bt.Block.PreviousID != p.PreviousID || bt.Block.Timestamp != p.Timestamp || bt.Block.MajorVersion != 16 {
+		t.Fatalf("Header doesn't match the parameters, %+v", bt.Block.BlockHeader)
+	}
+
+	// Everything should agree with what the rest of the library makes of the blob
+	b, err := ParseBlock(bt.Blob)
+	if err != nil {
+		t.Fatal("Error parsing the template,", err)
+	}
+	hashingBlob, _ := GetBlockHashingBlob(b)
+	if !bytes.Equal(hashingBlob, bt.HashingBlob) {
+		t.Fatal("Hashing blob doesn't match the template")
+	}
+	if reserved, err := ReservedSpace(bt.Blob, bt.ReservedOffset); err != nil || reserved != PoolNonceSize {
+		t.Fatalf("Expected %d bytes reserved at %d, got %d, %v", PoolNonceSize, bt.ReservedOffset, reserved, err)
+	}
+	if _, _, err = WriteReservedNonce(bt.Blob, bt.ReservedOffset, PoolNonce{PoolID: 1}.Bytes()); err != nil {
+		t.Fatal("Error writing a pool nonce,", err)
+	}
+	if err = CheckCoinbaseAmount(MoneroParams, b, p.MedianWeight, bt.Weight, p.AlreadyGeneratedCoins, bt.Fees); err != nil {
+		t.Fatal("Template's coinbase amount was rejected,", err)
+	}
+}
+
+func TestBuildBlockTemplatePenalty(t *testing.T) {
+	// Big enough to pay for its penalty, so the reward drops and the coinbase has to be reworked for the weight
+	mempool := []MempoolTransaction{{ID: [32]byte{1}, Weight: 350000, Fee: 200000000000}}
+	p := testTemplateParams(mempool)
+	bt, err := BuildBlockTemplate(MoneroParams, p)
+	if err != nil {
+		t.Fatal("Error building a block template,", err)
+	}
+	if len(bt.Block.TxnHashes) != 1 {
+		t.Fatal("Expected the transaction to be included")
+	}
+	reward, _ := BlockReward(MoneroParams, p.MedianWeight, bt.Weight, p.AlreadyGeneratedCoins, p.MajorVersion)
+	if reward >= 600000000000 || bt.ExpectedReward != reward+200000000000 {
+		t.Fatalf("Expected a penalised reward, got %d", bt.ExpectedReward)
+	}
+	b, _ := ParseBlock(bt.Blob)
+	if err = CheckCoinbaseAmount(MoneroParams, b, p.MedianWeight, bt.Weight, p.AlreadyGeneratedCoins, bt.Fees); err != nil {
+		t.Fatal("Template's coinbase amount was rejected,", err)
+	}
+	if bt.Weight != 350000+TransactionWeight(b.MinerTxn) {
+		t.Fatalf("Expected the weight to include the miner transaction, got %d", bt.Weight)
+	}
+}
+
+func TestBuildBlockTemplatePadding(t *testing.T) {
+	// A base reward of 4 XMR and a transaction paying just enough that the miner transaction's amount needs a seventh
+	// varint byte for the reward of the transactions alone, but not once the penalty for its own weight comes off
+	p := testTemplateParams(nil)
+	p.AlreadyGeneratedCoins = MoneySupply - 4000000000000<<19
+	reward, _ := BlockReward(MoneroParams, p.MedianWeight, 400000, p.AlreadyGeneratedCoins, p.MajorVersion)
+	p.Mempool = []MempoolTransaction{{ID: [32]byte{1}, Weight: 400000, Fee: 1<<42 - reward + 1000}}
+	bt, err := BuildBlockTemplate(MoneroParams, p)
+	if err != nil {
+		t.Fatal("Error building a block template,", err)
+	}
+	if len(bt.Block.TxnHashes) != 1 || bt.ExpectedReward >= 1<<42 {
+		t.Fatalf("Expected the transaction and a six byte amount, got %d", bt.ExpectedReward)
+	}
+
+	// The miner transaction shrank by a byte, which the daemon pads back out in the extra
+	b, _ := ParseBlock(bt.Blob)
+	extra := b.MinerTxn.ExtraFields()
+	if last := extra.Fields[len(extra.Fields)-1]; !last.Padding.Used || last.Padding.Size != 1 {
+		t.Fatalf("Expected a byte of padding at the end of the extra, got %+v", extra)
+	}
+	if bt.Weight != 400000+TransactionWeight(b.MinerTxn) {
+		t.Fatalf("Expected the padded miner transaction's weight, got %d", bt.Weight)
+	}
+	if err = CheckCoinbaseAmount(MoneroParams, b, p.MedianWeight, bt.Weight, p.AlreadyGeneratedCoins, bt.Fees); err != nil {
+		t.Fatal("Template's coinbase amount was rejected,", err)
+	}
+}
+
+func TestSelectTransactionsBeforeVersion5(t *testing.T) {
+	// Blocks could only go 30% over the median, and filling stopped once past the median whatever the penalty
+	mempool := []MempoolTransaction{
+		{ID: [32]byte{1}, Weight: 61000, Fee: 1 << 40},
+		{ID: [32]byte{2}, Weight: 10000, Fee: 1 << 30},
+	}
+	if txs, weight, _ := selectTransactions(MoneroParams, mempool, FullRewardZoneV2, 0, 4); len(txs) != 1 || weight != 61000 {
+		t.Fatalf("Expected to stop after the median, got %d transactions", len(txs))
+	}
+	mempool = []MempoolTransaction{
+		{ID: [32]byte{1}, Weight: 130*FullRewardZoneV2/100 - CoinbaseBlobReservedSize + 1, Fee: 1 << 40},
+		{ID: [32]byte{2}, Weight: 130*FullRewardZoneV2/100 - CoinbaseBlobReservedSize, Fee: 1 << 30},
+	}
+	if txs, _, _ := selectTransactions(MoneroParams, mempool, FullRewardZoneV2, 0, 4); len(txs) != 1 || txs[0].ID != mempool[1].ID {
+		t.Fatalf("Expected only the transaction within 130%% of the median, got %d", len(txs))
+	}
+}
+
+// The get_block_template response for mainnet height 2286447, with a reserve size of 60 and this wallet address
+const (
+	daemonTemplateAddress     = "44GBHzv6ZyQdJkjqZje6KLZ3xSyN1hBSFAnLP6EAqJtCRVzMzZmeXTC2AHKDS9aEDTRKmo6a6o9r9j86pYfhCWDkKjbtcns"
+	daemonTemplateBlob        = "0e0ed286da8006ecdc1aab3033cf1716c52f13f9d8ae0051615a2453643de94643b550d543becd0000000002abc78b0101ffefc68b0101fcfcf0d4b422025014bb4a1eade6622fd781cb1063381cad396efa69719b41aa28b4fce8c7ad4b5f019ce1dc670456b24a5e03c2d9058a2df10fec779e2579753b1847b74ee644f16b023c00000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000000051399a1bc46a846474f5b33db24eae173a26393b976054ee14f9feefe99925233802867097564c9db7a36af5bb5ed33ab46e63092bd8d32cef121608c3258edd55562812e21cc7e3ac73045745a72f7d74581d9a0849d6f30e8b2923171253e864f4e9ddea3acb5bc755f1c4a878130a70c26297540bc0b7a57affb6b35c1f03d8dbd54ece8457531f8cba15bb74516779c01193e212050423020e45aa2c15dcb"
+	daemonTemplateHashingBlob = "0e0ed286da8006ecdc1aab3033cf1716c52f13f9d8ae0051615a2453643de94643b550d543becd00000000d130d22cf308b308498bbc16e2e955e7dbd691e6a8fab805f98ad82e6faa8bcc06"
+)
+
+// TestBuildBlockTemplateDaemonLayout checks the template's layout byte for byte against the daemon's: the header, the
+// miner transaction and its extra, the reserved space and the hashing blob.  The recording has neither the daemon's
+// mempool nor its emission, so which transactions get picked and the reward aren't compared with the daemon's.
+func TestBuildBlockTemplateDaemonLayout(t *testing.T) {
+	daemonBlob, _ := hex.DecodeString(daemonTemplateBlob)
+	daemon, err := ParseBlock(daemonBlob)
+	if err != nil {
+		t.Fatal("Error parsing the daemon's template,", err)
+	}
+	SetValidTags(Monero, Mainnet)
+	SetActiveTag(Normal)
+	address, err := DecodeAddress(daemonTemplateAddress)
+	if err != nil {
+		t.Fatal("Error decoding the address,", err)
+	}
+
+	// The recording doesn't have the daemon's mempool or emission, so the fees and coins already generated are picked
+	// to give the reward it expected, 1182367759996, with the transactions in its order
+	const expectedReward = 1182367759996
+	var mempool []MempoolTransaction
+	var fees uint64
+	for i, id := range daemon.TxnHashes {
+		tx := MempoolTransaction{ID: id, Weight: 1500, Fee: uint64(50000000 - i*1000000)}
+		mempool = append(mempool, tx)
+		fees += tx.Fee
+	}
+	bt, err := BuildBlockTemplate(MoneroParams, BlockTemplateParams{
+		Height:                2286447,
+		MajorVersion:          daemon.MajorVersion,
+		MinorVersion:          daemon.MinorVersion,
+		PreviousID:            daemon.PreviousID,
+		Timestamp:             daemon.Timestamp,
+		AlreadyGeneratedCoins: MoneySupply - (expectedReward-fees)<<19,
+		Address:               address,
+		ReserveSize:           60,
+		Mempool:               mempool,
+	})
+	if err != nil {
+		t.Fatal("Error building a block template,", err)
+	}
+	if bt.ExpectedReward != expectedReward || bt.ReservedOffset != 130 {
+		t.Fatalf("Expected a reward of %d reserved at 130, got %d at %d", expectedReward, bt.ExpectedReward, bt.ReservedOffset)
+	}
+
+	// The transaction key is random, with the daemon's public key and output key the template is the daemon's
+	b := bt.Block
+	b.MinerTxn.Extra = append([]byte(nil), b.MinerTxn.Extra...)
+	copy(b.MinerTxn.Extra[1:33], daemon.MinerTxn.Extra[1:33])
+	b.MinerTxn.TransactionsOut[0].Key.PublicKey = daemon.MinerTxn.TransactionsOut[0].Key.PublicKey
+	if blob := b.Serialize(); !bytes.Equal(blob, daemonBlob) {
+		t.Fatalf("Expected the daemon's template\n%x, got\n%x", daemonBlob, blob)
+	}
+	if hashingBlob, _ := GetBlockHashingBlob(b); hex.EncodeToString(hashingBlob) != daemonTemplateHashingBlob {
+		t.Fatalf("Expected the daemon's hashing blob, got %x", hashingBlob)
+	}
+}
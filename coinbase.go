@@ -0,0 +1,123 @@
+package monerocnutils
+
+import (
+	"crypto/rand"
+	"errors"
+	"github.com/snipa22/monerocnutils/crypto"
+	"github.com/snipa22/monerocnutils/serialization"
+	"io"
+	"sort"
+)
+
+const (
+	// MinedMoneyUnlockWindow is how many blocks a Monero miner transaction's output stays locked for
+	MinedMoneyUnlockWindow = 60
+	// HFVersionViewTags is the block version outputs gained view tags at
+	HFVersionViewTags = 15
+	// HFVersionRctCoinbase is the block version miner transactions became version 2 transactions with a single output
+	HFVersionRctCoinbase = 4
+)
+
+var (
+	UnsupportedCoinbaseVersion = errors.New("miner transactions can only be built from block version 4")
+	MissingMinerAddress        = errors.New("a miner address is needed to build a miner transaction")
+)
+
+// CoinbaseParams describes a miner transaction for BuildCoinbase.
+type CoinbaseParams struct {
+	Height         uint64
+	Reward         uint64 // Block reward plus fees, the single output's amount
+	Address        *Address
+	MajorVersion   uint8
+	ExtraNonceSize int                        // Zeroed bytes reserved for pool nonces in an extra nonce, none if 0
+	ExtraFields    []serialization.ExtraField // Added to the extra alongside the public key and nonce
+	Random         io.Reader                  // Source of the transaction key, crypto/rand if nil
+}
+
+// BuildCoinbase builds a miner transaction paying the reward to the miner address, locked for the coin's
+// RewardUnlockWindow, returning it along with its transaction secret key.  Original: construct_miner_tx in
+// cryptonote_tx_utils.cpp
+func BuildCoinbase(coin CoinParams, p CoinbaseParams) (serialization.Transaction, [32]byte, error) {
+	var t serialization.Transaction
+	if p.MajorVersion < HFVersionRctCoinbase {
+		return t, [32]byte{}, UnsupportedCoinbaseVersion
+	}
+	if p.Address == nil {
+		return t, [32]byte{}, MissingMinerAddress
+	}
+	if p.ExtraNonceSize > serialization.ExtraNonceMaxCount {
+		return t, [32]byte{}, ReservedSizeExceeded
+	}
+	random := p.Random
+	if random == nil {
+		random = rand.Reader
+	}
+
+	txSecret, err := crypto.GenerateSecret(random)
+	if err != nil {
+		return t, txSecret, err
+	}
+	var txPublic [32]byte
+	crypto.PublicFromSecret(&txPublic, &txSecret)
+
+	derivation, err := crypto.GenerateKeyDerivation(&p.Address.view, &txSecret)
+	if err != nil {
+		return t, txSecret, err
+	}
+	outputKey, err := crypto.DerivePublicKey(derivation, 0, &p.Address.spend)
+	if err != nil {
+		return t, txSecret, err
+	}
+	out := serialization.TransactionOut{Amount: p.Reward}
+	if p.MajorVersion >= HFVersionViewTags {
+		out.TaggedKey = serialization.TransactionOutToTaggedKey{
+			PublicKey: *outputKey,
+			ViewTag:   crypto.DeriveViewTag(derivation, 0),
+			Used:      true,
+		}
+	} else {
+		out.Key = serialization.TransactionOutToKey{PublicKey: *outputKey, Used: true}
+	}
+
+	var extra serialization.Extra
+	extra.Fields = append(extra.Fields, serialization.ExtraField{
+		PublicKey: serialization.ExtraPublicKey{PublicKey: txPublic, Used: true},
+	})
+	if p.ExtraNonceSize > 0 {
+		extra.Fields = append(extra.Fields, serialization.ExtraField{
+			Nonce: serialization.ExtraNonce{Nonce: make([]byte, p.ExtraNonceSize), Used: true},
+		})
+	}
+	extra.Fields = append(extra.Fields, p.ExtraFields...)
+	sortExtra(extra.Fields)
+
+	t.Version = 2
+	t.UnlockTime = p.Height + coin.RewardUnlockWindow
+	t.TransactionsIn = []serialization.TransactionIn{
+		{Genesis: serialization.TransactionInGenesis{Height: p.Height, Used: true}},
+	}
+	t.TransactionsOut = []serialization.TransactionOut{out}
+	t.Extra = extra.Serialize()
+	return t, txSecret, nil
+}
+
+// sortExtra orders fields by type the way the daemon does, keeping fields of the same type in order.  Padding goes last
+// though, as anything after it would be read as more padding.  Original: sort_tx_extra in cryptonote_format_utils.cpp
+func sortExtra(fields []serialization.ExtraField) {
+	order := func(f serialization.ExtraField) int {
+		switch {
+		case f.Padding.Used:
+			return 6
+		case f.PublicKey.Used:
+			return 1
+		case f.Nonce.Used:
+			return 2
+		case f.MergeMiningTag.Used:
+			return 3
+		case f.AdditionalPublicKeys.Used:
+			return 4
+		}
+		return 5
+	}
+	sort.SliceStable(fields, func(i, j int) bool { return order(fields[i]) < order(fields[j]) })
+}
@@ -0,0 +1,115 @@
+package monerocnutils
+
+import (
+	"bytes"
+	"github.com/snipa22/monerocnutils/crypto"
+	"github.com/snipa22/monerocnutils/serialization"
+	"testing"
+)
+
+func TestBuildCoinbase(t *testing.T) {
+	// A wallet to pay, its keys are needed to check the output is spendable
+	var spendSecret, spendPublic, viewSecret, viewPublic [32]byte
+	crypto.SecretFromSeed(&spendSecret, &[32]byte{1, 2, 3})
+	crypto.PublicFromSecret(&spendPublic, &spendSecret)
+	crypto.ViewFromSpend(&viewSecret, &spendSecret)
+	crypto.PublicFromSecret(&viewPublic, &viewSecret)
+	address := &Address{spend: spendPublic, view: viewPublic}
+
+	mmTag := serialization.ExtraField{MergeMiningTag: serialization.ExtraMergeMiningTag{Depth: 1, Used: true}}
+	tx, txSecret, err := BuildCoinbase(MoneroParams, CoinbaseParams{
+		Height:         3000000,
+		Reward:         600000000000,
+		Address:        address,
+		MajorVersion:   16,
+		ExtraNonceSize: PoolNonceSize,
+		ExtraFields:    []serialization.ExtraField{mmTag},
+		Random:         bytes.NewReader(bytes.Repeat([]byte{7}, 64)),
+	})
+	if err != nil {
+		t.Fatal("Error building a miner transaction,", err)
+	}
+	if tx.Version != 2 || tx.UnlockTime != 3000060 || len(tx.TransactionsIn) != 1 || tx.TransactionsIn[0].Genesis.Height != 3000000 {
+		t.Fatalf("Unexpected miner transaction %+v", tx)
+	}
+
+	extra := tx.ExtraFields()
+	if len(extra.Fields) != 3 || !extra.Fields[0].PublicKey.Used || !extra.Fields[1].Nonce.Used || !extra.Fields[2].MergeMiningTag.Used {
+		t.Fatalf("Expected the public key, nonce and merge mining tag in order, got %+v", extra)
+	}
+	if nonce, _, _ := extra.Nonce(); len(nonce.Nonce) != PoolNonceSize {
+		t.Fatalf("Expected %d reserved bytes, got %d", PoolNonceSize, len(nonce.Nonce))
+	}
+	var txPublic [32]byte
+	crypto.PublicFromSecret(&txPublic, &txSecret)
+	if pk, _ := extra.PublicKey(); pk != txPublic {
+		t.Fatal("Extra public key doesn't match the transaction secret key")
+	}
+
+	// The wallet finds the output with its view key and can spend it with its spend key
+	out := tx.TransactionsOut[0]
+	if out.Amount != 600000000000 || !out.TaggedKey.Used || out.Key.Used {
+		t.Fatalf("Expected a tagged output of the reward, got %+v", out)
+	}
+	derivation, err := crypto.GenerateKeyDerivation(&txPublic, &viewSecret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if crypto.DeriveViewTag(derivation, 0) != out.TaggedKey.ViewTag {
+		t.Fatal("View tag doesn't match")
+	}
+	outputPublic, _ := crypto.DerivePublicKey(derivation, 0, &spendPublic)
+	outputSecret, _ := crypto.DeriveSecretKey(derivation, 0, &spendSecret)
+	var spendable [32]byte
+	crypto.PublicFromSecret(&spendable, outputSecret)
+	if *outputPublic != out.TaggedKey.PublicKey || spendable != out.TaggedKey.PublicKey {
+		t.Fatal("Output key isn't spendable by the miner")
+	}
+
+	parsed, err := ParseTransaction(tx.Serialize())
+	if err != nil || !bytes.Equal(parsed.Serialize(), tx.Serialize()) {
+		t.Fatal("Miner transaction didn't survive a round trip,", err)
+	}
+	block := serialization.Block{BlockHeader: serialization.BlockHeader{MajorVersion: 16, MinorVersion: 16}, MinerTxn: tx}
+	if parsedBlock, err := ParseBlock(block.Serialize()); err != nil || !bytes.Equal(parsedBlock.Serialize(), block.Serialize()) {
+		t.Fatal("Block with a tagged miner output didn't survive a round trip,", err)
+	}
+}
+
+// testAddress returns an address whose spend and view secret keys are 1 and 2.
+func testAddress() *Address {
+	address := &Address{}
+	crypto.PublicFromSecret(&address.spend, &[32]byte{1})
+	crypto.PublicFromSecret(&address.view, &[32]byte{2})
+	return address
+}
+
+func TestBuildCoinbaseVersions(t *testing.T) {
+	address := testAddress()
+
+	tx, _, err := BuildCoinbase(MoneroParams, CoinbaseParams{Height: 1, Reward: 1, Address: address, MajorVersion: 14})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !tx.TransactionsOut[0].Key.Used || tx.TransactionsOut[0].TaggedKey.Used {
+		t.Fatal("Expected an output without a view tag before version 15")
+	}
+	if extra := tx.ExtraFields(); len(extra.Fields) != 1 {
+		t.Fatalf("Expected just the public key without a nonce size, got %+v", extra)
+	}
+	coin := MoneroParams
+	coin.RewardUnlockWindow = 10
+	if tx, _, err = BuildCoinbase(coin, CoinbaseParams{Height: 1, Reward: 1, Address: address, MajorVersion: 14}); err != nil || tx.UnlockTime != 11 {
+		t.Fatalf("Expected the coin's unlock window, got %d, %v", tx.UnlockTime, err)
+	}
+
+	if _, _, err = BuildCoinbase(MoneroParams, CoinbaseParams{Address: address, MajorVersion: 3}); err != UnsupportedCoinbaseVersion {
+		t.Fatalf("Expected UnsupportedCoinbaseVersion, got %v", err)
+	}
+	if _, _, err = BuildCoinbase(MoneroParams, CoinbaseParams{MajorVersion: 16}); err != MissingMinerAddress {
+		t.Fatalf("Expected MissingMinerAddress, got %v", err)
+	}
+	if _, _, err = BuildCoinbase(MoneroParams, CoinbaseParams{Address: address, MajorVersion: 16, ExtraNonceSize: 256}); err != ReservedSizeExceeded {
+		t.Fatalf("Expected ReservedSizeExceeded, got %v", err)
+	}
+}
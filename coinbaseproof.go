@@ -0,0 +1,40 @@
+package monerocnutils
+
+import (
+	"github.com/snipa22/monerocnutils/crypto"
+	"github.com/snipa22/monerocnutils/serialization"
+)
+
+// CoinbaseProof shows a miner transaction is part of a block without carrying all of the block's transaction hashes,
+// just the branch from the miner transaction to the merkle root.
+type CoinbaseProof struct {
+	Header   serialization.BlockHeader
+	MinerTxn serialization.Transaction
+	Branch   [][32]byte
+	TxCount  uint64 // Including the miner transaction
+}
+
+// NewCoinbaseProof builds the CoinbaseProof for b's miner transaction.
+func NewCoinbaseProof(b serialization.Block) CoinbaseProof {
+	return CoinbaseProof{
+		Header:   b.BlockHeader,
+		MinerTxn: b.MinerTxn,
+		Branch:   CoinbaseBranch(b),
+		TxCount:  uint64(len(b.TxnHashes) + 1),
+	}
+}
+
+// CoinbaseBranch returns the branch from b's miner transaction to its merkle root, see crypto.TreeBranch.
+func CoinbaseBranch(b serialization.Block) [][32]byte {
+	hashes := append([][32]byte{getTransactionHash(b.MinerTxn)}, b.TxnHashes...)
+	branch, _, _ := crypto.TreeBranch(hashes, 0)
+	return branch
+}
+
+// HashingBlob rebuilds the block's hashing blob from the proof, which then only needs to meet the difficulty.
+func (p CoinbaseProof) HashingBlob() []byte {
+	root := crypto.TreeHashFromBranch(p.Branch, getTransactionHash(p.MinerTxn), nil)
+	blob := p.Header.Serialize()
+	blob = append(blob, root[:]...)
+	return serialization.WriteUint(blob, p.TxCount)
+}
@@ -0,0 +1,42 @@
+package monerocnutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+func TestCoinbaseProof(t *testing.T) {
+	for template, hashingBlob := range map[string]string{
+		onlyMinerBlockTemplate: onlyMinerBlockTemplateHashingBlob,
+		minerTXBlockTemplate2:  minerTXBlockTemplate2HashingBlob,
+	} {
+		blob, _ := hex.DecodeString(template)
+		b, err := ParseBlock(blob)
+		if err != nil {
+			t.Fatal(err)
+		}
+		// The daemon's own hashing blob for the whole block
+		if hex.EncodeToString(NewCoinbaseProof(b).HashingBlob()) != hashingBlob {
+			t.Fatal("Proof doesn't give the daemon's hashing blob")
+		}
+		var expected []byte
+
+		// Try every size of tree, from the miner transaction alone up
+		hashes := b.TxnHashes
+		for n := 0; n <= len(hashes); n++ {
+			b.TxnHashes = hashes[:n]
+			expected, _ = GetBlockHashingBlob(b)
+			proof := NewCoinbaseProof(b)
+			if !bytes.Equal(proof.HashingBlob(), expected) {
+				t.Fatalf("Proof with %d transactions doesn't give the block's hashing blob", n)
+			}
+		}
+
+		proof := NewCoinbaseProof(b)
+		proof.MinerTxn.UnlockTime++
+		if len(b.TxnHashes) > 0 && bytes.Equal(proof.HashingBlob(), expected) {
+			t.Fatal("Proof for a different miner transaction gave the block's hashing blob")
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package monerocnutils
+
+import (
+	"errors"
+	"sync"
+)
+
+var (
+	UnknownCoin    = errors.New("coin is not registered")
+	UnknownNetwork = errors.New("coin has no address prefixes for the network")
+)
+
+// AddressPrefixes are the varint prefixes a coin's addresses start with on one network.
+type AddressPrefixes struct {
+	Standard   uint64
+	Integrated uint64
+	Subaddress uint64
+}
+
+// CoinParams describes a CryptoNote coin, the reward, unlock and difficulty functions take one.
+type CoinParams struct {
+	Name                  string
+	Prefixes              map[Network]AddressPrefixes
+	AtomicUnits           uint64 // Atomic units in one coin
+	EmissionSpeedFactor   uint64 // Per minute of block target, see BaseReward
+	FinalSubsidyPerMinute uint64 // Tail emission per minute of block target
+	DifficultyTarget      uint64 // Seconds between blocks
+	DifficultyTargetV1    uint64 // Seconds between blocks before block version 2, DifficultyTarget if 0
+	DifficultyWindow      int    // Blocks the difficulty is worked out from
+	DifficultyLag         int    // Most recent blocks left out of the window
+	DifficultyCut         int    // Outlying timestamps dropped at either end of the window
+	RewardUnlockWindow    uint64 // Blocks a miner transaction's outputs stay locked for
+	MoneySupply           uint64
+	BlobType              BlobType
+}
+
+// TargetSeconds returns the time between blocks of version.  Original: get_difficulty_target
+func (p CoinParams) TargetSeconds(version uint8) uint64 {
+	if version < 2 && p.DifficultyTargetV1 != 0 {
+		return p.DifficultyTargetV1
+	}
+	return p.DifficultyTarget
+}
+
+var (
+	coinsMu sync.RWMutex
+	coins   = make(map[Coin]CoinParams)
+)
+
+// MoneroParams are Monero's parameters, registered as Monero.  Source: cryptonote_config.h
+var MoneroParams = CoinParams{
+	Name: "monero",
+	Prefixes: map[Network]AddressPrefixes{
+		Mainnet:  {Standard: 0x12, Integrated: 0x13, Subaddress: 0x2a},
+		Testnet:  {Standard: 0x35, Integrated: 0x36, Subaddress: 0x3f},
+		Stagenet: {Standard: 0x18, Integrated: 0x19, Subaddress: 0x24},
+	},
+	AtomicUnits:           1000000000000,
+	EmissionSpeedFactor:   EmissionSpeedFactorPerMinute,
+	FinalSubsidyPerMinute: FinalSubsidyPerMinute,
+	DifficultyTarget:      DifficultyTarget,
+	DifficultyTargetV1:    DifficultyTargetV1,
+	DifficultyWindow:      DifficultyWindow,
+	DifficultyLag:         DifficultyLag,
+	DifficultyCut:         DifficultyCut,
+	RewardUnlockWindow:    MinedMoneyUnlockWindow,
+	MoneySupply:           MoneySupply,
+	BlobType:              BlobTypeCryptonote,
+}
+
+func init() {
+	RegisterCoin(Monero, MoneroParams)
+}
+
+// RegisterCoin adds a coin, or replaces the parameters of one already registered.
+func RegisterCoin(c Coin, p CoinParams) {
+	prefixes := make(map[Network]AddressPrefixes, len(p.Prefixes))
+	for n, np := range p.Prefixes {
+		prefixes[n] = np
+	}
+	p.Prefixes = prefixes
+
+	coinsMu.Lock()
+	defer coinsMu.Unlock()
+	coins[c] = p
+}
+
+// LookupCoin returns the parameters of a registered coin.  The returned Prefixes are shared and mustn't be modified.
+func LookupCoin(c Coin) (CoinParams, error) {
+	coinsMu.RLock()
+	defer coinsMu.RUnlock()
+	p, ok := coins[c]
+	if !ok {
+		return p, UnknownCoin
+	}
+	return p, nil
+}
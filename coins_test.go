@@ -0,0 +1,55 @@
+package monerocnutils
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestMoneroCoinParams(t *testing.T) {
+	p, err := LookupCoin(Monero)
+	if err != nil {
+		t.Fatal("Error looking up Monero,", err)
+	}
+	if p.Prefixes[Mainnet].Standard != 0x12 || p.DifficultyTarget != 120 || p.RewardUnlockWindow != 60 {
+		t.Fatalf("Unexpected Monero parameters %+v", p)
+	}
+	if err = SetValidTags(Monero, Stagenet); err != nil {
+		t.Fatal("Error setting stagenet tags,", err)
+	}
+	if !reflect.DeepEqual(Tags, []uint64{0x18, 0x19, 0x24}) {
+		t.Fatalf("Unexpected stagenet tags %x", Tags)
+	}
+	if err = SetValidTags(Monero, Mainnet); err != nil {
+		t.Fatal("Error setting mainnet tags,", err)
+	}
+}
+
+func TestRegisterCoin(t *testing.T) {
+	const testCoin Coin = 1002
+	if _, err := LookupCoin(testCoin); err != UnknownCoin {
+		t.Fatalf("Expected UnknownCoin, got %v", err)
+	}
+
+	prefixes := map[Network]AddressPrefixes{Mainnet: {Standard: 0x01, Integrated: 0x02, Subaddress: 0x03}}
+	RegisterCoin(testCoin, CoinParams{Name: "test", Prefixes: prefixes, BlobType: BlobTypeForknote})
+	prefixes[Testnet] = AddressPrefixes{}
+	defer SetValidTags(Monero, Mainnet)
+
+	if bt, err := CoinBlobType(testCoin); err != nil || bt != BlobTypeForknote {
+		t.Fatalf("Expected a forknote blob type, got %v, %v", bt, err)
+	}
+	if err := SetValidTags(testCoin, Mainnet); err != nil || !reflect.DeepEqual(Tags, []uint64{1, 2, 3}) {
+		t.Fatalf("Expected the test coin's tags, got %x, %v", Tags, err)
+	}
+	if err := SetValidTags(testCoin, Testnet); err != UnknownNetwork {
+		t.Fatalf("Expected UnknownNetwork, got %v", err)
+	}
+
+	// Multi byte prefixes such as Aeon's
+	RegisterCoin(testCoin, CoinParams{Name: "test", Prefixes: map[Network]AddressPrefixes{
+		Mainnet: {Standard: 0xb2, Integrated: 0x06cd, Subaddress: 0x400b},
+	}})
+	if err := SetValidTags(testCoin, Mainnet); err != nil || !reflect.DeepEqual(Tags, []uint64{0xb2, 0x06cd, 0x400b}) {
+		t.Fatalf("Expected multi byte tags, got %x, %v", Tags, err)
+	}
+}
@@ -0,0 +1,113 @@
+package crypto
+
+import "encoding/binary"
+
+// A software AES round, as used by CryptoNight's scratchpad functions and RandomX's generators.  Go's crypto/aes only
+// exposes whole block encryptions, while both need single rounds (aesenc and aesdec) with their own round keys.
+
+// aesSbox is the AES forward S-box.
+var aesSbox = [256]byte{
+	0x63, 0x7c, 0x77, 0x7b, 0xf2, 0x6b, 0x6f, 0xc5, 0x30, 0x01, 0x67, 0x2b, 0xfe, 0xd7, 0xab, 0x76,
+	0xca, 0x82, 0xc9, 0x7d, 0xfa, 0x59, 0x47, 0xf0, 0xad, 0xd4, 0xa2, 0xaf, 0x9c, 0xa4, 0x72, 0xc0,
+	0xb7, 0xfd, 0x93, 0x26, 0x36, 0x3f, 0xf7, 0xcc, 0x34, 0xa5, 0xe5, 0xf1, 0x71, 0xd8, 0x31, 0x15,
+	0x04, 0xc7, 0x23, 0xc3, 0x18, 0x96, 0x05, 0x9a, 0x07, 0x12, 0x80, 0xe2, 0xeb, 0x27, 0xb2, 0x75,
+	0x09, 0x83, 0x2c, 0x1a, 0x1b, 0x6e, 0x5a, 0xa0, 0x52, 0x3b, 0xd6, 0xb3, 0x29, 0xe3, 0x2f, 0x84,
+	0x53, 0xd1, 0x00, 0xed, 0x20, 0xfc, 0xb1, 0x5b, 0x6a, 0xcb, 0xbe, 0x39, 0x4a, 0x4c, 0x58, 0xcf,
+	0xd0, 0xef, 0xaa, 0xfb, 0x43, 0x4d, 0x33, 0x85, 0x45, 0xf9, 0x02, 0x7f, 0x50, 0x3c, 0x9f, 0xa8,
+	0x51, 0xa3, 0x40, 0x8f, 0x92, 0x9d, 0x38, 0xf5, 0xbc, 0xb6, 0xda, 0x21, 0x10, 0xff, 0xf3, 0xd2,
+	0xcd, 0x0c, 0x13, 0xec, 0x5f, 0x97, 0x44, 0x17, 0xc4, 0xa7, 0x7e, 0x3d, 0x64, 0x5d, 0x19, 0x73,
+	0x60, 0x81, 0x4f, 0xdc, 0x22, 0x2a, 0x90, 0x88, 0x46, 0xee, 0xb8, 0x14, 0xde, 0x5e, 0x0b, 0xdb,
+	0xe0, 0x32, 0x3a, 0x0a, 0x49, 0x06, 0x24, 0x5c, 0xc2, 0xd3, 0xac, 0x62, 0x91, 0x95, 0xe4, 0x79,
+	0xe7, 0xc8, 0x37, 0x6d, 0x8d, 0xd5, 0x4e, 0xa9, 0x6c, 0x56, 0xf4, 0xea, 0x65, 0x7a, 0xae, 0x08,
+	0xba, 0x78, 0x25, 0x2e, 0x1c, 0xa6, 0xb4, 0xc6, 0xe8, 0xdd, 0x74, 0x1f, 0x4b, 0xbd, 0x8b, 0x8a,
+	0x70, 0x3e, 0xb5, 0x66, 0x48, 0x03, 0xf6, 0x0e, 0x61, 0x35, 0x57, 0xb9, 0x86, 0xc1, 0x1d, 0x9e,
+	0xe1, 0xf8, 0x98, 0x11, 0x69, 0xd9, 0x8e, 0x94, 0x9b, 0x1e, 0x87, 0xe9, 0xce, 0x55, 0x28, 0xdf,
+	0x8c, 0xa1, 0x89, 0x0d, 0xbf, 0xe6, 0x42, 0x68, 0x41, 0x99, 0x2d, 0x0f, 0xb0, 0x54, 0xbb, 0x16,
+}
+
+// aesTables are the combined SubBytes and MixColumns tables, one per row of the state.  aesInvTables are the same for
+// InvSubBytes and InvMixColumns.
+var aesTables, aesInvTables [4][256]uint32
+
+func init() {
+	var invSbox [256]byte
+	for i := 0; i < 256; i++ {
+		invSbox[aesSbox[i]] = byte(i)
+	}
+	for i := 0; i < 256; i++ {
+		s := uint32(aesSbox[i])
+		s2 := uint32(gfMul2(byte(s)))
+		s3 := s2 ^ s
+		t := s2 | s<<8 | s<<16 | s3<<24
+		for j := 0; j < 4; j++ {
+			aesTables[j][i] = t
+			t = t<<8 | t>>24
+		}
+
+		v := invSbox[i]
+		t = uint32(gfMul(v, 0x0e)) | uint32(gfMul(v, 0x09))<<8 | uint32(gfMul(v, 0x0d))<<16 | uint32(gfMul(v, 0x0b))<<24
+		for j := 0; j < 4; j++ {
+			aesInvTables[j][i] = t
+			t = t<<8 | t>>24
+		}
+	}
+}
+
+// gfMul2 multiplies b by x in AES's GF(2^8).
+func gfMul2(b byte) byte {
+	r := b << 1
+	if b&0x80 != 0 {
+		r ^= 0x1b
+	}
+	return r
+}
+
+// aesRound performs a single AES encryption round (ShiftRows, SubBytes, MixColumns, AddRoundKey) on the block, in
+// place.  The block and key are little endian words, like the __m128i the daemon works with.
+func aesRound(block *[4]uint32, key *[4]uint32) {
+	s0, s1, s2, s3 := block[0], block[1], block[2], block[3]
+	block[0] = aesTables[0][s0&0xff] ^ aesTables[1][(s1>>8)&0xff] ^ aesTables[2][(s2>>16)&0xff] ^ aesTables[3][s3>>24] ^ key[0]
+	block[1] = aesTables[0][s1&0xff] ^ aesTables[1][(s2>>8)&0xff] ^ aesTables[2][(s3>>16)&0xff] ^ aesTables[3][s0>>24] ^ key[1]
+	block[2] = aesTables[0][s2&0xff] ^ aesTables[1][(s3>>8)&0xff] ^ aesTables[2][(s0>>16)&0xff] ^ aesTables[3][s1>>24] ^ key[2]
+	block[3] = aesTables[0][s3&0xff] ^ aesTables[1][(s0>>8)&0xff] ^ aesTables[2][(s1>>16)&0xff] ^ aesTables[3][s2>>24] ^ key[3]
+}
+
+// aesDecRound performs a single AES decryption round (InvShiftRows, InvSubBytes, InvMixColumns, AddRoundKey) on the
+// block, in place, matching aesdec.
+func aesDecRound(block *[4]uint32, key *[4]uint32) {
+	s0, s1, s2, s3 := block[0], block[1], block[2], block[3]
+	block[0] = aesInvTables[0][s0&0xff] ^ aesInvTables[1][(s3>>8)&0xff] ^ aesInvTables[2][(s2>>16)&0xff] ^ aesInvTables[3][s1>>24] ^ key[0]
+	block[1] = aesInvTables[0][s1&0xff] ^ aesInvTables[1][(s0>>8)&0xff] ^ aesInvTables[2][(s3>>16)&0xff] ^ aesInvTables[3][s2>>24] ^ key[1]
+	block[2] = aesInvTables[0][s2&0xff] ^ aesInvTables[1][(s1>>8)&0xff] ^ aesInvTables[2][(s0>>16)&0xff] ^ aesInvTables[3][s3>>24] ^ key[2]
+	block[3] = aesInvTables[0][s3&0xff] ^ aesInvTables[1][(s2>>8)&0xff] ^ aesInvTables[2][(s1>>16)&0xff] ^ aesInvTables[3][s0>>24] ^ key[3]
+}
+
+// aesExpandKey expands a 256 bit key with the AES-256 key schedule, keeping only the first 10 round keys, which is
+// all CryptoNight uses.
+func aesExpandKey(key []byte) [10][4]uint32 {
+	var w [40]uint32
+	for i := 0; i < 8; i++ {
+		w[i] = binary.LittleEndian.Uint32(key[i*4:])
+	}
+	rcon := uint32(1)
+	for i := 8; i < 40; i++ {
+		t := w[i-1]
+		if i%8 == 0 {
+			t = subWord(t>>8|t<<24) ^ rcon
+			rcon = uint32(gfMul2(byte(rcon)))
+		} else if i%8 == 4 {
+			t = subWord(t)
+		}
+		w[i] = w[i-8] ^ t
+	}
+
+	var keys [10][4]uint32
+	for i := range keys {
+		copy(keys[i][:], w[i*4:i*4+4])
+	}
+	return keys
+}
+
+func subWord(w uint32) uint32 {
+	return uint32(aesSbox[w&0xff]) | uint32(aesSbox[(w>>8)&0xff])<<8 | uint32(aesSbox[(w>>16)&0xff])<<16 | uint32(aesSbox[w>>24])<<24
+}
@@ -0,0 +1,153 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Argon2d's memory filling, which RandomX uses to build its cache.  Only a single lane is supported and the final
+// tag is never computed, RandomX keeps the filled memory instead.  Source: RFC 9106
+
+const (
+	argon2BlockSize    = 1024
+	argon2BlockWords   = argon2BlockSize / 8
+	argon2SyncPoints   = 4
+	argon2Version      = 0x13
+	argon2TypeD        = 0
+	argon2PrehashBytes = 64
+)
+
+// argon2Block is a single 1 KiB block of Argon2 memory.
+type argon2Block [argon2BlockWords]uint64
+
+// blake2bLong is Argon2's variable length hash H', built out of BLAKE2b.
+func blake2bLong(in []byte, size int) []byte {
+	input := append(appendUint32(nil, uint32(size)), in...)
+	if size <= 64 {
+		return blake2b(input, size)
+	}
+
+	out := make([]byte, 0, size)
+	v := blake2b(input, 64)
+	for size-len(out) > 64 {
+		out = append(out, v[:32]...)
+		if size-len(out) > 64 {
+			v = blake2b(v, 64)
+		}
+	}
+	return append(out, blake2b(v, size-len(out))...)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return append(b, byte(v), byte(v>>8), byte(v>>16), byte(v>>24))
+}
+
+// argon2dFill returns memory KiB of Argon2d memory, filled with iterations passes over password and salt.
+func argon2dFill(password, salt []byte, iterations, memory uint32) []argon2Block {
+	// H0 binds every parameter
+	var h0 []byte
+	for _, e := range []uint32{1, 0, memory, iterations, argon2Version, argon2TypeD} {
+		h0 = appendUint32(h0, e)
+	}
+	h0 = append(appendUint32(h0, uint32(len(password))), password...)
+	h0 = append(appendUint32(h0, uint32(len(salt))), salt...)
+	h0 = appendUint32(h0, 0) // Secret
+	h0 = appendUint32(h0, 0) // Associated data
+	h0 = blake2b(h0, argon2PrehashBytes)
+
+	// The first two blocks come straight from H0
+	blocks := make([]argon2Block, memory)
+	for i := range blocks[:2] {
+		b := blake2bLong(appendUint32(appendUint32(h0[:argon2PrehashBytes:argon2PrehashBytes], uint32(i)), 0), argon2BlockSize)
+		for j := range blocks[i] {
+			blocks[i][j] = binary.LittleEndian.Uint64(b[j*8:])
+		}
+	}
+
+	laneLength := memory
+	segmentLength := laneLength / argon2SyncPoints
+	for pass := uint32(0); pass < iterations; pass++ {
+		for slice := uint32(0); slice < argon2SyncPoints; slice++ {
+			index := uint32(0)
+			if pass == 0 && slice == 0 {
+				index = 2
+			}
+			offset := slice*segmentLength + index
+			for ; index < segmentLength; index, offset = index+1, offset+1 {
+				prev := offset - 1
+				if offset == 0 {
+					prev = laneLength - 1
+				}
+
+				// Argon2d picks the reference block from the previous block's contents
+				j1 := blocks[prev][0] & 0xffffffff
+				var areaSize, start uint32
+				if pass == 0 {
+					areaSize = slice*segmentLength + index - 1
+				} else {
+					areaSize = laneLength - segmentLength + index - 1
+					if slice != argon2SyncPoints-1 {
+						start = (slice + 1) * segmentLength
+					}
+				}
+				x := j1 * j1 >> 32
+				x = uint64(areaSize) - 1 - (uint64(areaSize) * x >> 32)
+				ref := uint32((uint64(start) + x) % uint64(laneLength))
+
+				argon2FillBlock(&blocks[offset], &blocks[prev], &blocks[ref], pass != 0)
+			}
+		}
+	}
+	return blocks
+}
+
+// argon2FillBlock computes the compression function G over prev and ref into next, keeping next's old contents when
+// xor is set as version 1.3 does on later passes.
+func argon2FillBlock(next, prev, ref *argon2Block, xor bool) {
+	var r, t argon2Block
+	for i := range r {
+		r[i] = prev[i] ^ ref[i]
+	}
+	t = r
+	if xor {
+		for i := range t {
+			t[i] ^= next[i]
+		}
+	}
+
+	// Apply the permutation to each row, then each column, of 16 words
+	for i := 0; i < 8; i++ {
+		j := i * 16
+		blamkaRound(&r, j, j+1, j+2, j+3, j+4, j+5, j+6, j+7, j+8, j+9, j+10, j+11, j+12, j+13, j+14, j+15)
+	}
+	for i := 0; i < 8; i++ {
+		j := i * 2
+		blamkaRound(&r, j, j+1, j+16, j+17, j+32, j+33, j+48, j+49, j+64, j+65, j+80, j+81, j+96, j+97, j+112, j+113)
+	}
+
+	for i := range next {
+		next[i] = t[i] ^ r[i]
+	}
+}
+
+// blamkaRound is a BLAKE2b round without the message, using BlaMka's multiplication hardened G.
+func blamkaRound(b *argon2Block, v0, v1, v2, v3, v4, v5, v6, v7, v8, v9, v10, v11, v12, v13, v14, v15 int) {
+	g := func(a, b2, c, d *uint64) {
+		*a += *b2 + 2*uint64(uint32(*a))*uint64(uint32(*b2))
+		*d = bits.RotateLeft64(*d^*a, -32)
+		*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+		*b2 = bits.RotateLeft64(*b2^*c, -24)
+		*a += *b2 + 2*uint64(uint32(*a))*uint64(uint32(*b2))
+		*d = bits.RotateLeft64(*d^*a, -16)
+		*c += *d + 2*uint64(uint32(*c))*uint64(uint32(*d))
+		*b2 = bits.RotateLeft64(*b2^*c, -63)
+	}
+	g(&b[v0], &b[v4], &b[v8], &b[v12])
+	g(&b[v1], &b[v5], &b[v9], &b[v13])
+	g(&b[v2], &b[v6], &b[v10], &b[v14])
+	g(&b[v3], &b[v7], &b[v11], &b[v15])
+	g(&b[v0], &b[v5], &b[v10], &b[v15])
+	g(&b[v1], &b[v6], &b[v11], &b[v12])
+	g(&b[v2], &b[v7], &b[v8], &b[v13])
+	g(&b[v3], &b[v4], &b[v9], &b[v14])
+}
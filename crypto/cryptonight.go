@@ -0,0 +1,296 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"math/bits"
+)
+
+// CryptoNight, the proof of work Monero used up to block version 12, along with the parameter sets used by other
+// CryptoNote coins.  Source: slow-hash.c
+
+// CryptonightVariant selects one of the CryptoNight algorithms.
+type CryptonightVariant int
+
+const (
+	CryptonightV0     CryptonightVariant = iota // cn/0, the original algorithm
+	CryptonightV1                               // cn/1, Monero v7
+	CryptonightV2                               // cn/2, Monero v8
+	CryptonightR                                // cn/r, Monero v10, needs the block height
+	CryptonightLiteV0                           // cn-lite/0, 1 MB scratchpad
+	CryptonightLiteV1                           // cn-lite/1, 1 MB scratchpad with the v1 tweak
+	CryptonightHeavy                            // cn-heavy/0, 4 MB scratchpad
+	CryptonightPico                             // cn-pico/trtl, 256 KB scratchpad with the v2 tweaks
+)
+
+var (
+	InvalidCryptonightVariant = errors.New("unknown cryptonight variant")
+	InvalidCryptonightInput   = errors.New("cryptonight variant 1 needs at least 43 bytes of input")
+)
+
+type cryptonightParams struct {
+	memory     int    // Scratchpad size in bytes
+	iterations int    // Main loop iterations, each one touches the scratchpad twice
+	mask       uint64 // Mask applied to addresses into the scratchpad
+	variant    int    // Which tweaks are applied, 0, 1, 2 or 4 as in the daemon's slow hash
+	heavy      bool   // cn-heavy's extra scratchpad mixing and division
+}
+
+var cryptonightVariants = map[CryptonightVariant]cryptonightParams{
+	CryptonightV0:     {memory: 1 << 21, iterations: 1 << 19, mask: (1<<21 - 1) &^ 15, variant: 0},
+	CryptonightV1:     {memory: 1 << 21, iterations: 1 << 19, mask: (1<<21 - 1) &^ 15, variant: 1},
+	CryptonightV2:     {memory: 1 << 21, iterations: 1 << 19, mask: (1<<21 - 1) &^ 15, variant: 2},
+	CryptonightR:      {memory: 1 << 21, iterations: 1 << 19, mask: (1<<21 - 1) &^ 15, variant: 4},
+	CryptonightLiteV0: {memory: 1 << 20, iterations: 1 << 18, mask: (1<<20 - 1) &^ 15, variant: 0},
+	CryptonightLiteV1: {memory: 1 << 20, iterations: 1 << 18, mask: (1<<20 - 1) &^ 15, variant: 1},
+	CryptonightHeavy:  {memory: 1 << 22, iterations: 1 << 18, mask: (1<<22 - 1) &^ 15, variant: 0, heavy: true},
+	// cn-pico only ever addresses the first half of its scratchpad
+	CryptonightPico: {memory: 1 << 18, iterations: 1 << 16, mask: (1<<17 - 1) &^ 15, variant: 2},
+}
+
+// keccak1600 returns the whole Keccak state after absorbing data, rather than just a digest.
+func keccak1600(data []byte) [numLanes]uint64 {
+	d := &digest{outputSize: 256 / 8, capacity: 2 * 256 / 8}
+	d.Write(data)
+	d.finalize()
+	return d.a
+}
+
+// aesRound64 is aesRound on a block held as two little endian words.
+func aesRound64(block *[2]uint64, key *[2]uint64) {
+	b := [4]uint32{uint32(block[0]), uint32(block[0] >> 32), uint32(block[1]), uint32(block[1] >> 32)}
+	k := [4]uint32{uint32(key[0]), uint32(key[0] >> 32), uint32(key[1]), uint32(key[1] >> 32)}
+	aesRound(&b, &k)
+	block[0] = uint64(b[0]) | uint64(b[1])<<32
+	block[1] = uint64(b[2]) | uint64(b[3])<<32
+}
+
+// aesPseudoRounds runs all 10 rounds over each of the 8 blocks held in text.
+func aesPseudoRounds(text *[16]uint64, keys *[10][4]uint32) {
+	for i := 0; i < 8; i++ {
+		b := [4]uint32{uint32(text[2*i]), uint32(text[2*i] >> 32), uint32(text[2*i+1]), uint32(text[2*i+1] >> 32)}
+		for j := range keys {
+			aesRound(&b, &keys[j])
+		}
+		text[2*i] = uint64(b[0]) | uint64(b[1])<<32
+		text[2*i+1] = uint64(b[2]) | uint64(b[3])<<32
+	}
+}
+
+// mixAndPropagate xors every block of text with the next one, wrapping around.  Only used by cn-heavy.
+func mixAndPropagate(text *[16]uint64) {
+	t0, t1 := text[0], text[1]
+	for i := 0; i < 14; i++ {
+		text[i] ^= text[i+2]
+	}
+	text[14] ^= t0
+	text[15] ^= t1
+}
+
+// CryptonightHash returns the CryptoNight hash of data.  height is only used by CryptonightR.
+func CryptonightHash(data []byte, variant CryptonightVariant, height uint64) ([32]byte, error) {
+	p, ok := cryptonightVariants[variant]
+	if !ok {
+		return [32]byte{}, InvalidCryptonightVariant
+	}
+	if p.variant == 1 && len(data) < 43 {
+		return [32]byte{}, InvalidCryptonightInput
+	}
+	return cryptonight(data, &p, height), nil
+}
+
+func cryptonight(data []byte, p *cryptonightParams, height uint64) [32]byte {
+	state := keccak1600(data)
+	var stateBytes [200]byte
+	for i, e := range state {
+		binary.LittleEndian.PutUint64(stateBytes[i*8:], e)
+	}
+
+	// Variant 1 tweak
+	var tweak1 uint64
+	if p.variant == 1 {
+		tweak1 = state[24] ^ binary.LittleEndian.Uint64(data[35:43])
+	}
+
+	// Fill the scratchpad by repeatedly encrypting the middle of the state
+	scratchpad := make([]uint64, p.memory/8)
+	keys := aesExpandKey(stateBytes[0:32])
+	var text [16]uint64
+	copy(text[:], state[8:24])
+	if p.heavy {
+		for i := 0; i < 16; i++ {
+			aesPseudoRounds(&text, &keys)
+			mixAndPropagate(&text)
+		}
+	}
+	for i := 0; i < len(scratchpad); i += 16 {
+		aesPseudoRounds(&text, &keys)
+		copy(scratchpad[i:i+16], text[:])
+	}
+
+	a := [2]uint64{state[0] ^ state[4], state[1] ^ state[5]}
+	b := [2]uint64{state[2] ^ state[6], state[3] ^ state[7]}
+
+	// Variant 2 keeps the previous b around, along with the results of its division and square root
+	var b1 [2]uint64
+	var divisionResult, sqrtResult uint64
+	if p.variant >= 2 {
+		b1 = [2]uint64{state[8] ^ state[10], state[9] ^ state[11]}
+		divisionResult = state[12]
+		sqrtResult = state[13]
+	}
+
+	// Variant 4 runs a random program over 9 registers, the first 4 carry over between iterations
+	var r [9]uint32
+	var code []v4Instruction
+	if p.variant >= 4 {
+		r[0], r[1] = uint32(state[12]), uint32(state[12]>>32)
+		r[2], r[3] = uint32(state[13]), uint32(state[13]>>32)
+		code = v4GenerateCode(height)
+	}
+
+	// shuffleAdd is variant 2's mixing of the 3 neighbouring blocks of the scratchpad, variant 4 also feeds them into
+	// out
+	shuffleAdd := func(j uint64, out *[2]uint64) {
+		chunk1, chunk2, chunk3 := j^2, j^4, j^6
+		c1 := [2]uint64{scratchpad[chunk1], scratchpad[chunk1+1]}
+		c2 := [2]uint64{scratchpad[chunk2], scratchpad[chunk2+1]}
+		c3 := [2]uint64{scratchpad[chunk3], scratchpad[chunk3+1]}
+		scratchpad[chunk1], scratchpad[chunk1+1] = c3[0]+b1[0], c3[1]+b1[1]
+		scratchpad[chunk3], scratchpad[chunk3+1] = c2[0]+a[0], c2[1]+a[1]
+		scratchpad[chunk2], scratchpad[chunk2+1] = c1[0]+b[0], c1[1]+b[1]
+		if p.variant >= 4 {
+			out[0] ^= c3[0] ^ c1[0] ^ c2[0]
+			out[1] ^= c3[1] ^ c1[1] ^ c2[1]
+		}
+	}
+
+	idx := a[0]
+	for i := 0; i < p.iterations; i++ {
+		// Iteration 1
+		j := (idx & p.mask) / 8
+		c1 := [2]uint64{scratchpad[j], scratchpad[j+1]}
+		aesRound64(&c1, &a)
+		if p.variant >= 2 {
+			shuffleAdd(j, &c1)
+		}
+		scratchpad[j], scratchpad[j+1] = c1[0]^b[0], c1[1]^b[1]
+		if p.variant == 1 {
+			tmp := byte(scratchpad[j+1] >> 24)
+			index := (((tmp >> 3) & 6) | (tmp & 1)) << 1
+			scratchpad[j+1] ^= uint64((0x75310>>index)&0x30) << 24
+		}
+
+		// Iteration 2
+		j = (c1[0] & p.mask) / 8
+		c2 := [2]uint64{scratchpad[j], scratchpad[j+1]}
+		a1 := a
+		if p.variant == 2 {
+			c2[0] ^= divisionResult ^ (sqrtResult << 32)
+			dividend := c1[1]
+			divisor := uint64((uint32(c1[0]) + uint32(sqrtResult<<1)) | 0x80000001)
+			divisionResult = uint64(uint32(dividend/divisor)) + (dividend%divisor)<<32
+			sqrtInput := c1[0] + divisionResult
+			sqrtResult = uint64(math.Sqrt(float64(sqrtInput)+18446744073709551616.0)*2.0 - 8589934592.0)
+
+			// Fix up the rounding of the floating point square root
+			s := sqrtResult >> 1
+			bit := sqrtResult & 1
+			r2 := s*(s+bit) + sqrtResult<<32
+			if r2+bit > sqrtInput {
+				sqrtResult--
+			}
+			if r2+(1<<32) < sqrtInput-s {
+				sqrtResult++
+			}
+		}
+		if p.variant >= 4 {
+			c2[0] ^= uint64(r[0]+r[1]) | uint64(r[2]+r[3])<<32
+			r[4], r[5] = uint32(a1[0]), uint32(a1[1])
+			r[6] = uint32(b[0])
+			r[7], r[8] = uint32(b1[0]), uint32(b1[1])
+			v4Execute(code, &r)
+			a1[0] ^= uint64(r[2]) | uint64(r[3])<<32
+			a1[1] ^= uint64(r[0]) | uint64(r[1])<<32
+		}
+
+		hi, lo := bits.Mul64(c1[0], c2[0])
+		if p.variant == 2 {
+			scratchpad[j^2] ^= hi
+			scratchpad[(j^2)+1] ^= lo
+			hi ^= scratchpad[j^4]
+			lo ^= scratchpad[(j^4)+1]
+		}
+		if p.variant >= 2 {
+			shuffleAdd(j, &c1)
+		}
+		a1[0] += hi
+		a1[1] += lo
+
+		scratchpad[j], scratchpad[j+1] = a1[0], a1[1]
+		if p.variant == 1 {
+			scratchpad[j+1] ^= tweak1
+		}
+		a1[0] ^= c2[0]
+		a1[1] ^= c2[1]
+
+		if p.variant >= 2 {
+			b1 = b
+		}
+		b = c1
+		a = a1
+		idx = a[0]
+
+		if p.heavy {
+			j = (idx & p.mask) / 8
+			n := int64(scratchpad[j])
+			d := int32(scratchpad[j+1])
+			q := n / int64(d|5)
+			scratchpad[j] = uint64(n ^ q)
+			idx = uint64(int64(d) ^ q)
+		}
+	}
+
+	// Fold the scratchpad back into the state, this time keyed by its second 32 bytes
+	keys = aesExpandKey(stateBytes[32:64])
+	copy(text[:], state[8:24])
+	for i := 0; i < len(scratchpad); i += 16 {
+		for k := range text {
+			text[k] ^= scratchpad[i+k]
+		}
+		aesPseudoRounds(&text, &keys)
+		if p.heavy {
+			mixAndPropagate(&text)
+		}
+	}
+	if p.heavy {
+		for i := 0; i < len(scratchpad); i += 16 {
+			for k := range text {
+				text[k] ^= scratchpad[i+k]
+			}
+			aesPseudoRounds(&text, &keys)
+			mixAndPropagate(&text)
+		}
+		for i := 0; i < 16; i++ {
+			aesPseudoRounds(&text, &keys)
+			mixAndPropagate(&text)
+		}
+	}
+	copy(state[8:24], text[:])
+	keccakF(&state)
+
+	for i, e := range state {
+		binary.LittleEndian.PutUint64(stateBytes[i*8:], e)
+	}
+	switch state[0] & 3 {
+	case 0:
+		return blake256(stateBytes[:])
+	case 1:
+		return groestl256(stateBytes[:])
+	case 2:
+		return jh256(stateBytes[:])
+	default:
+		return skein256(stateBytes[:])
+	}
+}
@@ -0,0 +1,294 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// CryptoNight variant 4 (cn/r) replaces variant 2's integer math with a random program derived from the block height.
+// Source: variant4_random_math.h
+
+const (
+	v4TotalLatency       = 15 * 3
+	v4NumInstructionsMin = 60
+	v4NumInstructionsMax = 70
+	v4ALUCountMul        = 1
+	v4ALUCount           = 3
+
+	v4OpcodeBits   = 3
+	v4DstIndexBits = 2
+	v4SrcIndexBits = 3
+)
+
+const (
+	v4Mul = iota
+	v4Add
+	v4Sub
+	v4Ror
+	v4Rol
+	v4Xor
+	v4Ret
+	v4InstructionCount = v4Ret
+)
+
+type v4Instruction struct {
+	opcode   uint8
+	dstIndex uint8
+	srcIndex uint8
+	c        uint32
+}
+
+// v4Execute runs code over the registers, R0-R3 carry over between iterations while R4-R8 are loaded fresh each time.
+func v4Execute(code []v4Instruction, r *[9]uint32) {
+	for _, op := range code {
+		src := r[op.srcIndex]
+		dst := &r[op.dstIndex]
+		switch op.opcode {
+		case v4Mul:
+			*dst *= src
+		case v4Add:
+			*dst += src + op.c
+		case v4Sub:
+			*dst -= src
+		case v4Ror:
+			*dst = bits.RotateLeft32(*dst, -int(src%32))
+		case v4Rol:
+			*dst = bits.RotateLeft32(*dst, int(src%32))
+		case v4Xor:
+			*dst ^= src
+		case v4Ret:
+			return
+		}
+	}
+}
+
+// v4GenerateCode generates as many random math operations as possible with given latency and ALU restrictions.  The
+// program ends with a v4Ret.
+func v4GenerateCode(height uint64) []v4Instruction {
+	// MUL is 3 cycles, 3-way addition and rotations are 2 cycles, SUB/XOR are 1 cycle
+	opLatency := [v4InstructionCount]int{3, 2, 1, 2, 2, 1}
+	// Instruction latencies for theoretical ASIC implementation
+	asicOpLatency := [v4InstructionCount]int{3, 1, 1, 1, 1, 1}
+	// Available ALUs for each instruction
+	opALUs := [v4InstructionCount]int{v4ALUCountMul, v4ALUCount, v4ALUCount, v4ALUCount, v4ALUCount, v4ALUCount}
+
+	var data [32]byte
+	binary.LittleEndian.PutUint64(data[:], height)
+	data[20] = 0xda // -38, change seed
+
+	// Set dataIndex past the last byte in data to trigger a full data update with blake hash before we start using it
+	dataIndex := len(data)
+	checkData := func(bytesNeeded int) {
+		if dataIndex+bytesNeeded > len(data) {
+			data = blake256(data[:])
+			dataIndex = 0
+		}
+	}
+
+	code := make([]v4Instruction, v4NumInstructionsMax+1)
+	var codeSize int
+
+	// There is a small chance (1.8%) that register R8 won't be used in the generated program, so we keep track of it
+	// and try again if it's not used
+	r8Used := false
+	for !r8Used || codeSize < v4NumInstructionsMin || codeSize > v4NumInstructionsMax {
+		var latency, asicLatency [9]int
+
+		// Tracks previous instruction and value of the source operand for registers R0-R3 throughout code execution
+		// byte 0: current value of the destination register
+		// byte 1: instruction opcode
+		// byte 2: current value of the source register
+		//
+		// Registers R4-R8 are constant and are treated as having the same value because when we do the same
+		// operation twice with two constant source registers, it can be optimized into a single operation
+		instData := [9]uint32{0, 1, 2, 3, 0xffffff, 0xffffff, 0xffffff, 0xffffff, 0xffffff}
+
+		var aluBusy [v4TotalLatency + 1][v4ALUCount]bool
+		var isRotation [v4InstructionCount]bool
+		var rotated [4]bool
+		rotateCount := 0
+		isRotation[v4Ror] = true
+		isRotation[v4Rol] = true
+
+		numRetries := 0
+		codeSize = 0
+		totalIterations := 0
+		r8Used = false
+
+		// Generate random code to achieve minimal required latency for our abstract CPU, try to get this latency for
+		// all 4 registers
+		for (latency[0] < v4TotalLatency || latency[1] < v4TotalLatency || latency[2] < v4TotalLatency || latency[3] < v4TotalLatency) && numRetries < 64 {
+			// Fail-safe to guarantee loop termination
+			totalIterations++
+			if totalIterations > 256 {
+				break
+			}
+
+			checkData(1)
+			c := data[dataIndex]
+			dataIndex++
+
+			// MUL = opcodes 0-2
+			// ADD = opcode 3
+			// SUB = opcode 4
+			// ROR/ROL = opcode 5, shift direction is selected randomly
+			// XOR = opcodes 6-7
+			opcode := c & ((1 << v4OpcodeBits) - 1)
+			if opcode == 5 {
+				checkData(1)
+				if int8(data[dataIndex]) >= 0 {
+					opcode = v4Ror
+				} else {
+					opcode = v4Rol
+				}
+				dataIndex++
+			} else if opcode >= 6 {
+				opcode = v4Xor
+			} else if opcode <= 2 {
+				opcode = v4Mul
+			} else {
+				opcode -= 2
+			}
+
+			dstIndex := (c >> v4OpcodeBits) & ((1 << v4DstIndexBits) - 1)
+			srcIndex := (c >> (v4OpcodeBits + v4DstIndexBits)) & ((1 << v4SrcIndexBits) - 1)
+
+			a := int(dstIndex)
+			b := int(srcIndex)
+
+			// Don't do ADD/SUB/XOR with the same register
+			if (opcode == v4Add || opcode == v4Sub || opcode == v4Xor) && a == b {
+				// a is always < 4, so we don't need to check bounds here
+				b = 8
+				srcIndex = uint8(b)
+			}
+
+			// Don't do rotation with the same destination twice because it's equal to a single rotation
+			if isRotation[opcode] && rotated[a] {
+				continue
+			}
+
+			// Don't do the same instruction (except MUL) with the same source value twice because all other cases
+			// can be optimized:
+			// 2xADD(a, b, C) = ADD(a, b*2, C1+C2), same for SUB and rotations
+			// 2xXOR(a, b) = NOP
+			if opcode != v4Mul && instData[a]&0xffff00 == uint32(opcode)<<8+(instData[b]&255)<<16 {
+				continue
+			}
+
+			// Find which ALU is available (and when) for this instruction
+			nextLatency := latency[a]
+			if latency[b] > nextLatency {
+				nextLatency = latency[b]
+			}
+			aluIndex := -1
+			for nextLatency < v4TotalLatency {
+				for i := opALUs[opcode] - 1; i >= 0; i-- {
+					if !aluBusy[nextLatency][i] {
+						// ADD is implemented as two 1-cycle instructions on a real CPU, so do an additional
+						// availability check
+						if opcode == v4Add && aluBusy[nextLatency+1][i] {
+							continue
+						}
+
+						// Rotation can only start when previous rotation is finished, so do an additional
+						// availability check
+						if isRotation[opcode] && nextLatency < rotateCount*opLatency[opcode] {
+							continue
+						}
+
+						aluIndex = i
+						break
+					}
+				}
+				if aluIndex >= 0 {
+					break
+				}
+				nextLatency++
+			}
+
+			// Don't generate instructions that leave some register unchanged for more than 7 cycles
+			if nextLatency > latency[a]+7 {
+				continue
+			}
+
+			nextLatency += opLatency[opcode]
+
+			if nextLatency <= v4TotalLatency {
+				if isRotation[opcode] {
+					rotateCount++
+				}
+
+				// Mark ALU as busy only for the first cycle when it starts executing the instruction because ALUs
+				// are fully pipelined
+				aluBusy[nextLatency-opLatency[opcode]][aluIndex] = true
+				latency[a] = nextLatency
+
+				// ASIC is supposed to have enough ALUs to run as many independent instructions per cycle as
+				// possible, so latency calculation for ASIC is simple
+				asicStart := asicLatency[a]
+				if asicLatency[b] > asicStart {
+					asicStart = asicLatency[b]
+				}
+				asicLatency[a] = asicStart + asicOpLatency[opcode]
+
+				rotated[a] = isRotation[opcode]
+
+				instData[a] = uint32(codeSize) + uint32(opcode)<<8 + (instData[b]&255)<<16
+
+				code[codeSize] = v4Instruction{opcode: opcode, dstIndex: dstIndex, srcIndex: srcIndex}
+
+				if srcIndex == 8 {
+					r8Used = true
+				}
+
+				if opcode == v4Add {
+					// ADD instruction is implemented as two 1-cycle instructions on a real CPU, so mark ALU as busy
+					// for the next cycle too
+					aluBusy[nextLatency-opLatency[opcode]+1][aluIndex] = true
+
+					// ADD instruction requires 4 more random bytes for 32-bit constant "C" in "a = a + b + C"
+					checkData(4)
+					code[codeSize].c = binary.LittleEndian.Uint32(data[dataIndex:])
+					dataIndex += 4
+				}
+
+				codeSize++
+				if codeSize >= v4NumInstructionsMin {
+					break
+				}
+			} else {
+				numRetries++
+			}
+		}
+
+		// ASIC has more execution resources and can extract as much parallelism from the code as possible.  We need
+		// to add a few more MUL and ROR instructions to achieve minimal required latency for ASIC.  Get this latency
+		// for at least 1 of the 4 registers
+		prevCodeSize := codeSize
+		for codeSize < v4NumInstructionsMax && asicLatency[0] < v4TotalLatency && asicLatency[1] < v4TotalLatency && asicLatency[2] < v4TotalLatency && asicLatency[3] < v4TotalLatency {
+			minIdx := 0
+			maxIdx := 0
+			for i := 1; i < 4; i++ {
+				if asicLatency[i] < asicLatency[minIdx] {
+					minIdx = i
+				}
+				if asicLatency[i] > asicLatency[maxIdx] {
+					maxIdx = i
+				}
+			}
+
+			pattern := [3]uint8{v4Ror, v4Mul, v4Mul}
+			opcode := pattern[(codeSize-prevCodeSize)%3]
+			latency[minIdx] = latency[maxIdx] + opLatency[opcode]
+			asicLatency[minIdx] = asicLatency[maxIdx] + asicOpLatency[opcode]
+
+			code[codeSize] = v4Instruction{opcode: opcode, dstIndex: uint8(minIdx), srcIndex: uint8(maxIdx)}
+			codeSize++
+		}
+	}
+
+	// Add final instruction to stop the interpreter
+	code[codeSize] = v4Instruction{opcode: v4Ret}
+	return code[:codeSize+1]
+}
@@ -0,0 +1,52 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestCryptonightHash(t *testing.T) {
+	// The first test input and outputs from xmrig's CryptoNight_test.h
+	xmrigInput := "0305a0dbd6bf05cf16e503f3a66f78007cbf34144332ecbfc22ed95c8700383b309ace1923a0964b00000008ba939a62724c0d7581fce5761e9d8a0e6a1c3f924fdd8493d1115649c05eb601"
+	tests := []struct {
+		name    string
+		input   string
+		variant CryptonightVariant
+		height  uint64
+		want    string
+	}{
+		{"v0 this is a test", hex.EncodeToString([]byte("This is a test")), CryptonightV0, 0, "a084f01d1437a09c6985401b60d43554ae105802c5f5d8a9b3253649c0be6605"},
+		{"v0 de omnibus dubitandum", "6465206f6d6e69627573206475626974616e64756d", CryptonightV0, 0, "2f8e3df40bd11f9ac90c743ca8e32bb391da4fb98612aa3b6cdc639ee00b31f5"},
+		{"v0 abundans cautela", "6162756e64616e732063617574656c61206e6f6e206e6f636574", CryptonightV0, 0, "722fa8ccd594d40e4a41f3822734304c8d5eff7e1b528408e2229da38ba553c4"},
+		{"v0 caveat emptor", "63617665617420656d70746f72", CryptonightV0, 0, "bbec2cacf69866a8e740380fe7b818fc78f8571221742d729d9d02d7f8989b87"},
+		{"v0 ex nihilo", "6578206e6968696c6f206e6968696c20666974", CryptonightV0, 0, "b1257de4efc5ce28c6b40ceb1c6c8f812a64634eb3e81c5220bee9b2b76a6f05"},
+		{"v0 block", xmrigInput, CryptonightV0, 0, "1a3ffbee909b420d91f7be6e5fb56db71b3110d886011e877ee5786afd080100"},
+		{"v1 block", xmrigInput, CryptonightV1, 0, "f22d3d6203d2a08b41d9027278d8bcc983acada9b68e52e3c689692a50e921d9"},
+		{"v2 block", xmrigInput, CryptonightV2, 0, "97378282cf10e7ad033f7b8074c40e14d06e7f609dddda787680b58c05f43d21"},
+		{"v2 this is a test", "5468697320697320612074657374205468697320697320612074657374205468697320697320612074657374", CryptonightV2, 0, "353fdc068fd47b03c04b9431e005e00b68c2168a3cc7335c8b9b308156591a4f"},
+		{"r this is a test", "5468697320697320612074657374205468697320697320612074657374205468697320697320612074657374", CryptonightR, 1806260, "f759588ad57e758467295443a9bd71490abff8e9dad1b95b6bf2f5d0d78387bc"},
+		{"lite v0 block", xmrigInput, CryptonightLiteV0, 0, "3695b4b53bb00358b0ad38dc160feb9e004eece09b83a72ef6ba9864d3510c88"},
+		{"lite v1 block", xmrigInput, CryptonightLiteV1, 0, "6d8cdc444e9bbbfd68fc43fcd4855b228c8a1bd91d9d00285bec02b7ca2d6741"},
+		{"heavy block", xmrigInput, CryptonightHeavy, 0, "9983f21bdf2010a8d707bb2f14d78664bbe1187f55014b39e5f3d69328e48fc2"},
+		{"pico block", xmrigInput, CryptonightPico, 0, "08f421d7833117300eda66e98f4a2569093df300500173944efc401e9a4a17af"},
+	}
+	for _, tt := range tests {
+		input, _ := hex.DecodeString(tt.input)
+		result, err := CryptonightHash(input, tt.variant, tt.height)
+		if err != nil {
+			t.Fatalf("%v: %v", tt.name, err)
+		}
+		if hex.EncodeToString(result[:]) != tt.want {
+			t.Errorf("%v: expected %v, received %v", tt.name, tt.want, hex.EncodeToString(result[:]))
+		}
+	}
+}
+
+func TestCryptonightHashErrors(t *testing.T) {
+	if _, err := CryptonightHash([]byte("This is a test"), CryptonightV1, 0); err != InvalidCryptonightInput {
+		t.Fatalf("Expected a short input error for variant 1, received %v", err)
+	}
+	if _, err := CryptonightHash([]byte("This is a test"), CryptonightVariant(100), 0); err != InvalidCryptonightVariant {
+		t.Fatalf("Expected an unknown variant error, received %v", err)
+	}
+}
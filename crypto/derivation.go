@@ -47,3 +47,30 @@ func deriveSecretKey(derivation []byte, outputIndex uint64, secret *[32]byte) (d
 	scAdd(derivedKey, secret, scalar)
 	return derivedKey, nil
 }
+
+// GenerateKeyDerivation returns the shared secret 8 * secret * public.  Senders use the transaction secret key and the
+// recipient's view public key, recipients their view secret key and the transaction public key.
+func GenerateKeyDerivation(public, secret *[32]byte) (*[32]byte, error) {
+	return generateKeyDerivation(public, secret)
+}
+
+// DerivePublicKey returns the one time public key for output outputIndex paying to the spend key public.
+func DerivePublicKey(derivation *[32]byte, outputIndex uint64, public *[32]byte) (*[32]byte, error) {
+	return derivePublicKey(derivation[:], outputIndex, public)
+}
+
+// DeriveSecretKey returns the secret key for the one time key DerivePublicKey returns, given the spend secret key.
+func DeriveSecretKey(derivation *[32]byte, outputIndex uint64, secret *[32]byte) (*[32]byte, error) {
+	return deriveSecretKey(derivation[:], outputIndex, secret)
+}
+
+// DeriveViewTag returns the view tag for output outputIndex, the first byte of a hash of the derivation.  Original:
+// crypto_ops::derive_view_tag in crypto.cpp
+func DeriveViewTag(derivation *[32]byte, outputIndex uint64) byte {
+	buf := make([]byte, 8+32+binary.MaxVarintLen64)
+	copy(buf, "view_tag")
+	copy(buf[8:], derivation[:])
+	n := binary.PutUvarint(buf[40:], outputIndex)
+	h := KeccakOneShot(buf[:40+n])
+	return h[0]
+}
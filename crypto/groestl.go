@@ -0,0 +1,123 @@
+package crypto
+
+import "encoding/binary"
+
+// Groestl-256, one of CryptoNight's four final hashes.  The state is 8 rows by 8 columns of bytes, stored column by
+// column so that a 64 byte block maps straight onto it.
+
+const groestlRounds = 10
+
+// groestlShiftP and groestlShiftQ are how far each row is rotated left by ShiftBytes.
+var (
+	groestlShiftP = [8]int{0, 1, 2, 3, 4, 5, 6, 7}
+	groestlShiftQ = [8]int{1, 3, 5, 7, 0, 2, 4, 6}
+)
+
+// groestlMix is the first row of the circulant MixBytes matrix.
+var groestlMix = [8]byte{2, 2, 3, 4, 5, 3, 5, 7}
+
+// gfMul multiplies a and b in AES's GF(2^8).
+func gfMul(a, b byte) byte {
+	var r byte
+	for b != 0 {
+		if b&1 != 0 {
+			r ^= a
+		}
+		a = gfMul2(a)
+		b >>= 1
+	}
+	return r
+}
+
+// groestlPermute applies the P (q false) or Q (q true) permutation to the state in place.
+func groestlPermute(x *[64]byte, q bool) {
+	shift := &groestlShiftP
+	if q {
+		shift = &groestlShiftQ
+	}
+	var t [64]byte
+	for r := 0; r < groestlRounds; r++ {
+		// AddRoundConstant
+		if q {
+			for j := 0; j < 64; j++ {
+				x[j] ^= 0xff
+			}
+			for col := 0; col < 8; col++ {
+				x[col*8+7] ^= byte(col<<4) ^ byte(r)
+			}
+		} else {
+			for col := 0; col < 8; col++ {
+				x[col*8] ^= byte(col<<4) ^ byte(r)
+			}
+		}
+
+		// SubBytes and ShiftBytes
+		for col := 0; col < 8; col++ {
+			for row := 0; row < 8; row++ {
+				t[col*8+row] = aesSbox[x[((col+shift[row])%8)*8+row]]
+			}
+		}
+
+		// MixBytes
+		for col := 0; col < 8; col++ {
+			for row := 0; row < 8; row++ {
+				var v byte
+				for k := 0; k < 8; k++ {
+					v ^= gfMul(groestlMix[(k-row+8)%8], t[col*8+k])
+				}
+				x[col*8+row] = v
+			}
+		}
+	}
+}
+
+// groestlCompress computes h = P(h ^ m) ^ Q(m) ^ h.
+func groestlCompress(h *[64]byte, m []byte) {
+	var p, q [64]byte
+	for i := range p {
+		p[i] = h[i] ^ m[i]
+		q[i] = m[i]
+	}
+	groestlPermute(&p, false)
+	groestlPermute(&q, true)
+	for i := range h {
+		h[i] ^= p[i] ^ q[i]
+	}
+}
+
+// groestl256 returns the Groestl-256 hash of data.
+func groestl256(data []byte) [32]byte {
+	var h [64]byte
+	// The IV is the output size in bits
+	h[62] = 0x01
+
+	blocks := uint64(0)
+	for len(data) >= 64 {
+		groestlCompress(&h, data[:64])
+		data = data[64:]
+		blocks++
+	}
+
+	// Pad with a 1 bit, zeroes and the total number of blocks
+	var final [128]byte
+	n := copy(final[:], data)
+	final[n] = 0x80
+	size := 64
+	if n >= 56 {
+		size = 128
+	}
+	blocks += uint64(size / 64)
+	binary.BigEndian.PutUint64(final[size-8:], blocks)
+	for i := 0; i < size; i += 64 {
+		groestlCompress(&h, final[i:i+64])
+	}
+
+	// Output transformation, truncated to the last 256 bits
+	x := h
+	groestlPermute(&x, false)
+	var out [32]byte
+	for i := range out {
+		out[i] = x[32+i] ^ h[32+i]
+	}
+	return out
+}
@@ -0,0 +1,149 @@
+package crypto
+
+import "encoding/binary"
+
+// JH-256, one of CryptoNight's four final hashes.  This follows the reference implementation's 4 bit element
+// formulation of E8, which is slow but only ever runs over CryptoNight's final 200 byte state.
+
+// jhS are the two S-boxes, a bit of the round constant picks one per element.
+var jhS = [2][16]byte{
+	{9, 0, 4, 11, 13, 12, 3, 15, 1, 10, 2, 6, 7, 5, 8, 14},
+	{3, 12, 6, 13, 5, 7, 1, 9, 15, 2, 0, 4, 11, 10, 14, 8},
+}
+
+// jhRoundConstantZero is the first round constant of E8, the fractional part of sqrt(2) as 4 bit elements.
+var jhRoundConstantZero = [64]byte{
+	0x6, 0xa, 0x0, 0x9, 0xe, 0x6, 0x6, 0x7, 0xf, 0x3, 0xb, 0xc, 0xc, 0x9, 0x0, 0x8,
+	0xb, 0x2, 0xf, 0xb, 0x1, 0x3, 0x6, 0x6, 0xe, 0xa, 0x9, 0x5, 0x7, 0xd, 0x3, 0xe,
+	0x3, 0xa, 0xd, 0xe, 0xc, 0x1, 0x7, 0x5, 0x1, 0x2, 0x7, 0x7, 0x5, 0x0, 0x9, 0x9,
+	0xd, 0xa, 0x2, 0xf, 0x5, 0x9, 0x0, 0xb, 0x0, 0x6, 0x6, 0x7, 0x3, 0x2, 0x2, 0xa,
+}
+
+// jhL is the MDS layer, a multiplication in GF(2^4) mixing a pair of elements.
+func jhL(a, b *byte) {
+	*b ^= ((*a << 1) ^ (*a >> 3) ^ ((*a >> 2) & 2)) & 0xf
+	*a ^= ((*b << 1) ^ (*b >> 3) ^ ((*b >> 2) & 2)) & 0xf
+}
+
+// jhPermute applies the swap Pi, the permutation P' and the swap Phi to n elements from tem into out.
+func jhPermute(out, tem []byte) {
+	n := len(tem)
+	for i := 0; i < n; i += 4 {
+		tem[i+2], tem[i+3] = tem[i+3], tem[i+2]
+	}
+	for i := 0; i < n/2; i++ {
+		out[i] = tem[i<<1]
+		out[i+n/2] = tem[(i<<1)+1]
+	}
+	for i := n / 2; i < n; i += 2 {
+		out[i], out[i+1] = out[i+1], out[i]
+	}
+}
+
+// jhR8 is a round of E8 over the 256 elements of a.
+func jhR8(a *[256]byte, roundConstant *[64]byte) {
+	var tem [256]byte
+	for i := range tem {
+		bit := (roundConstant[i>>2] >> (3 - uint(i&3))) & 1
+		tem[i] = jhS[bit][a[i]]
+	}
+	for i := 0; i < 256; i += 2 {
+		jhL(&tem[i], &tem[i+1])
+	}
+	jhPermute(a[:], tem[:])
+}
+
+// jhUpdateRoundConstant derives the next round constant with R6, a smaller version of R8 using a constant of zero.
+func jhUpdateRoundConstant(roundConstant *[64]byte) {
+	var tem [64]byte
+	for i := range tem {
+		tem[i] = jhS[0][roundConstant[i]]
+	}
+	for i := 0; i < 64; i += 2 {
+		jhL(&tem[i], &tem[i+1])
+	}
+	jhPermute(roundConstant[:], tem[:])
+}
+
+// jhE8 is the bijective function E8 over the 1024 bit state.
+func jhE8(h *[128]byte) {
+	// Group the i-th, (i+256)-th, (i+512)-th and (i+768)-th bits of h into the i-th element, then interleave halves
+	var tem, a [256]byte
+	for i := uint(0); i < 256; i++ {
+		t0 := (h[i>>3] >> (7 - (i & 7))) & 1
+		t1 := (h[(i+256)>>3] >> (7 - (i & 7))) & 1
+		t2 := (h[(i+512)>>3] >> (7 - (i & 7))) & 1
+		t3 := (h[(i+768)>>3] >> (7 - (i & 7))) & 1
+		tem[i] = t0<<3 | t1<<2 | t2<<1 | t3
+	}
+	for i := 0; i < 128; i++ {
+		a[i<<1] = tem[i]
+		a[(i<<1)+1] = tem[i+128]
+	}
+
+	roundConstant := jhRoundConstantZero
+	for i := 0; i < 42; i++ {
+		jhR8(&a, &roundConstant)
+		jhUpdateRoundConstant(&roundConstant)
+	}
+
+	// And back again
+	for i := 0; i < 128; i++ {
+		tem[i] = a[i<<1]
+		tem[i+128] = a[(i<<1)+1]
+	}
+	*h = [128]byte{}
+	for i := uint(0); i < 256; i++ {
+		h[i>>3] |= ((tem[i] >> 3) & 1) << (7 - (i & 7))
+		h[(i+256)>>3] |= ((tem[i] >> 2) & 1) << (7 - (i & 7))
+		h[(i+512)>>3] |= ((tem[i] >> 1) & 1) << (7 - (i & 7))
+		h[(i+768)>>3] |= (tem[i] & 1) << (7 - (i & 7))
+	}
+}
+
+// jhF8 is the compression function, the block is mixed into the first half before E8 and the second half after.
+func jhF8(h *[128]byte, block []byte) {
+	for i := 0; i < 64; i++ {
+		h[i] ^= block[i]
+	}
+	jhE8(h)
+	for i := 0; i < 64; i++ {
+		h[i+64] ^= block[i]
+	}
+}
+
+// jh returns the JH hash of data with a digest of size bits, truncated from the end of the state.
+func jh(data []byte, size int) []byte {
+	var h [128]byte
+	h[0] = byte(size >> 8)
+	h[1] = byte(size)
+	jhF8(&h, make([]byte, 64))
+
+	bits := uint64(len(data)) * 8
+	for len(data) >= 64 {
+		jhF8(&h, data[:64])
+		data = data[64:]
+	}
+
+	// Pad with a 1 bit, zeroes and a 128 bit length, there's always at least one full block of padding
+	var final [128]byte
+	n := copy(final[:], data)
+	final[n] = 0x80
+	size64 := 64
+	if n > 0 {
+		size64 = 128
+	}
+	binary.BigEndian.PutUint64(final[size64-8:], bits)
+	for i := 0; i < size64; i += 64 {
+		jhF8(&h, final[i:i+64])
+	}
+
+	return append([]byte(nil), h[128-size/8:]...)
+}
+
+// jh256 returns the JH-256 hash of data.
+func jh256(data []byte) [32]byte {
+	var out [32]byte
+	copy(out[:], jh(data, 256))
+	return out
+}
@@ -0,0 +1,236 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"math"
+	"sync"
+)
+
+// RandomX, Monero's proof of work since block version 12, in light mode.  Light mode only keeps the 256 MiB cache and
+// computes dataset items as they're needed, which is far slower than mining but plenty for verifying shares.  Source:
+// https://github.com/tevador/RandomX, with Monero's configuration.
+
+const (
+	randomxArgonMemory     = 262144 // KiB
+	randomxArgonIterations = 3
+	randomxArgonSalt       = "RandomX\x03"
+	randomxCacheAccesses   = 8
+
+	// RandomXEpoch is how many blocks a RandomX key is used for.  Source: rx-slow-hash.c
+	RandomXEpoch = 2048
+	// RandomXEpochLag is how many blocks after an epoch starts its key takes over, so miners can build the new cache
+	// ahead of time.
+	RandomXEpochLag = 64
+)
+
+// RandomXSeedHeight returns the height of the block whose hash is the RandomX key for a block at height.
+// Original: rx_seedheight in rx-slow-hash.c
+func RandomXSeedHeight(height uint64) uint64 {
+	if height <= RandomXEpoch+RandomXEpochLag {
+		return 0
+	}
+	return (height - RandomXEpochLag - 1) &^ (RandomXEpoch - 1)
+}
+
+// RandomXSeedHeights returns the seed height for a block at height, and the seed height that will be used
+// RandomXEpochLag blocks later.  They differ once the next key is known, which is when templates carry a
+// next_seed_hash.  Original: rx_seedheights in rx-slow-hash.c
+func RandomXSeedHeights(height uint64) (seedHeight, nextHeight uint64) {
+	return RandomXSeedHeight(height), RandomXSeedHeight(height + RandomXEpochLag)
+}
+
+// Multipliers and additions seeding the registers of a dataset item
+const (
+	superscalarMul0 = 6364136223846793005
+	superscalarAdd1 = 9298411001130361340
+	superscalarAdd2 = 12065312585734608966
+	superscalarAdd3 = 9306329213124626780
+	superscalarAdd4 = 5281919268842080866
+	superscalarAdd5 = 10536153434571861004
+	superscalarAdd6 = 3398623926847679864
+	superscalarAdd7 = 9549104520008361294
+)
+
+// RandomXCache is the cache for a single key, along with the superscalar programs that turn it into dataset items.
+// It's read only once created, so a single cache can be shared between any number of VMs.
+type RandomXCache struct {
+	key      []byte
+	memory   []argon2Block
+	programs [randomxCacheAccesses]*superscalarProgram
+}
+
+// NewRandomXCache builds the cache for key, usually the hash of a seed block.  This takes a few seconds and 256 MiB.
+func NewRandomXCache(key []byte) *RandomXCache {
+	c := &RandomXCache{key: append([]byte(nil), key...)}
+	c.memory = argon2dFill(key, []byte(randomxArgonSalt), randomxArgonIterations, randomxArgonMemory)
+	gen := newBlake2Generator(key, 0)
+	for i := range c.programs {
+		c.programs[i] = generateSuperscalar(gen)
+	}
+	return c
+}
+
+// Key returns the key the cache was built from.
+func (c *RandomXCache) Key() []byte {
+	return append([]byte(nil), c.key...)
+}
+
+// datasetItem computes the item-th 64 byte item of the dataset.
+func (c *RandomXCache) datasetItem(item uint64) [8]uint64 {
+	const cacheLines = randomxArgonMemory * argon2BlockSize / randomxCacheLineSize
+	const linesPerBlock = argon2BlockSize / randomxCacheLineSize
+
+	var r [8]uint64
+	r[0] = (item + 1) * superscalarMul0
+	r[1] = r[0] ^ superscalarAdd1
+	r[2] = r[0] ^ superscalarAdd2
+	r[3] = r[0] ^ superscalarAdd3
+	r[4] = r[0] ^ superscalarAdd4
+	r[5] = r[0] ^ superscalarAdd5
+	r[6] = r[0] ^ superscalarAdd6
+	r[7] = r[0] ^ superscalarAdd7
+
+	registerValue := item
+	for _, prog := range c.programs {
+		line := registerValue & (cacheLines - 1)
+		mix := c.memory[line/linesPerBlock][(line%linesPerBlock)*8:]
+		prog.execute(&r)
+		for i := range r {
+			r[i] ^= mix[i]
+		}
+		registerValue = r[prog.addressRegister]
+	}
+	return r
+}
+
+// RandomXVM hashes with a cache.  A VM holds a 2 MiB scratchpad and isn't safe for concurrent use, so keep one per
+// goroutine and reuse it.
+type RandomXVM struct {
+	cache        *RandomXCache
+	scratchpad   []uint64
+	reg          randomxRegisters
+	zero         uint64
+	eMask        [2]uint64
+	roundingMode int
+	bytecode     [randomxProgramSize]randomxOp
+}
+
+// NewRandomXVM returns a VM hashing with cache.
+func NewRandomXVM(cache *RandomXCache) *RandomXVM {
+	return &RandomXVM{
+		cache:      cache,
+		scratchpad: make([]uint64, randomxScratchpadL3/8),
+	}
+}
+
+// SetCache switches the VM to another cache, for when the key changes.
+func (vm *RandomXVM) SetCache(cache *RandomXCache) {
+	vm.cache = cache
+}
+
+// Cache returns the cache the VM hashes with.
+func (vm *RandomXVM) Cache() *RandomXCache {
+	return vm.cache
+}
+
+// Hash returns the RandomX hash of input.
+func (vm *RandomXVM) Hash(input []byte) [32]byte {
+	var seed [8]uint64
+	loadWords(seed[:], blake2b(input, 64))
+
+	fillAES1Rx4(&seed, vm.scratchpad)
+	vm.roundingMode = roundToNearest
+
+	// Each program is seeded by the register file of the one before
+	for i := 0; i < randomxProgramCount-1; i++ {
+		vm.run(&seed)
+		loadWords(seed[:], blake2b(vm.reg.bytes(), 64))
+	}
+	vm.run(&seed)
+
+	fingerprint := hashAES1Rx4(vm.scratchpad)
+	for i := range vm.reg.a {
+		vm.reg.a[i] = [2]float64{math.Float64frombits(fingerprint[2*i]), math.Float64frombits(fingerprint[2*i+1])}
+	}
+
+	var hash [32]byte
+	copy(hash[:], blake2b(vm.reg.bytes(), 32))
+	return hash
+}
+
+// loadWords reads b into little endian words.
+func loadWords(w []uint64, b []byte) {
+	for i := range w {
+		w[i] = binary.LittleEndian.Uint64(b[i*8:])
+	}
+}
+
+// RandomXHash returns the RandomX hash of input with key.  Building the cache is expensive, use a RandomXCacheManager
+// or keep a RandomXVM around when hashing more than once.
+func RandomXHash(key, input []byte) [32]byte {
+	return NewRandomXVM(NewRandomXCache(key)).Hash(input)
+}
+
+// RandomXCacheManager hands out caches by seed hash.  The key changes every RandomXEpoch blocks, and shares for the
+// old key keep arriving for a little while after, so the manager keeps the caches for the two most recent seed
+// hashes.  It's safe for concurrent use, and a cache is only ever built once however many goroutines ask for it.
+type RandomXCacheManager struct {
+	mu       sync.Mutex
+	entries  []*randomxCacheEntry // Most recently added first
+	newCache func(key []byte) *RandomXCache
+}
+
+type randomxCacheEntry struct {
+	seedHash [32]byte
+	once     sync.Once
+	cache    *RandomXCache
+}
+
+// randomxCachesKept is how many caches the manager holds on to.
+const randomxCachesKept = 2
+
+// NewRandomXCacheManager returns an empty RandomXCacheManager.
+func NewRandomXCacheManager() *RandomXCacheManager {
+	return &RandomXCacheManager{newCache: NewRandomXCache}
+}
+
+// Cache returns the cache for seedHash, building it if needed and dropping the oldest cache if there are too many.
+func (m *RandomXCacheManager) Cache(seedHash [32]byte) *RandomXCache {
+	m.mu.Lock()
+	var entry *randomxCacheEntry
+	for _, e := range m.entries {
+		if e.seedHash == seedHash {
+			entry = e
+			break
+		}
+	}
+	if entry == nil {
+		entry = &randomxCacheEntry{seedHash: seedHash}
+		m.entries = append([]*randomxCacheEntry{entry}, m.entries...)
+		if len(m.entries) > randomxCachesKept {
+			m.entries = m.entries[:randomxCachesKept]
+		}
+	}
+	m.mu.Unlock()
+
+	entry.once.Do(func() {
+		entry.cache = m.newCache(seedHash[:])
+	})
+	return entry.cache
+}
+
+// VM returns vm switched to the cache for seedHash, or a new VM if vm is nil.
+func (m *RandomXCacheManager) VM(vm *RandomXVM, seedHash [32]byte) *RandomXVM {
+	cache := m.Cache(seedHash)
+	if vm == nil {
+		return NewRandomXVM(cache)
+	}
+	vm.SetCache(cache)
+	return vm
+}
+
+// Hash returns the RandomX hash of input with the key seedHash.  This uses a throwaway VM, callers hashing often
+// should keep their own with VM.
+func (m *RandomXCacheManager) Hash(seedHash [32]byte, input []byte) [32]byte {
+	return m.VM(nil, seedHash).Hash(input)
+}
@@ -0,0 +1,102 @@
+package crypto
+
+// RandomX's AES based generators and hash, used to fill the scratchpad, generate programs and fingerprint the
+// scratchpad at the end.  Source: aes_hash.cpp
+
+// AES states and keys are held as little endian words, so these are the daemon's _mm_set_epi32 arguments reversed.
+var (
+	aesGen1RKeys = [4][4]uint32{
+		{0x6daca553, 0x62716609, 0xdbb5552b, 0xb4f44917},
+		{0x6d7caf07, 0x846a710d, 0x1725d378, 0x0da1dc4e},
+		{0x3f1262f1, 0x9f947ec6, 0xf4c0794f, 0x3e20e345},
+		{0x6aef8135, 0xb1ba317c, 0x16314c88, 0x49169154},
+	}
+	aesGen4RKeys = [8][4]uint32{
+		{0x6421aadd, 0xd1833ddb, 0x2f546d2b, 0x99e5d23f},
+		{0xb20e3450, 0xb6913f55, 0x06f79d53, 0xa5dfcde5},
+		{0x5c3ed904, 0x515e7baf, 0x0aa4679f, 0x171c02bf},
+		{0x85623763, 0xe78f5d08, 0xcd673785, 0xd8ded291},
+		{0xb5826f73, 0xe3d6a7a6, 0x3d518b6d, 0x229effb4},
+		{0xc7566bf3, 0x9c10b3d9, 0xe9024d4e, 0xb272b7d2},
+		{0xf273c9e7, 0xf765a38b, 0x2ba9660a, 0xf63befa7},
+		{0x7a7cd609, 0x915839de, 0x0c06d1fd, 0xc0b0762d},
+	}
+	aesHash1RState = [4][4]uint32{
+		{0x92b52c0d, 0x9fa856de, 0xcc82db47, 0xd7983aad},
+		{0x338d996e, 0x15c7b798, 0xf59e125a, 0xace78057},
+		{0x6a770017, 0xae62c7d0, 0x5079506b, 0xe8a07ce4},
+		{0x630a240c, 0x07ad828d, 0x79a10005, 0x7e994948},
+	}
+	aesHash1RXKeys = [2][4]uint32{
+		{0xf6fa8389, 0x8b24949f, 0x90dc56bf, 0x06890201},
+		{0x61b263d1, 0x51f4e03c, 0xee1043c6, 0xed18f99b},
+	}
+)
+
+// loadAESState splits 64 bytes held as words into 4 AES states.
+func loadAESState(w []uint64) [4][4]uint32 {
+	var s [4][4]uint32
+	for i := range s {
+		s[i] = [4]uint32{uint32(w[2*i]), uint32(w[2*i] >> 32), uint32(w[2*i+1]), uint32(w[2*i+1] >> 32)}
+	}
+	return s
+}
+
+func storeAESState(w []uint64, s *[4][4]uint32) {
+	for i := range s {
+		w[2*i] = uint64(s[i][0]) | uint64(s[i][1])<<32
+		w[2*i+1] = uint64(s[i][2]) | uint64(s[i][3])<<32
+	}
+}
+
+// fillAES1Rx4 fills out with a single round of AES per 16 bytes, seeded with and updating state.
+func fillAES1Rx4(state *[8]uint64, out []uint64) {
+	s := loadAESState(state[:])
+	for i := 0; i < len(out); i += 8 {
+		aesDecRound(&s[0], &aesGen1RKeys[0])
+		aesRound(&s[1], &aesGen1RKeys[1])
+		aesDecRound(&s[2], &aesGen1RKeys[2])
+		aesRound(&s[3], &aesGen1RKeys[3])
+		storeAESState(out[i:i+8], &s)
+	}
+	storeAESState(state[:], &s)
+}
+
+// fillAES4Rx4 fills out with four rounds of AES per 16 bytes, seeded with state.
+func fillAES4Rx4(state *[8]uint64, out []uint64) {
+	s := loadAESState(state[:])
+	k := &aesGen4RKeys
+	for i := 0; i < len(out); i += 8 {
+		for j := 0; j < 4; j++ {
+			aesDecRound(&s[0], &k[j])
+			aesRound(&s[1], &k[j])
+			aesDecRound(&s[2], &k[j+4])
+			aesRound(&s[3], &k[j+4])
+		}
+		storeAESState(out[i:i+8], &s)
+	}
+}
+
+// hashAES1Rx4 hashes in into 64 bytes with a single round of AES per 16 bytes.
+func hashAES1Rx4(in []uint64) [8]uint64 {
+	s := aesHash1RState
+	for i := 0; i < len(in); i += 8 {
+		b := loadAESState(in[i : i+8])
+		aesRound(&s[0], &b[0])
+		aesDecRound(&s[1], &b[1])
+		aesRound(&s[2], &b[2])
+		aesDecRound(&s[3], &b[3])
+	}
+
+	// Two extra rounds to achieve full diffusion
+	for _, k := range aesHash1RXKeys {
+		aesRound(&s[0], &k)
+		aesDecRound(&s[1], &k)
+		aesRound(&s[2], &k)
+		aesDecRound(&s[3], &k)
+	}
+
+	var out [8]uint64
+	storeAESState(out[:], &s)
+	return out
+}
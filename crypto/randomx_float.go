@@ -0,0 +1,132 @@
+package crypto
+
+import "math"
+
+// RandomX switches the FPU's rounding mode at random, which Go has no way to do.  These compute each operation
+// rounded to nearest, find the sign of the rounding error exactly and step to the neighbouring double when the
+// directed mode would have rounded the other way.
+
+// Rounding modes, in the order of the x86 MXCSR rounding control field
+const (
+	roundToNearest = iota
+	roundDown
+	roundUp
+	roundToZero
+)
+
+// roundDirected rounds r, the result rounded to nearest, to mode given the sign of the exact result minus r.
+func roundDirected(r float64, errSign int, mode int) float64 {
+	switch {
+	case errSign < 0 && (mode == roundDown || (mode == roundToZero && r > 0)):
+		return math.Nextafter(r, math.Inf(-1))
+	case errSign > 0 && (mode == roundUp || (mode == roundToZero && r < 0)):
+		return math.Nextafter(r, math.Inf(1))
+	}
+	return r
+}
+
+// roundOverflow is the result of a finite operation that overflowed to r when rounding to nearest.
+func roundOverflow(r float64, mode int) float64 {
+	if r > 0 && (mode == roundDown || mode == roundToZero) {
+		return math.MaxFloat64
+	}
+	if r < 0 && (mode == roundUp || mode == roundToZero) {
+		return -math.MaxFloat64
+	}
+	return r
+}
+
+func sign(x float64) int {
+	switch {
+	case x < 0:
+		return -1
+	case x > 0:
+		return 1
+	}
+	return 0
+}
+
+// twoProductError returns a*b - p exactly, for p the product rounded to nearest, by splitting both operands into 26
+// bit halves.  The explicit conversions stop the compiler from fusing any of the multiplications.
+func twoProductError(a, b, p float64) float64 {
+	split := func(x float64) (float64, float64) {
+		c := float64(134217729 * x) // 2^27 + 1
+		hi := c - float64(c-x)
+		return hi, x - hi
+	}
+	ah, al := split(a)
+	bh, bl := split(b)
+	return float64(float64(float64(float64(ah*bh)-p)+float64(ah*bl))+float64(al*bh)) + float64(al*bl)
+}
+
+func fpAdd(a, b float64, mode int) float64 {
+	s := a + b
+	if mode == roundToNearest {
+		return s
+	}
+	if math.IsInf(s, 0) {
+		return roundOverflow(s, mode)
+	}
+	if s == 0 {
+		// An exact zero sum is -0 when rounding down, unless both operands were +0
+		if mode == roundDown && (a != 0 || b != 0 || math.Signbit(a) || math.Signbit(b)) {
+			return math.Copysign(0, -1)
+		}
+		return s
+	}
+	bb := s - a
+	err := (a - (s - bb)) + (b - bb)
+	return roundDirected(s, sign(err), mode)
+}
+
+func fpSub(a, b float64, mode int) float64 {
+	return fpAdd(a, -b, mode)
+}
+
+func fpMul(a, b float64, mode int) float64 {
+	p := a * b
+	if mode == roundToNearest || p == 0 {
+		return p
+	}
+	if math.IsInf(p, 0) {
+		return roundOverflow(p, mode)
+	}
+	// Work on the mantissas so that splitting can't overflow
+	ma, ea := math.Frexp(a)
+	mb, eb := math.Frexp(b)
+	return roundDirected(p, sign(twoProductError(ma, mb, math.Ldexp(p, -(ea+eb)))), mode)
+}
+
+func fpDiv(a, b float64, mode int) float64 {
+	q := a / b
+	if mode == roundToNearest || q == 0 {
+		return q
+	}
+	if math.IsInf(q, 0) {
+		return roundOverflow(q, mode)
+	}
+	// a/b - q has the sign of (a - q*b) / b, the subtraction is exact as q*b is within a factor of 2 of a
+	ma, ea := math.Frexp(a)
+	mb, eb := math.Frexp(b)
+	mq := math.Ldexp(q, eb-ea)
+	p := float64(mq * mb)
+	r := (ma - p) - twoProductError(mq, mb, p)
+	return roundDirected(q, sign(r)*sign(mb), mode)
+}
+
+func fpSqrt(x float64, mode int) float64 {
+	s := math.Sqrt(x)
+	if mode == roundToNearest || s == 0 || math.IsNaN(s) || math.IsInf(s, 0) {
+		return s
+	}
+	// Scale x by an even power of 2 so that the square root scales exactly
+	mx, ex := math.Frexp(x)
+	if ex%2 != 0 {
+		mx *= 2
+		ex--
+	}
+	ms := math.Ldexp(s, -ex/2)
+	p := float64(ms * ms)
+	r := (mx - p) - twoProductError(ms, ms, p)
+	return roundDirected(s, sign(r), mode)
+}
@@ -0,0 +1,632 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// RandomX's superscalar programs, which turn the cache into dataset items.  The generator simulates the decoder and
+// execution ports of a generic x86 CPU so that the program saturates them.  Source: superscalar.cpp
+
+const (
+	superscalarLatency    = 170
+	superscalarMaxSize    = 512
+	superscalarCycleMap   = superscalarLatency + 4
+	superscalarLookAhead  = 4
+	superscalarMaxThrowAw = 256
+
+	// registerNeedsDisplacement can't be the destination of IADD_RS, x86 can't encode it without a displacement
+	registerNeedsDisplacement = 5
+)
+
+// Superscalar instruction types
+const (
+	ssISUB_R = iota
+	ssIXOR_R
+	ssIADD_RS
+	ssIMUL_R
+	ssIROR_C
+	ssIADD_C7
+	ssIXOR_C7
+	ssIADD_C8
+	ssIXOR_C8
+	ssIADD_C9
+	ssIXOR_C9
+	ssIMULH_R
+	ssISMULH_R
+	ssIMUL_RCP
+	ssInvalid = -1
+)
+
+// Execution ports, a micro-op may be able to run on more than one of them
+const (
+	portNull = 0
+	portP0   = 1
+	portP1   = 2
+	portP5   = 4
+	portP01  = portP0 | portP1
+	portP05  = portP0 | portP5
+	portP015 = portP0 | portP1 | portP5
+)
+
+// macroOp is a single x86 instruction, decoded into one or two micro-ops.
+type macroOp struct {
+	size      int
+	latency   int
+	uop1      int
+	uop2      int
+	dependent bool // Depends on the previous macro-op of the same instruction
+}
+
+func (m macroOp) simple() bool     { return m.uop2 == portNull }
+func (m macroOp) eliminated() bool { return m.uop1 == portNull }
+
+var (
+	mopAddRI      = macroOp{size: 7, latency: 1, uop1: portP015}
+	mopLeaSIB     = macroOp{size: 4, latency: 1, uop1: portP01}
+	mopSubRR      = macroOp{size: 3, latency: 1, uop1: portP015}
+	mopXorRR      = macroOp{size: 3, latency: 1, uop1: portP015}
+	mopXorRI      = macroOp{size: 7, latency: 1, uop1: portP015}
+	mopImulRR     = macroOp{size: 4, latency: 3, uop1: portP1}
+	mopImulR      = macroOp{size: 3, latency: 4, uop1: portP1, uop2: portP5}
+	mopMulR       = macroOp{size: 3, latency: 4, uop1: portP1, uop2: portP5}
+	mopMovRR      = macroOp{size: 3}
+	mopMovRI64    = macroOp{size: 10, latency: 1, uop1: portP015}
+	mopRorRI      = macroOp{size: 4, latency: 1, uop1: portP05}
+	mopImulRRDeps = macroOp{size: 4, latency: 3, uop1: portP1, dependent: true}
+)
+
+// superscalarInfo describes how an instruction type decodes, and which of its macro-ops read the source, write the
+// destination and produce the result.
+type superscalarInfo struct {
+	typ      int
+	ops      []macroOp
+	resultOp int
+	dstOp    int
+	srcOp    int
+}
+
+var (
+	ssInfoISUB_R   = &superscalarInfo{typ: ssISUB_R, ops: []macroOp{mopSubRR}}
+	ssInfoIXOR_R   = &superscalarInfo{typ: ssIXOR_R, ops: []macroOp{mopXorRR}}
+	ssInfoIADD_RS  = &superscalarInfo{typ: ssIADD_RS, ops: []macroOp{mopLeaSIB}}
+	ssInfoIMUL_R   = &superscalarInfo{typ: ssIMUL_R, ops: []macroOp{mopImulRR}}
+	ssInfoIROR_C   = &superscalarInfo{typ: ssIROR_C, ops: []macroOp{mopRorRI}, srcOp: -1}
+	ssInfoIADD_C7  = &superscalarInfo{typ: ssIADD_C7, ops: []macroOp{mopAddRI}, srcOp: -1}
+	ssInfoIXOR_C7  = &superscalarInfo{typ: ssIXOR_C7, ops: []macroOp{mopXorRI}, srcOp: -1}
+	ssInfoIADD_C8  = &superscalarInfo{typ: ssIADD_C8, ops: []macroOp{mopAddRI}, srcOp: -1}
+	ssInfoIXOR_C8  = &superscalarInfo{typ: ssIXOR_C8, ops: []macroOp{mopXorRI}, srcOp: -1}
+	ssInfoIADD_C9  = &superscalarInfo{typ: ssIADD_C9, ops: []macroOp{mopAddRI}, srcOp: -1}
+	ssInfoIXOR_C9  = &superscalarInfo{typ: ssIXOR_C9, ops: []macroOp{mopXorRI}, srcOp: -1}
+	ssInfoIMULH_R  = &superscalarInfo{typ: ssIMULH_R, ops: []macroOp{mopMovRR, mopMulR, mopMovRR}, resultOp: 1, dstOp: 0, srcOp: 1}
+	ssInfoISMULH_R = &superscalarInfo{typ: ssISMULH_R, ops: []macroOp{mopMovRR, mopImulR, mopMovRR}, resultOp: 1, dstOp: 0, srcOp: 1}
+	ssInfoIMUL_RCP = &superscalarInfo{typ: ssIMUL_RCP, ops: []macroOp{mopMovRI64, mopImulRRDeps}, resultOp: 1, dstOp: 1, srcOp: -1}
+	ssInfoNOP      = &superscalarInfo{typ: ssInvalid}
+)
+
+// Candidates for each size of decoder slot
+var (
+	ssSlot3  = []*superscalarInfo{ssInfoISUB_R, ssInfoIXOR_R}
+	ssSlot3L = []*superscalarInfo{ssInfoISUB_R, ssInfoIXOR_R, ssInfoIMULH_R, ssInfoISMULH_R}
+	ssSlot4  = []*superscalarInfo{ssInfoIROR_C, ssInfoIADD_RS}
+	ssSlot7  = []*superscalarInfo{ssInfoIXOR_C7, ssInfoIADD_C7}
+	ssSlot8  = []*superscalarInfo{ssInfoIXOR_C8, ssInfoIADD_C8}
+	ssSlot9  = []*superscalarInfo{ssInfoIXOR_C9, ssInfoIADD_C9}
+)
+
+// decoderBuffer is one way the 16 bytes fetched per cycle can be split between instructions.
+type decoderBuffer struct {
+	index  int
+	counts []int
+}
+
+var (
+	decodeBuffer484     = &decoderBuffer{0, []int{4, 8, 4}}
+	decodeBuffer7333    = &decoderBuffer{1, []int{7, 3, 3, 3}}
+	decodeBuffer3733    = &decoderBuffer{2, []int{3, 7, 3, 3}}
+	decodeBuffer493     = &decoderBuffer{3, []int{4, 9, 3}}
+	decodeBuffer4444    = &decoderBuffer{4, []int{4, 4, 4, 4}}
+	decodeBuffer3310    = &decoderBuffer{5, []int{3, 3, 10}}
+	decodeBufferDefault = &decoderBuffer{-1, nil}
+
+	decodeBuffers = [4]*decoderBuffer{decodeBuffer484, decodeBuffer7333, decodeBuffer3733, decodeBuffer493}
+)
+
+// fetchNext picks the decoder buffer for the next cycle.
+func (d *decoderBuffer) fetchNext(instrType, cycle, mulCount int, gen *blake2Generator) *decoderBuffer {
+	// The full 128 bit multiplication decodes to 2 micro-ops, which needs a 3-3-10 configuration
+	if instrType == ssIMULH_R || instrType == ssISMULH_R {
+		return decodeBuffer3310
+	}
+	// Saturate the multiplication port with a 4-4-4-4 configuration when there are fewer multiplications than cycles
+	if mulCount < cycle+1 {
+		return decodeBuffer4444
+	}
+	// IMUL_RCP needs the next buffer to begin with a 4 byte slot
+	if instrType == ssIMUL_RCP {
+		if gen.getByte()&1 != 0 {
+			return decodeBuffer484
+		}
+		return decodeBuffer493
+	}
+	return decodeBuffers[gen.getByte()&3]
+}
+
+// blake2Generator is a stream of pseudo random bytes from repeatedly hashing a seed.
+type blake2Generator struct {
+	data  [64]byte
+	index int
+}
+
+func newBlake2Generator(seed []byte, nonce uint32) *blake2Generator {
+	g := &blake2Generator{index: 64}
+	copy(g.data[:60], seed)
+	binary.LittleEndian.PutUint32(g.data[60:], nonce)
+	return g
+}
+
+func (g *blake2Generator) checkData(bytesNeeded int) {
+	if g.index+bytesNeeded > len(g.data) {
+		copy(g.data[:], blake2b(g.data[:], len(g.data)))
+		g.index = 0
+	}
+}
+
+func (g *blake2Generator) getByte() byte {
+	g.checkData(1)
+	b := g.data[g.index]
+	g.index++
+	return b
+}
+
+func (g *blake2Generator) getUint32() uint32 {
+	g.checkData(4)
+	v := binary.LittleEndian.Uint32(g.data[g.index:])
+	g.index += 4
+	return v
+}
+
+// superscalarInstruction is an instruction of a superscalar program.
+type superscalarInstruction struct {
+	opcode int
+	dst    int
+	src    int
+	mod    byte
+	imm32  uint32
+}
+
+// superscalarProgram is a generated program, along with the register holding the next address into the cache.
+type superscalarProgram struct {
+	instructions    []superscalarInstruction
+	addressRegister int
+	reciprocals     []uint64 // IMUL_RCP's multipliers, in program order
+}
+
+type superscalarRegister struct {
+	latency     int
+	lastOpGroup int
+	lastOpPar   int32
+}
+
+// superscalarCandidate is the instruction being generated.
+type superscalarCandidate struct {
+	info             *superscalarInfo
+	src, dst         int
+	mod              byte
+	imm32            uint32
+	opGroup          int
+	opGroupPar       int32
+	canReuse         bool
+	groupParIsSource bool
+}
+
+func (c *superscalarCandidate) createForSlot(gen *blake2Generator, slotSize, fetchType int, isLast bool) {
+	switch slotSize {
+	case 3:
+		// The last slot can also hold the 128 bit multiplications
+		if isLast {
+			c.create(ssSlot3L[gen.getByte()&3], gen)
+		} else {
+			c.create(ssSlot3[gen.getByte()&1], gen)
+		}
+	case 4:
+		// The 4-4-4-4 buffer issues multiplications as its first 3 instructions
+		if fetchType == 4 && !isLast {
+			c.create(ssInfoIMUL_R, gen)
+		} else {
+			c.create(ssSlot4[gen.getByte()&1], gen)
+		}
+	case 7:
+		c.create(ssSlot7[gen.getByte()&1], gen)
+	case 8:
+		c.create(ssSlot8[gen.getByte()&1], gen)
+	case 9:
+		c.create(ssSlot9[gen.getByte()&1], gen)
+	case 10:
+		c.create(ssInfoIMUL_RCP, gen)
+	}
+}
+
+func (c *superscalarCandidate) create(info *superscalarInfo, gen *blake2Generator) {
+	*c = superscalarCandidate{info: info, src: -1, dst: -1}
+	switch info.typ {
+	case ssISUB_R:
+		c.opGroup = ssIADD_RS
+		c.groupParIsSource = true
+	case ssIXOR_R:
+		c.opGroup = ssIXOR_R
+		c.groupParIsSource = true
+	case ssIADD_RS:
+		c.mod = gen.getByte()
+		c.opGroup = ssIADD_RS
+		c.groupParIsSource = true
+	case ssIMUL_R:
+		c.opGroup = ssIMUL_R
+		c.groupParIsSource = true
+	case ssIROR_C:
+		for c.imm32 == 0 {
+			c.imm32 = uint32(gen.getByte() & 63)
+		}
+		c.opGroup = ssIROR_C
+		c.opGroupPar = -1
+	case ssIADD_C7, ssIADD_C8, ssIADD_C9:
+		c.imm32 = gen.getUint32()
+		c.opGroup = ssIADD_C7
+		c.opGroupPar = -1
+	case ssIXOR_C7, ssIXOR_C8, ssIXOR_C9:
+		c.imm32 = gen.getUint32()
+		c.opGroup = ssIXOR_C7
+		c.opGroupPar = -1
+	case ssIMULH_R:
+		c.canReuse = true
+		c.opGroup = ssIMULH_R
+		c.opGroupPar = int32(gen.getUint32())
+	case ssISMULH_R:
+		c.canReuse = true
+		c.opGroup = ssISMULH_R
+		c.opGroupPar = int32(gen.getUint32())
+	case ssIMUL_RCP:
+		c.imm32 = gen.getUint32()
+		for isZeroOrPowerOf2(c.imm32) {
+			c.imm32 = gen.getUint32()
+		}
+		c.opGroup = ssIMUL_RCP
+		c.opGroupPar = -1
+	}
+}
+
+func selectRegister(available []int, gen *blake2Generator) (int, bool) {
+	if len(available) == 0 {
+		return 0, false
+	}
+	index := 0
+	if len(available) > 1 {
+		index = int(gen.getUint32() % uint32(len(available)))
+	}
+	return available[index], true
+}
+
+func (c *superscalarCandidate) selectDestination(cycle int, allowChainedMul bool, registers *[8]superscalarRegister, gen *blake2Generator) bool {
+	var available []int
+	for i := range registers {
+		r := &registers[i]
+		if r.latency <= cycle &&
+			(c.canReuse || i != c.src) &&
+			(allowChainedMul || c.opGroup != ssIMUL_R || r.lastOpGroup != ssIMUL_R) &&
+			(r.lastOpGroup != c.opGroup || r.lastOpPar != c.opGroupPar) &&
+			(c.info.typ != ssIADD_RS || i != registerNeedsDisplacement) {
+			available = append(available, i)
+		}
+	}
+	dst, ok := selectRegister(available, gen)
+	if ok {
+		c.dst = dst
+	}
+	return ok
+}
+
+func (c *superscalarCandidate) selectSource(cycle int, registers *[8]superscalarRegister, gen *blake2Generator) bool {
+	var available []int
+	for i := range registers {
+		if registers[i].latency <= cycle {
+			available = append(available, i)
+		}
+	}
+	// With only 2 registers available for IADD_RS, one of them r5, r5 has to be the source
+	if len(available) == 2 && c.info.typ == ssIADD_RS {
+		if available[0] == registerNeedsDisplacement || available[1] == registerNeedsDisplacement {
+			c.src = registerNeedsDisplacement
+			c.opGroupPar = registerNeedsDisplacement
+			return true
+		}
+	}
+	src, ok := selectRegister(available, gen)
+	if ok {
+		c.src = src
+		if c.groupParIsSource {
+			c.opGroupPar = int32(src)
+		}
+	}
+	return ok
+}
+
+func isZeroOrPowerOf2(x uint32) bool {
+	return x&(x-1) == 0
+}
+
+func isMultiplication(typ int) bool {
+	return typ == ssIMUL_R || typ == ssIMULH_R || typ == ssISMULH_R || typ == ssIMUL_RCP
+}
+
+// scheduleUop finds the first cycle from cycle on with a free port for the micro-op, trying P5, then P0 and then P1
+// so that the multiplication port isn't filled with instructions that could go anywhere.
+func scheduleUop(uop int, portBusy *[superscalarCycleMap][3]int, cycle int, commit bool) int {
+	for ; cycle < superscalarCycleMap; cycle++ {
+		if uop&portP5 != 0 && portBusy[cycle][2] == 0 {
+			if commit {
+				portBusy[cycle][2] = uop
+			}
+			return cycle
+		}
+		if uop&portP0 != 0 && portBusy[cycle][0] == 0 {
+			if commit {
+				portBusy[cycle][0] = uop
+			}
+			return cycle
+		}
+		if uop&portP1 != 0 && portBusy[cycle][1] == 0 {
+			if commit {
+				portBusy[cycle][1] = uop
+			}
+			return cycle
+		}
+	}
+	return -1
+}
+
+// scheduleMop finds the earliest cycle the macro-op can execute, or -1 if the ports are saturated.
+func scheduleMop(mop macroOp, portBusy *[superscalarCycleMap][3]int, cycle, depCycle int, commit bool) int {
+	if mop.dependent && depCycle > cycle {
+		cycle = depCycle
+	}
+	if mop.eliminated() {
+		return cycle
+	}
+	if mop.simple() {
+		return scheduleUop(mop.uop1, portBusy, cycle, commit)
+	}
+	// Macro-ops with 2 micro-ops are scheduled conservatively, both have to execute in the same cycle
+	for ; cycle < superscalarCycleMap; cycle++ {
+		cycle1 := scheduleUop(mop.uop1, portBusy, cycle, false)
+		cycle2 := scheduleUop(mop.uop2, portBusy, cycle, false)
+		if cycle1 >= 0 && cycle1 == cycle2 {
+			if commit {
+				scheduleUop(mop.uop1, portBusy, cycle1, true)
+				scheduleUop(mop.uop2, portBusy, cycle2, true)
+			}
+			return cycle1
+		}
+	}
+	return -1
+}
+
+// generateSuperscalar generates a program from gen, decoding until an execution port is saturated.
+func generateSuperscalar(gen *blake2Generator) *superscalarProgram {
+	var portBusy [superscalarCycleMap][3]int
+	var registers [8]superscalarRegister
+	for i := range registers {
+		registers[i].lastOpGroup = ssInvalid
+		registers[i].lastOpPar = -1
+	}
+
+	prog := &superscalarProgram{}
+	decodeBuffer := decodeBufferDefault
+	current := superscalarCandidate{info: ssInfoNOP, src: -1, dst: -1}
+	macroOpIndex := 0
+	cycle := 0
+	depCycle := 0
+	portsSaturated := false
+	mulCount := 0
+	throwAwayCount := 0
+
+	for decodeCycle := 0; decodeCycle < superscalarLatency && !portsSaturated && len(prog.instructions) < superscalarMaxSize; decodeCycle++ {
+		decodeBuffer = decodeBuffer.fetchNext(current.info.typ, decodeCycle, mulCount, gen)
+
+		// Fill every slot of the buffer
+		bufferIndex := 0
+		for bufferIndex < len(decodeBuffer.counts) {
+			topCycle := cycle
+
+			// Once every macro-op of the instruction is issued, create a new one that fits in the current slot
+			if macroOpIndex >= len(current.info.ops) {
+				if portsSaturated || len(prog.instructions) >= superscalarMaxSize {
+					break
+				}
+				current.createForSlot(gen, decodeBuffer.counts[bufferIndex], decodeBuffer.index, len(decodeBuffer.counts) == bufferIndex+1)
+				macroOpIndex = 0
+			}
+			mop := current.info.ops[macroOpIndex]
+
+			// The earliest cycle every micro-op of this macro-op could execute
+			scheduleCycle := scheduleMop(mop, &portBusy, cycle, depCycle, false)
+			if scheduleCycle < 0 {
+				portsSaturated = true
+				break
+			}
+
+			// Find a source register that will be ready, looking a few cycles ahead, or throw the instruction away
+			if macroOpIndex == current.info.srcOp {
+				forward := 0
+				for ; forward < superscalarLookAhead && !current.selectSource(scheduleCycle, &registers, gen); forward++ {
+					scheduleCycle++
+					cycle++
+				}
+				if forward == superscalarLookAhead {
+					if throwAwayCount < superscalarMaxThrowAw {
+						throwAwayCount++
+						macroOpIndex = len(current.info.ops)
+						continue
+					}
+					current = superscalarCandidate{info: ssInfoNOP, src: -1, dst: -1}
+					break
+				}
+			}
+			// Same for the destination
+			if macroOpIndex == current.info.dstOp {
+				forward := 0
+				for ; forward < superscalarLookAhead && !current.selectDestination(scheduleCycle, throwAwayCount > 0, &registers, gen); forward++ {
+					scheduleCycle++
+					cycle++
+				}
+				if forward == superscalarLookAhead {
+					if throwAwayCount < superscalarMaxThrowAw {
+						throwAwayCount++
+						macroOpIndex = len(current.info.ops)
+						continue
+					}
+					current = superscalarCandidate{info: ssInfoNOP, src: -1, dst: -1}
+					break
+				}
+			}
+			throwAwayCount = 0
+
+			// Schedule for real now the operands are known
+			scheduleCycle = scheduleMop(mop, &portBusy, scheduleCycle, scheduleCycle, true)
+			if scheduleCycle < 0 {
+				portsSaturated = true
+				break
+			}
+			depCycle = scheduleCycle + mop.latency
+
+			if macroOpIndex == current.info.resultOp {
+				r := &registers[current.dst]
+				r.latency = depCycle
+				r.lastOpGroup = current.opGroup
+				r.lastOpPar = current.opGroupPar
+			}
+			bufferIndex++
+			macroOpIndex++
+
+			if scheduleCycle >= superscalarLatency {
+				portsSaturated = true
+			}
+			cycle = topCycle
+
+			if macroOpIndex >= len(current.info.ops) {
+				src := current.src
+				if src < 0 {
+					src = current.dst
+				}
+				prog.instructions = append(prog.instructions, superscalarInstruction{
+					opcode: current.info.typ,
+					dst:    current.dst,
+					src:    src,
+					mod:    current.mod,
+					imm32:  current.imm32,
+				})
+				if isMultiplication(current.info.typ) {
+					mulCount++
+				}
+			}
+		}
+		cycle++
+	}
+
+	// The address register is the one with the longest dependency chain on an ASIC, which is assumed to run every
+	// operation in 1 cycle with unlimited parallelism
+	var asicLatencies [8]int
+	for _, instr := range prog.instructions {
+		latDst := asicLatencies[instr.dst] + 1
+		latSrc := 0
+		if instr.dst != instr.src {
+			latSrc = asicLatencies[instr.src] + 1
+		}
+		if latSrc > latDst {
+			latDst = latSrc
+		}
+		asicLatencies[instr.dst] = latDst
+	}
+	asicLatencyMax := 0
+	for i, e := range asicLatencies {
+		if e > asicLatencyMax {
+			asicLatencyMax = e
+			prog.addressRegister = i
+		}
+	}
+
+	for _, instr := range prog.instructions {
+		if instr.opcode == ssIMUL_RCP {
+			prog.reciprocals = append(prog.reciprocals, randomxReciprocal(uint64(instr.imm32)))
+		}
+	}
+	return prog
+}
+
+// randomxReciprocal returns 2^x / divisor for the highest x that keeps the result in 64 bits.
+func randomxReciprocal(divisor uint64) uint64 {
+	const p2exp63 = uint64(1) << 63
+	quotient, remainder := p2exp63/divisor, p2exp63%divisor
+	for shift := bits.Len64(divisor); shift > 0; shift-- {
+		if remainder >= divisor-remainder {
+			quotient = quotient*2 + 1
+			remainder = remainder*2 - divisor
+		} else {
+			quotient = quotient * 2
+			remainder = remainder * 2
+		}
+	}
+	return quotient
+}
+
+// mulh and smulh return the high 64 bits of the unsigned and signed 128 bit products.
+func mulh(a, b uint64) uint64 {
+	hi, _ := bits.Mul64(a, b)
+	return hi
+}
+
+func smulh(a, b uint64) uint64 {
+	hi, _ := bits.Mul64(a, b)
+	if int64(a) < 0 {
+		hi -= b
+	}
+	if int64(b) < 0 {
+		hi -= a
+	}
+	return hi
+}
+
+// execute runs the program over the registers.
+func (p *superscalarProgram) execute(r *[8]uint64) {
+	rcp := 0
+	for _, instr := range p.instructions {
+		dst := &r[instr.dst]
+		src := r[instr.src]
+		switch instr.opcode {
+		case ssISUB_R:
+			*dst -= src
+		case ssIXOR_R:
+			*dst ^= src
+		case ssIADD_RS:
+			*dst += src << ((instr.mod >> 2) % 4)
+		case ssIMUL_R:
+			*dst *= src
+		case ssIROR_C:
+			*dst = bits.RotateLeft64(*dst, -int(instr.imm32))
+		case ssIADD_C7, ssIADD_C8, ssIADD_C9:
+			*dst += signExtend(instr.imm32)
+		case ssIXOR_C7, ssIXOR_C8, ssIXOR_C9:
+			*dst ^= signExtend(instr.imm32)
+		case ssIMULH_R:
+			*dst = mulh(*dst, src)
+		case ssISMULH_R:
+			*dst = smulh(*dst, src)
+		case ssIMUL_RCP:
+			*dst *= p.reciprocals[rcp]
+			rcp++
+		}
+	}
+}
+
+func signExtend(imm uint32) uint64 {
+	return uint64(int64(int32(imm)))
+}
@@ -0,0 +1,152 @@
+package crypto
+
+import (
+	"encoding/hex"
+	"math"
+	"math/big"
+	"math/rand"
+	"testing"
+)
+
+func TestRandomXHash(t *testing.T) {
+	if testing.Short() {
+		t.Skip("Building RandomX caches is slow")
+	}
+	input, _ := hex.DecodeString("0b0b98bea7e805e0010a2126d287a2a0cc833d312cb786385a7c2f9de69d25537f584a9bc9977b00000000666fd8753bf61a8631f12984e3fd44f4014eca629276817b56f32e9b68bd82f416")
+	tests := []struct {
+		key   string
+		input []byte
+		want  string
+	}{
+		{"test key 000", []byte("This is a test"), "639183aae1bf4c9a35884cb46b09cad9175f04efd7684e7262a0ac1c2f0b4e3f"},
+		{"test key 000", []byte("Lorem ipsum dolor sit amet"), "300a0adb47603dedb42228ccb2b211104f4da45af709cd7547cd049e9489c969"},
+		{"test key 000", []byte("sed do eiusmod tempor incididunt ut labore et dolore magna aliqua"), "c36d4ed4191e617309867ed66a443be4075014e2b061bcdaf9ce7b721d2b77a8"},
+		{"test key 001", []byte("sed do eiusmod tempor incididunt ut labore et dolore magna aliqua"), "e9ff4503201c0c2cca26d285c93ae883f9b1d30c9eb240b820756f2d5a7905fc"},
+		{"test key 001", input, "c56414121acda1713c2f2a819d8ae38aed7c80c35c2a769298d34f03833cd5f1"},
+	}
+
+	var vm *RandomXVM
+	for _, tt := range tests {
+		if vm == nil || string(vm.Cache().Key()) != tt.key {
+			cache := NewRandomXCache([]byte(tt.key))
+			if tt.key == "test key 000" {
+				if cache.memory[0][0] != 0x191e0e1d23c02186 {
+					t.Fatalf("Cache initialisation failed, received %x", cache.memory[0][0])
+				}
+				if item := cache.datasetItem(0); item[0] != 0x680588a85ae222db {
+					t.Fatalf("Dataset item 0 is wrong, received %x", item[0])
+				}
+			}
+			if vm == nil {
+				vm = NewRandomXVM(cache)
+			} else {
+				vm.SetCache(cache)
+			}
+		}
+		result := vm.Hash(tt.input)
+		if hex.EncodeToString(result[:]) != tt.want {
+			t.Errorf("%v %q: expected %v, received %v", tt.key, tt.input, tt.want, hex.EncodeToString(result[:]))
+		}
+	}
+}
+
+func TestRandomXDirectedRounding(t *testing.T) {
+	modes := map[int]big.RoundingMode{
+		roundToNearest: big.ToNearestEven,
+		roundDown:      big.ToNegativeInf,
+		roundUp:        big.ToPositiveInf,
+		roundToZero:    big.ToZero,
+	}
+	exact := func(mode int, f func(z, x, y *big.Float) *big.Float, a, b float64) float64 {
+		z := new(big.Float).SetPrec(53).SetMode(modes[mode])
+		result, _ := f(z, new(big.Float).SetFloat64(a), new(big.Float).SetFloat64(b)).Float64()
+		return result
+	}
+	random := func(r *rand.Rand) float64 {
+		return math.Ldexp(r.Float64()+0.5, r.Intn(200)-100) * float64(1-2*r.Intn(2))
+	}
+
+	r := rand.New(rand.NewSource(1))
+	for i := 0; i < 20000; i++ {
+		a, b := random(r), random(r)
+		for mode := range modes {
+			if got, want := fpAdd(a, b, mode), exact(mode, (*big.Float).Add, a, b); got != want {
+				t.Fatalf("%v + %v in mode %v: expected %v, received %v", a, b, mode, want, got)
+			}
+			if got, want := fpSub(a, b, mode), exact(mode, (*big.Float).Sub, a, b); got != want {
+				t.Fatalf("%v - %v in mode %v: expected %v, received %v", a, b, mode, want, got)
+			}
+			if got, want := fpMul(a, b, mode), exact(mode, (*big.Float).Mul, a, b); got != want {
+				t.Fatalf("%v * %v in mode %v: expected %v, received %v", a, b, mode, want, got)
+			}
+			if got, want := fpDiv(a, b, mode), exact(mode, (*big.Float).Quo, a, b); got != want {
+				t.Fatalf("%v / %v in mode %v: expected %v, received %v", a, b, mode, want, got)
+			}
+			// Float.Sqrt is only correctly rounded to nearest, so round a more precise root instead
+			sqrt := func(z, x, _ *big.Float) *big.Float { return z.Set(new(big.Float).SetPrec(300).Sqrt(x)) }
+			if got, want := fpSqrt(math.Abs(a), mode), exact(mode, sqrt, math.Abs(a), 0); got != want {
+				t.Fatalf("sqrt(%v) in mode %v: expected %v, received %v", math.Abs(a), mode, want, got)
+			}
+		}
+	}
+
+	if result := fpSub(1.5, 1.5, roundDown); !math.Signbit(result) {
+		t.Fatalf("Expected -0 for an exact zero difference when rounding down, received %v", result)
+	}
+	if result := fpAdd(math.MaxFloat64, math.MaxFloat64, roundToZero); result != math.MaxFloat64 {
+		t.Fatalf("Expected overflow to saturate when rounding towards zero, received %v", result)
+	}
+}
+
+func TestRandomXCacheManager(t *testing.T) {
+	built := 0
+	m := NewRandomXCacheManager()
+	m.newCache = func(key []byte) *RandomXCache {
+		built++
+		return &RandomXCache{key: key}
+	}
+
+	seeds := [3][32]byte{{1}, {2}, {3}}
+	first := m.Cache(seeds[0])
+	if m.Cache(seeds[0]) != first || built != 1 {
+		t.Fatalf("Expected the cache to be reused, built %v caches", built)
+	}
+	vm := m.VM(nil, seeds[1])
+	if string(vm.Cache().Key()) != string(seeds[1][:]) {
+		t.Fatal("VM was handed the wrong cache")
+	}
+	if m.VM(vm, seeds[0]) != vm || vm.Cache() != first {
+		t.Fatal("Expected the VM to be switched to the first cache")
+	}
+
+	// A third seed pushes out the oldest
+	m.Cache(seeds[2])
+	m.Cache(seeds[1])
+	if built != 3 {
+		t.Fatalf("Expected 3 caches to be built, built %v", built)
+	}
+	if m.Cache(seeds[0]) == first || built != 4 {
+		t.Fatal("Expected the oldest cache to have been dropped")
+	}
+}
+
+func TestRandomXSeedHeights(t *testing.T) {
+	tests := []struct {
+		height, seed, next uint64
+	}{
+		{0, 0, 0},
+		{2048, 0, 0},
+		{2049, 0, 2048},
+		{2112, 0, 2048},
+		{2113, 2048, 2048},
+		{4160, 2048, 4096},
+		{4161, 4096, 4096},
+		{1978433, 1978368, 1978368}, // First RandomX block on mainnet
+	}
+	for _, tt := range tests {
+		seed, next := RandomXSeedHeights(tt.height)
+		if seed != tt.seed || next != tt.next {
+			t.Errorf("height %d: got seed heights %d, %d, expected %d, %d", tt.height, seed, next, tt.seed, tt.next)
+		}
+	}
+}
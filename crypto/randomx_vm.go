@@ -0,0 +1,471 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"math"
+	"math/bits"
+)
+
+// The RandomX virtual machine, an interpreter for its random programs.  Instructions are first compiled into a
+// bytecode with their operands resolved, like the reference interpreter.  Source: bytecode_machine.cpp,
+// vm_interpreted.cpp
+
+const (
+	randomxProgramSize       = 256
+	randomxProgramIterations = 2048
+	randomxProgramCount      = 8
+
+	randomxScratchpadL1 = 16384
+	randomxScratchpadL2 = 262144
+	randomxScratchpadL3 = 2097152
+
+	scratchpadL1Mask   = (randomxScratchpadL1 - 1) &^ 7
+	scratchpadL2Mask   = (randomxScratchpadL2 - 1) &^ 7
+	scratchpadL3Mask   = (randomxScratchpadL3 - 1) &^ 7
+	scratchpadL3Mask64 = (randomxScratchpadL3 - 1) &^ 63
+
+	randomxDatasetBaseSize  = 2147483648
+	randomxDatasetExtraSize = 33554368
+	randomxCacheLineSize    = 64
+	datasetExtraItems       = randomxDatasetExtraSize / randomxCacheLineSize
+	cacheLineAlignMask      = (randomxDatasetBaseSize - 1) &^ (randomxCacheLineSize - 1)
+
+	randomxJumpBits   = 8
+	randomxJumpOffset = 8
+	conditionMask     = (1 << randomxJumpBits) - 1
+	storeL3Condition  = 14
+
+	mantissaSize        = 52
+	exponentBias        = 1023
+	exponentMask        = (1 << 11) - 1
+	mantissaMask        = (1 << mantissaSize) - 1
+	dynamicExponentBits = 4
+	staticExponentBits  = 4
+	constExponentBits   = 0x300
+	dynamicMantissaMask = (1 << (mantissaSize + dynamicExponentBits)) - 1
+
+	fscalMask = 0x80F0000000000000
+)
+
+// Bytecode instruction types
+const (
+	rxIADD_RS = iota
+	rxIADD_M
+	rxISUB_R
+	rxISUB_M
+	rxIMUL_R
+	rxIMUL_M
+	rxIMULH_R
+	rxIMULH_M
+	rxISMULH_R
+	rxISMULH_M
+	rxIMUL_RCP
+	rxINEG_R
+	rxIXOR_R
+	rxIXOR_M
+	rxIROR_R
+	rxIROL_R
+	rxISWAP_R
+	rxFSWAP_R
+	rxFADD_R
+	rxFADD_M
+	rxFSUB_R
+	rxFSUB_M
+	rxFSCAL_R
+	rxFMUL_R
+	rxFDIV_M
+	rxFSQRT_R
+	rxCBRANCH
+	rxCFROUND
+	rxISTORE
+	rxNOP
+)
+
+// randomxFrequencies is how many of the 256 opcodes decode to each instruction type, in order.
+var randomxFrequencies = [rxNOP + 1]int{
+	16, 7, 16, 7, 16, 4, 4, 1, 4, 1, 8, // IADD_RS to IMUL_RCP
+	2, 15, 5, 8, 2, 4, 4, // INEG_R to FSWAP_R
+	16, 5, 16, 5, 6, 32, 4, 6, // FADD_R to FSQRT_R
+	25, 1, 16, 0, // CBRANCH to NOP
+}
+
+// randomxOpcodes maps each opcode to its instruction type.
+var randomxOpcodes [256]int
+
+func init() {
+	opcode := 0
+	for t, frequency := range randomxFrequencies {
+		for i := 0; i < frequency; i++ {
+			randomxOpcodes[opcode] = t
+			opcode++
+		}
+	}
+}
+
+// randomxOp is a compiled instruction, its operands are pointers straight into the register file.
+type randomxOp struct {
+	typ     int
+	idst    *uint64
+	isrc    *uint64
+	fdst    *[2]float64
+	fsrc    *[2]float64
+	imm     uint64
+	shift   uint
+	memMask uint64
+	target  int
+}
+
+// randomxRegisters is the register file, which is hashed between and after programs.
+type randomxRegisters struct {
+	r       [8]uint64
+	f, e, a [4][2]float64
+}
+
+func (rf *randomxRegisters) bytes() []byte {
+	b := make([]byte, 256)
+	for i, e := range rf.r {
+		binary.LittleEndian.PutUint64(b[i*8:], e)
+	}
+	for i, group := range [][4][2]float64{rf.f, rf.e, rf.a} {
+		for j, e := range group {
+			binary.LittleEndian.PutUint64(b[64+i*64+j*16:], math.Float64bits(e[0]))
+			binary.LittleEndian.PutUint64(b[64+i*64+j*16+8:], math.Float64bits(e[1]))
+		}
+	}
+	return b
+}
+
+func getSmallPositiveFloatBits(entropy uint64) uint64 {
+	exponent := entropy >> 59 // 0..31
+	mantissa := entropy & mantissaMask
+	exponent += exponentBias
+	exponent &= exponentMask
+	exponent <<= mantissaSize
+	return exponent | mantissa
+}
+
+func getStaticExponent(entropy uint64) uint64 {
+	exponent := uint64(constExponentBits)
+	exponent |= (entropy >> (64 - staticExponentBits)) << dynamicExponentBits
+	exponent <<= mantissaSize
+	return exponent
+}
+
+func getFloatMask(entropy uint64) uint64 {
+	const mask22bit = (1 << 22) - 1
+	return entropy&mask22bit | getStaticExponent(entropy)
+}
+
+// cvtPacked converts the two 32 bit integers of a scratchpad word into doubles.
+func cvtPacked(w uint64) [2]float64 {
+	return [2]float64{float64(int32(w)), float64(int32(w >> 32))}
+}
+
+func (vm *RandomXVM) maskExponentMantissa(x [2]float64) [2]float64 {
+	for i := range x {
+		x[i] = math.Float64frombits(math.Float64bits(x[i])&dynamicMantissaMask | vm.eMask[i])
+	}
+	return x
+}
+
+// compile resolves the operands of every instruction of the program.
+func (vm *RandomXVM) compile(program []uint64) {
+	var registerUsage [8]int
+	for i := range registerUsage {
+		registerUsage[i] = -1
+	}
+	nreg := &vm.reg
+
+	memMask := func(mod byte) uint64 {
+		if mod%4 != 0 {
+			return scratchpadL1Mask
+		}
+		return scratchpadL2Mask
+	}
+
+	for i := 0; i < randomxProgramSize; i++ {
+		instr := program[16+i]
+		opcode := byte(instr)
+		dst := int(byte(instr>>8)) % 8
+		src := int(byte(instr>>16)) % 8
+		mod := byte(instr >> 24)
+		imm32 := uint32(instr >> 32)
+
+		op := &vm.bytecode[i]
+		*op = randomxOp{typ: randomxOpcodes[opcode]}
+
+		switch op.typ {
+		case rxIADD_RS:
+			op.idst = &nreg.r[dst]
+			op.isrc = &nreg.r[src]
+			op.shift = uint((mod >> 2) % 4)
+			if dst == registerNeedsDisplacement {
+				op.imm = signExtend(imm32)
+			}
+			registerUsage[dst] = i
+
+		case rxIADD_M, rxISUB_M, rxIMUL_M, rxIMULH_M, rxISMULH_M, rxIXOR_M:
+			op.idst = &nreg.r[dst]
+			op.imm = signExtend(imm32)
+			if src != dst {
+				op.isrc = &nreg.r[src]
+				op.memMask = memMask(mod)
+			} else {
+				op.isrc = &vm.zero
+				op.memMask = scratchpadL3Mask
+			}
+			registerUsage[dst] = i
+
+		case rxISUB_R, rxIMUL_R, rxIXOR_R, rxIROR_R, rxIROL_R:
+			op.idst = &nreg.r[dst]
+			if src != dst {
+				op.isrc = &nreg.r[src]
+			} else {
+				op.imm = signExtend(imm32)
+				op.isrc = &op.imm
+			}
+			registerUsage[dst] = i
+
+		case rxIMULH_R, rxISMULH_R:
+			op.idst = &nreg.r[dst]
+			op.isrc = &nreg.r[src]
+			registerUsage[dst] = i
+
+		case rxIMUL_RCP:
+			if isZeroOrPowerOf2(imm32) {
+				op.typ = rxNOP
+				break
+			}
+			op.typ = rxIMUL_R
+			op.idst = &nreg.r[dst]
+			op.imm = randomxReciprocal(uint64(imm32))
+			op.isrc = &op.imm
+			registerUsage[dst] = i
+
+		case rxINEG_R:
+			op.idst = &nreg.r[dst]
+			registerUsage[dst] = i
+
+		case rxISWAP_R:
+			if src == dst {
+				op.typ = rxNOP
+				break
+			}
+			op.idst = &nreg.r[dst]
+			op.isrc = &nreg.r[src]
+			registerUsage[dst] = i
+			registerUsage[src] = i
+
+		case rxFSWAP_R:
+			if dst < 4 {
+				op.fdst = &nreg.f[dst]
+			} else {
+				op.fdst = &nreg.e[dst-4]
+			}
+
+		case rxFADD_R, rxFSUB_R:
+			op.fdst = &nreg.f[dst%4]
+			op.fsrc = &nreg.a[src%4]
+
+		case rxFADD_M, rxFSUB_M:
+			op.fdst = &nreg.f[dst%4]
+			op.isrc = &nreg.r[src]
+			op.memMask = memMask(mod)
+			op.imm = signExtend(imm32)
+
+		case rxFSCAL_R:
+			op.fdst = &nreg.f[dst%4]
+
+		case rxFMUL_R:
+			op.fdst = &nreg.e[dst%4]
+			op.fsrc = &nreg.a[src%4]
+
+		case rxFDIV_M:
+			op.fdst = &nreg.e[dst%4]
+			op.isrc = &nreg.r[src]
+			op.memMask = memMask(mod)
+			op.imm = signExtend(imm32)
+
+		case rxFSQRT_R:
+			op.fdst = &nreg.e[dst%4]
+
+		case rxCBRANCH:
+			// Jump back to just after the last instruction that modified the condition register, at most twice in a
+			// row as the bit below the condition mask is cleared
+			op.idst = &nreg.r[dst]
+			op.target = registerUsage[dst]
+			shift := uint(mod>>4) + randomxJumpOffset
+			op.imm = signExtend(imm32) | 1<<shift
+			op.imm &^= 1 << (shift - 1)
+			op.memMask = conditionMask << shift
+			for j := range registerUsage {
+				registerUsage[j] = i
+			}
+
+		case rxCFROUND:
+			op.isrc = &nreg.r[src]
+			op.imm = uint64(imm32 & 63)
+
+		case rxISTORE:
+			op.idst = &nreg.r[dst]
+			op.isrc = &nreg.r[src]
+			op.imm = signExtend(imm32)
+			if mod>>4 < storeL3Condition {
+				op.memMask = memMask(mod)
+			} else {
+				op.memMask = scratchpadL3Mask
+			}
+		}
+	}
+}
+
+// load returns the scratchpad word at the address held by op.
+func (vm *RandomXVM) load(op *randomxOp) uint64 {
+	return vm.scratchpad[((*op.isrc+op.imm)&op.memMask)/8]
+}
+
+// executeBytecode runs the compiled program once.
+func (vm *RandomXVM) executeBytecode() {
+	for pc := 0; pc < randomxProgramSize; pc++ {
+		op := &vm.bytecode[pc]
+		switch op.typ {
+		case rxIADD_RS:
+			*op.idst += *op.isrc<<op.shift + op.imm
+		case rxIADD_M:
+			*op.idst += vm.load(op)
+		case rxISUB_R:
+			*op.idst -= *op.isrc
+		case rxISUB_M:
+			*op.idst -= vm.load(op)
+		case rxIMUL_R:
+			*op.idst *= *op.isrc
+		case rxIMUL_M:
+			*op.idst *= vm.load(op)
+		case rxIMULH_R:
+			*op.idst = mulh(*op.idst, *op.isrc)
+		case rxIMULH_M:
+			*op.idst = mulh(*op.idst, vm.load(op))
+		case rxISMULH_R:
+			*op.idst = smulh(*op.idst, *op.isrc)
+		case rxISMULH_M:
+			*op.idst = smulh(*op.idst, vm.load(op))
+		case rxINEG_R:
+			*op.idst = -*op.idst
+		case rxIXOR_R:
+			*op.idst ^= *op.isrc
+		case rxIXOR_M:
+			*op.idst ^= vm.load(op)
+		case rxIROR_R:
+			*op.idst = bits.RotateLeft64(*op.idst, -int(*op.isrc&63))
+		case rxIROL_R:
+			*op.idst = bits.RotateLeft64(*op.idst, int(*op.isrc&63))
+		case rxISWAP_R:
+			*op.idst, *op.isrc = *op.isrc, *op.idst
+		case rxFSWAP_R:
+			op.fdst[0], op.fdst[1] = op.fdst[1], op.fdst[0]
+		case rxFADD_R:
+			op.fdst[0] = fpAdd(op.fdst[0], op.fsrc[0], vm.roundingMode)
+			op.fdst[1] = fpAdd(op.fdst[1], op.fsrc[1], vm.roundingMode)
+		case rxFADD_M:
+			src := cvtPacked(vm.load(op))
+			op.fdst[0] = fpAdd(op.fdst[0], src[0], vm.roundingMode)
+			op.fdst[1] = fpAdd(op.fdst[1], src[1], vm.roundingMode)
+		case rxFSUB_R:
+			op.fdst[0] = fpSub(op.fdst[0], op.fsrc[0], vm.roundingMode)
+			op.fdst[1] = fpSub(op.fdst[1], op.fsrc[1], vm.roundingMode)
+		case rxFSUB_M:
+			src := cvtPacked(vm.load(op))
+			op.fdst[0] = fpSub(op.fdst[0], src[0], vm.roundingMode)
+			op.fdst[1] = fpSub(op.fdst[1], src[1], vm.roundingMode)
+		case rxFSCAL_R:
+			op.fdst[0] = math.Float64frombits(math.Float64bits(op.fdst[0]) ^ fscalMask)
+			op.fdst[1] = math.Float64frombits(math.Float64bits(op.fdst[1]) ^ fscalMask)
+		case rxFMUL_R:
+			op.fdst[0] = fpMul(op.fdst[0], op.fsrc[0], vm.roundingMode)
+			op.fdst[1] = fpMul(op.fdst[1], op.fsrc[1], vm.roundingMode)
+		case rxFDIV_M:
+			src := vm.maskExponentMantissa(cvtPacked(vm.load(op)))
+			op.fdst[0] = fpDiv(op.fdst[0], src[0], vm.roundingMode)
+			op.fdst[1] = fpDiv(op.fdst[1], src[1], vm.roundingMode)
+		case rxFSQRT_R:
+			op.fdst[0] = fpSqrt(op.fdst[0], vm.roundingMode)
+			op.fdst[1] = fpSqrt(op.fdst[1], vm.roundingMode)
+		case rxCBRANCH:
+			*op.idst += op.imm
+			if *op.idst&op.memMask == 0 {
+				pc = op.target
+			}
+		case rxCFROUND:
+			vm.roundingMode = int(bits.RotateLeft64(*op.isrc, -int(op.imm)) % 4)
+		case rxISTORE:
+			vm.scratchpad[((*op.idst+op.imm)&op.memMask)/8] = *op.isrc
+		}
+	}
+}
+
+// run generates a program from seed and executes it.
+func (vm *RandomXVM) run(seed *[8]uint64) {
+	var program [16 + randomxProgramSize]uint64
+	fillAES4Rx4(seed, program[:])
+
+	// Initialise the configuration from the program's entropy
+	entropy := program[:16]
+	for i := range vm.reg.a {
+		vm.reg.a[i][0] = math.Float64frombits(getSmallPositiveFloatBits(entropy[2*i]))
+		vm.reg.a[i][1] = math.Float64frombits(getSmallPositiveFloatBits(entropy[2*i+1]))
+	}
+	ma := entropy[8] & cacheLineAlignMask
+	mx := entropy[10] & 0xffffffff
+	addressRegisters := entropy[12]
+	var readReg [4]int
+	for i := range readReg {
+		readReg[i] = 2*i + int(addressRegisters&1)
+		addressRegisters >>= 1
+	}
+	datasetOffset := (entropy[13] % (datasetExtraItems + 1)) * randomxCacheLineSize
+	vm.eMask = [2]uint64{getFloatMask(entropy[14]), getFloatMask(entropy[15])}
+	vm.reg.r = [8]uint64{}
+
+	vm.compile(program[:])
+
+	spAddr0 := mx
+	spAddr1 := ma
+	for i := 0; i < randomxProgramIterations; i++ {
+		spMix := vm.reg.r[readReg[0]] ^ vm.reg.r[readReg[1]]
+		spAddr0 = (spAddr0 ^ spMix) & scratchpadL3Mask64
+		spAddr1 = (spAddr1 ^ spMix>>32) & scratchpadL3Mask64
+
+		for j := range vm.reg.r {
+			vm.reg.r[j] ^= vm.scratchpad[spAddr0/8+uint64(j)]
+		}
+		for j := range vm.reg.f {
+			vm.reg.f[j] = cvtPacked(vm.scratchpad[spAddr1/8+uint64(j)])
+		}
+		for j := range vm.reg.e {
+			vm.reg.e[j] = vm.maskExponentMantissa(cvtPacked(vm.scratchpad[spAddr1/8+uint64(4+j)]))
+		}
+
+		vm.executeBytecode()
+
+		mx ^= vm.reg.r[readReg[2]] ^ vm.reg.r[readReg[3]]
+		mx &= cacheLineAlignMask
+		item := vm.cache.datasetItem((datasetOffset + ma) / randomxCacheLineSize)
+		for j := range vm.reg.r {
+			vm.reg.r[j] ^= item[j]
+		}
+		mx, ma = ma, mx
+
+		for j := range vm.reg.r {
+			vm.scratchpad[spAddr1/8+uint64(j)] = vm.reg.r[j]
+		}
+		for j := range vm.reg.f {
+			for k := 0; k < 2; k++ {
+				w := math.Float64bits(vm.reg.f[j][k]) ^ math.Float64bits(vm.reg.e[j][k])
+				vm.reg.f[j][k] = math.Float64frombits(w)
+				vm.scratchpad[spAddr0/8+uint64(2*j+k)] = w
+			}
+		}
+		spAddr0 = 0
+		spAddr1 = 0
+	}
+}
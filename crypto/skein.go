@@ -0,0 +1,118 @@
+package crypto
+
+import (
+	"encoding/binary"
+	"math/bits"
+)
+
+// Skein-512-256, one of CryptoNight's four final hashes.  Skein-512 with 256 bits of output, built on the Threefish-512
+// block cipher.
+
+const (
+	skeinKeyScheduleParity = 0x1bd11bdaa9fc1a22
+
+	skeinTypeConfig  = 4
+	skeinTypeMessage = 48
+	skeinTypeOutput  = 63
+
+	skeinFirst = 1 << 62
+	skeinFinal = 1 << 63
+)
+
+var threefishRotations = [8][4]int{
+	{46, 36, 19, 37}, {33, 27, 14, 42}, {17, 49, 36, 39}, {44, 9, 54, 56},
+	{39, 30, 34, 24}, {13, 50, 10, 17}, {25, 29, 39, 43}, {8, 35, 56, 22},
+}
+
+// threefish512 encrypts block with key and tweak, 72 rounds with a subkey injected every 4.
+func threefish512(key *[8]uint64, tweak [2]uint64, block *[8]uint64) {
+	var k [9]uint64
+	copy(k[:], key[:])
+	k[8] = skeinKeyScheduleParity
+	for _, e := range key {
+		k[8] ^= e
+	}
+	t := [3]uint64{tweak[0], tweak[1], tweak[0] ^ tweak[1]}
+
+	x := *block
+	injectKey := func(s int) {
+		for i := 0; i < 8; i++ {
+			x[i] += k[(s+i)%9]
+		}
+		x[5] += t[s%3]
+		x[6] += t[(s+1)%3]
+		x[7] += uint64(s)
+	}
+
+	for d := 0; d < 72; d++ {
+		if d%4 == 0 {
+			injectKey(d / 4)
+		}
+		r := &threefishRotations[d%8]
+		for j := 0; j < 4; j++ {
+			x[2*j] += x[2*j+1]
+			x[2*j+1] = bits.RotateLeft64(x[2*j+1], r[j]) ^ x[2*j]
+		}
+		// Permute the words
+		x[0], x[2], x[3], x[4], x[6], x[7] = x[2], x[4], x[7], x[6], x[0], x[3]
+	}
+	injectKey(72 / 4)
+	*block = x
+}
+
+// skeinUBI runs a single UBI block, chaining the result into h.  position is the number of bytes processed including
+// this block.
+func skeinUBI(h *[8]uint64, block []byte, blockType uint64, position uint64, flags uint64) {
+	var m [8]uint64
+	for i := range m {
+		m[i] = binary.LittleEndian.Uint64(block[i*8:])
+	}
+	x := m
+	threefish512(h, [2]uint64{position, blockType<<56 | flags}, &x)
+	for i := range h {
+		h[i] = x[i] ^ m[i]
+	}
+}
+
+// skein512 returns the Skein-512 hash of data with a digest of size bits, up to 512.
+func skein512(data []byte, size int) []byte {
+	var h [8]uint64
+
+	// Configuration block, which produces the IV
+	var cfg [64]byte
+	binary.LittleEndian.PutUint32(cfg[0:], 0x33414853) // "SHA3"
+	binary.LittleEndian.PutUint16(cfg[4:], 1)
+	binary.LittleEndian.PutUint64(cfg[8:], uint64(size))
+	skeinUBI(&h, cfg[:], skeinTypeConfig, 32, skeinFirst|skeinFinal)
+
+	// Message blocks, the last one is always processed with the final flag even if it's short or empty
+	var position uint64
+	flags := uint64(skeinFirst)
+	for len(data) > 64 {
+		position += 64
+		skeinUBI(&h, data[:64], skeinTypeMessage, position, flags)
+		data = data[64:]
+		flags = 0
+	}
+	var last [64]byte
+	copy(last[:], data)
+	position += uint64(len(data))
+	skeinUBI(&h, last[:], skeinTypeMessage, position, flags|skeinFinal)
+
+	// Output block
+	var counter [64]byte
+	skeinUBI(&h, counter[:], skeinTypeOutput, 8, skeinFirst|skeinFinal)
+
+	out := make([]byte, 64)
+	for i, e := range h {
+		binary.LittleEndian.PutUint64(out[i*8:], e)
+	}
+	return out[:size/8]
+}
+
+// skein256 returns the Skein-512-256 hash of data.
+func skein256(data []byte) [32]byte {
+	var out [32]byte
+	copy(out[:], skein512(data, 256))
+	return out
+}
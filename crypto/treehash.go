@@ -1,52 +1,169 @@
 package crypto
 
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// TreeHash returns the merkle root of hs the way the daemon builds it for a block's transactions.  Original:
+// tree_hash in tree-hash.c
 func TreeHash(hs [][32]byte) [32]byte {
-	hash := [32]byte{}
-	count := uint64(len(hs))
-	if len(hs) == 1 {
+	var th TreeHasher
+	return th.Hash(hs)
+}
+
+// TreeHasher works out TreeHash roots, reusing its scratch space from one call to the next so that rehashing a block
+// template's transactions doesn't allocate.  It also keeps the branch of the first leaf, the miner transaction, so a
+// new miner transaction hash only costs a hash per level with UpdateFirst.  A TreeHasher isn't safe for concurrent use.
+type TreeHasher struct {
+	ints   [][32]byte // The level being hashed
+	branch [][32]byte // Siblings of the first leaf, bottom up
+}
+
+// Hash returns the TreeHash root of hs, which it doesn't modify or keep.
+func (th *TreeHasher) Hash(hs [][32]byte) [32]byte {
+	th.branch = th.branch[:0]
+	switch len(hs) {
+	case 0:
+		return [32]byte{}
+	case 1:
 		return hs[0]
-	} else if len(hs) == 2 {
-		h := NewHash() // Hash Object
-		h.Write(hs[0][:])
-		h.Write(hs[1][:])
-		var tempHash []byte
-		tempHash = h.Sum(tempHash)
-		copy(hash[:], tempHash[0:32])
-	} else {
-		// Borrowed from the tree-hash.c inmplenm
-		var i, j uint64
-		var cnt uint64 = count - 1
-		var ints [][32]byte
-		for i = 1; i < 8<<3; i <<= 1 {
-			cnt |= cnt >> i
+	}
+
+	// The first level pairs up just enough of the leaves to leave a power of two, the rest pass through
+	cnt := 1
+	for cnt*2 < len(hs) {
+		cnt *= 2
+	}
+	if cap(th.ints) < cnt {
+		th.ints = make([][32]byte, cnt)
+	}
+	ints := th.ints[:cnt]
+	passthrough := 2*cnt - len(hs)
+	copy(ints, hs[:passthrough])
+	if passthrough == 0 {
+		th.branch = append(th.branch, hs[1])
+	}
+	for i, j := passthrough, passthrough; j < cnt; i, j = i+2, j+1 {
+		keccakPair(&ints[j], &hs[i], &hs[i+1])
+	}
+
+	// Then each level is hashed in place into the first half of the one below
+	for ; cnt > 1; cnt >>= 1 {
+		th.branch = append(th.branch, ints[1])
+		for i, j := 0, 0; j < cnt/2; i, j = i+2, j+1 {
+			keccakPair(&ints[j], &ints[i], &ints[i+1])
 		}
-		cnt &= ^(cnt >> 1)
-		ints = make([][32]byte, cnt)
-		for c, e := range hs {
-			if uint64(c) >= 2*cnt-count {
-				break
-			}
-			ints[c] = e
+	}
+	return ints[0]
+}
+
+// UpdateFirst returns the root the hashes last passed to Hash would have with their first leaf replaced by leaf.
+// Only the first leaf's path is rehashed, the other leaves are taken to be unchanged.  UpdateFirst doesn't change the
+// TreeHasher, so it can be called concurrently between calls to Hash.
+func (th *TreeHasher) UpdateFirst(leaf [32]byte) [32]byte {
+	hash := leaf
+	for i := range th.branch {
+		keccakPair(&hash, &hash, &th.branch[i])
+	}
+	return hash
+}
+
+// keccakPair sets dst to the Keccak hash of left followed by right.  The 64 bytes fit in a single block, so they're
+// absorbed straight into the state without a digest or any allocation.  dst may be left or right.
+func keccakPair(dst, left, right *[32]byte) {
+	var a [numLanes]uint64
+	for i := 0; i < 4; i++ {
+		a[i] = binary.LittleEndian.Uint64(left[i*laneSize:])
+		a[i+4] = binary.LittleEndian.Uint64(right[i*laneSize:])
+	}
+	// Keccak padding, a 1 bit after the input and another at the end of the 136 byte rate
+	a[8] ^= 0x01
+	a[(stateSize-2*32)/laneSize-1] ^= 0x8000000000000000
+	keccakF(&a)
+	for i := 0; i < 4; i++ {
+		binary.LittleEndian.PutUint64(dst[i*laneSize:], a[i])
+	}
+}
+
+var (
+	InvalidTreeIndex = errors.New("leaf index is outside of the tree")
+)
+
+// TreeBranch returns the sibling hashes needed to get from hashes[index] to the TreeHash root, root first, and the
+// path to the leaf, where bit i (least significant first in each byte) is set if the leaf is on the right at level i
+// from the root.  For the miner transaction at index 0 the path is always zero.  Original: tree_branch in
+// tree-hash.c, which only handles index 0
+func TreeBranch(hashes [][32]byte, index int) ([][32]byte, []byte, error) {
+	if index < 0 || index >= len(hashes) {
+		return nil, nil, InvalidTreeIndex
+	}
+	if len(hashes) == 1 {
+		return nil, nil, nil
+	}
+
+	// Bottom up to start with, reversed at the end
+	var branch [][32]byte
+	var right []bool
+
+	// Like TreeHash, the first level pairs up just enough of the leaves to leave a power of two
+	cnt := 1
+	for cnt*2 < len(hashes) {
+		cnt *= 2
+	}
+	passthrough := 2*cnt - len(hashes)
+	level := make([][32]byte, cnt)
+	copy(level, hashes[:passthrough])
+	for i, j := passthrough, passthrough; j < cnt; i, j = i+2, j+1 {
+		level[j] = hashPair(hashes[i], hashes[i+1])
+	}
+	pos := index
+	if index >= passthrough {
+		sibling := passthrough + ((index - passthrough) ^ 1)
+		branch = append(branch, hashes[sibling])
+		right = append(right, sibling < index)
+		pos = passthrough + (index-passthrough)/2
+	}
+
+	for len(level) > 1 {
+		branch = append(branch, level[pos^1])
+		right = append(right, pos&1 == 1)
+		for j := range level[:len(level)/2] {
+			level[j] = hashPair(level[2*j], level[2*j+1])
 		}
-		for i, j = 2*cnt-count, 2*cnt-count; j < cnt; i, j = i+2, j+1 {
-			// cn_fast_hash(hashes[i], 2 * HASH_SIZE, ints[j]);
-			// Input, Length, Result
-			// Resut = KeccakOneShot(Input)
-			// These are doubles, not singles.
-			intHash := append(hs[i][:], hs[i+1][:]...)
-			ints[j] = KeccakOneShot(intHash)
+		level = level[:len(level)/2]
+		pos >>= 1
+	}
+
+	path := make([]byte, (len(branch)+7)/8)
+	for i, j := 0, len(branch)-1; i < j; i, j = i+1, j-1 {
+		branch[i], branch[j] = branch[j], branch[i]
+		right[i], right[j] = right[j], right[i]
+	}
+	for i, r := range right {
+		if r {
+			path[i>>3] |= 1 << uint(i&7)
 		}
-		for {
-			if cnt <= 2 {
-				break
-			}
-			cnt >>= 1
-			for i, j = 0, 0; j < cnt; i, j = i+2, j+1 {
-				intHash := append(ints[i][:], ints[i+1][:]...)
-				ints[j] = KeccakOneShot(intHash)
-			}
+	}
+	return branch, path, nil
+}
+
+// TreeHashFromBranch returns the TreeHash root given a leaf, its branch and path as returned by TreeBranch.  A nil
+// path is the leftmost leaf.  Original: tree_hash_from_branch in tree-hash.c
+func TreeHashFromBranch(branch [][32]byte, leaf [32]byte, path []byte) [32]byte {
+	hash := leaf
+	for depth := len(branch) - 1; depth >= 0; depth-- {
+		if depth>>3 < len(path) && path[depth>>3]>>uint(depth&7)&1 != 0 {
+			hash = hashPair(branch[depth], hash)
+		} else {
+			hash = hashPair(hash, branch[depth])
 		}
-		hash = KeccakOneShot(append(ints[0][:], ints[1][:]...))
 	}
 	return hash
 }
+
+func hashPair(left, right [32]byte) [32]byte {
+	var hash [32]byte
+	keccakPair(&hash, &left, &right)
+	return hash
+}
@@ -81,3 +81,109 @@ func TestTreeHash(t *testing.T) {
 		t.Fatal("Unable to hash a long object properly")
 	}
 }
+
+func TestTreeBranch(t *testing.T) {
+	var hashes [][32]byte
+	for n := 1; n <= 33; n++ {
+		hashes = append(hashes, KeccakOneShot([]byte{byte(n)}))
+		root := TreeHash(hashes)
+		for i := range hashes {
+			branch, path, err := TreeBranch(hashes, i)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if TreeHashFromBranch(branch, hashes[i], path) != root {
+				t.Fatalf("Branch for leaf %d of %d doesn't lead to the root", i, n)
+			}
+			if i == 0 && TreeHashFromBranch(branch, hashes[0], nil) != root {
+				t.Fatalf("Branch for the first of %d leaves needs a path", n)
+			}
+			if n > 1 && TreeHashFromBranch(branch, hashes[(i+1)%n], path) == root {
+				t.Fatalf("Branch for leaf %d of %d also proves leaf %d", i, n, (i+1)%n)
+			}
+		}
+	}
+	if _, _, err := TreeBranch(hashes, len(hashes)); err != InvalidTreeIndex {
+		t.Fatalf("Expected InvalidTreeIndex, got %v", err)
+	}
+}
+
+func TestTreeHasher(t *testing.T) {
+	var th TreeHasher
+	var hashes [][32]byte
+	for n := 1; n <= 70; n++ {
+		hashes = append(hashes, KeccakOneShot([]byte{byte(n)}))
+		root := th.Hash(hashes)
+
+		// Against the original pairwise KeccakOneShot
+		level := append([][32]byte(nil), hashes...)
+		for len(level) > 1 {
+			cnt := 1
+			for cnt*2 < len(level) {
+				cnt *= 2
+			}
+			next := append([][32]byte(nil), level[:2*cnt-len(level)]...)
+			for i := 2*cnt - len(level); i < len(level); i += 2 {
+				next = append(next, KeccakOneShot(append(append([]byte(nil), level[i][:]...), level[i+1][:]...)))
+			}
+			level = next
+		}
+		if root != level[0] || TreeHash(hashes) != root {
+			t.Fatalf("Wrong root for %d leaves", n)
+		}
+		if hashes[n-1] != KeccakOneShot([]byte{byte(n)}) {
+			t.Fatalf("Hashing %d leaves changed them", n)
+		}
+
+		coinbase := KeccakOneShot([]byte{0, byte(n)})
+		updated := append([][32]byte{coinbase}, hashes[1:]...)
+		if th.UpdateFirst(coinbase) != TreeHash(updated) {
+			t.Fatalf("Wrong updated root for %d leaves", n)
+		}
+	}
+
+	allocs := testing.AllocsPerRun(10, func() {
+		th.Hash(hashes)
+		th.UpdateFirst(hashes[1])
+	})
+	if allocs != 0 {
+		t.Fatalf("Rehashing allocated %v times", allocs)
+	}
+}
+
+func benchmarkLeaves(n int) [][32]byte {
+	hashes := make([][32]byte, n)
+	for i := range hashes {
+		hashes[i] = KeccakOneShot([]byte{byte(i), byte(i >> 8)})
+	}
+	return hashes
+}
+
+func BenchmarkTreeHash(b *testing.B) {
+	hashes := benchmarkLeaves(500)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		TreeHash(hashes)
+	}
+}
+
+func BenchmarkTreeHasher(b *testing.B) {
+	hashes := benchmarkLeaves(500)
+	var th TreeHasher
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		th.Hash(hashes)
+	}
+}
+
+func BenchmarkTreeHasherUpdateFirst(b *testing.B) {
+	hashes := benchmarkLeaves(500)
+	var th TreeHasher
+	th.Hash(hashes)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		hashes[0][0] = byte(i)
+		th.UpdateFirst(hashes[0])
+	}
+}
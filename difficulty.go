@@ -0,0 +1,245 @@
+package monerocnutils
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"github.com/snipa22/monerocnutils/rpc"
+	"math"
+	"math/big"
+	"math/bits"
+	"sort"
+)
+
+// Monero's difficulty adjustment parameters, other coins have their own in CoinParams.  Source: cryptonote_config.h
+const (
+	DifficultyTarget   = 120 // Seconds, from block version 2
+	DifficultyTargetV1 = 60
+	DifficultyWindow   = 720
+	DifficultyLag      = 15
+	DifficultyCut      = 60
+	// DifficultyBlocksCount is how many of the blocks before a block its difficulty is worked out from, the most
+	// recent DifficultyLag of which are ignored.
+	DifficultyBlocksCount = DifficultyWindow + DifficultyLag
+)
+
+var (
+	InvalidTarget          = errors.New("target must be 4 or 8 hex encoded, non zero bytes")
+	InvalidDifficultyInput = errors.New("timestamps and cumulative difficulties differ in length")
+	UnexpectedBlockHeaders = errors.New("node didn't return the block headers asked for")
+)
+
+var (
+	maxUint64  = new(big.Int).SetUint64(math.MaxUint64)
+	maxUint256 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 256), big.NewInt(1))
+)
+
+// CheckHash reports whether a PoW hash meets difficulty, that is whether hash * difficulty fits in 256 bits with the
+// hash read as a little endian number.  Original: check_hash_64 in difficulty.cpp
+func CheckHash(hash [32]byte, difficulty uint64) bool {
+	// Multiply from the least significant word up, any carry out of the top word means the product overflowed
+	var carry uint64
+	for i := 0; i < 4; i++ {
+		hi, lo := bits.Mul64(binary.LittleEndian.Uint64(hash[i*8:]), difficulty)
+		_, c := bits.Add64(lo, carry, 0)
+		carry = hi + c
+	}
+	return carry == 0
+}
+
+// CheckHash128 is CheckHash for the wide difficulties used since block version 11, when the difficulty no longer fits
+// in 64 bits.  Original: check_hash in difficulty.cpp
+func CheckHash128(hash [32]byte, difficulty *big.Int) bool {
+	if difficulty.Cmp(maxUint64) <= 0 {
+		return CheckHash(hash, difficulty.Uint64())
+	}
+	// Quick check, a difficulty over 64 bits needs a hash with the top word clear
+	if binary.LittleEndian.Uint64(hash[24:]) != 0 {
+		return false
+	}
+	h := new(big.Int).SetBytes(reverseBytes(hash[:]))
+	return h.Mul(h, difficulty).Cmp(maxUint256) <= 0
+}
+
+// HashDifficulty returns the highest difficulty hash meets, used to tell whether a share also solves a block.
+func HashDifficulty(hash [32]byte) *big.Int {
+	h := new(big.Int).SetBytes(reverseBytes(hash[:]))
+	if h.Sign() == 0 {
+		return new(big.Int).Set(maxUint256)
+	}
+	return h.Div(maxUint256, h)
+}
+
+// TargetHex returns the 4 byte target stratum sends miners for difficulty, the top 32 bits of (2^256-1) / difficulty in
+// little endian hex.  A difficulty of 0 is treated as 1, and from 2^32 up the target can't go below 1, use TargetHex64
+// there instead.
+func TargetHex(difficulty uint64) string {
+	t := uint32(target64(difficulty) >> 32)
+	if t == 0 {
+		t = 1
+	}
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, t)
+	return hex.EncodeToString(b)
+}
+
+// TargetHex64 returns the 8 byte target for difficulty, which keeps its precision at difficulties too high for
+// TargetHex.  A difficulty of 0 is treated as 1.
+func TargetHex64(difficulty uint64) string {
+	b := make([]byte, 8)
+	binary.LittleEndian.PutUint64(b, target64(difficulty))
+	return hex.EncodeToString(b)
+}
+
+// TargetDifficulty returns the difficulty a 4 or 8 byte hex target stands for, the way miners read it.
+func TargetDifficulty(target string) (uint64, error) {
+	b, err := hex.DecodeString(target)
+	if err != nil {
+		return 0, InvalidTarget
+	}
+	var t uint64
+	switch len(b) {
+	case 4:
+		t32 := uint64(binary.LittleEndian.Uint32(b))
+		if t32 == 0 {
+			return 0, InvalidTarget
+		}
+		t = math.MaxUint64 / (math.MaxUint32 / t32)
+	case 8:
+		t = binary.LittleEndian.Uint64(b)
+	default:
+		return 0, InvalidTarget
+	}
+	if t == 0 {
+		return 0, InvalidTarget
+	}
+	return math.MaxUint64 / t, nil
+}
+
+// target64 is the top 64 bits of (2^256-1) / difficulty.
+func target64(difficulty uint64) uint64 {
+	if difficulty == 0 {
+		difficulty = 1
+	}
+	return math.MaxUint64 / difficulty
+}
+
+// reverseBytes returns a reversed copy of b, to read little endian hashes with big.Int.
+func reverseBytes(b []byte) []byte {
+	r := make([]byte, len(b))
+	for i := range b {
+		r[len(b)-1-i] = b[i]
+	}
+	return r
+}
+
+// NextDifficulty returns the difficulty for a block of version after the given timestamps and cumulative
+// difficulties, oldest first.  Only the first DifficultyWindow blocks are used, the daemon passes DifficultyWindow plus
+// DifficultyLag so the most recent DifficultyLag get left out.  It returns 0 if the difficulty overflows, which the
+// daemon treats as an error.  Original: next_difficulty_64 in difficulty.cpp
+func NextDifficulty(coin CoinParams, timestamps []uint64, cumulativeDifficulties []uint64, version uint8) (uint64, error) {
+	if len(timestamps) != len(cumulativeDifficulties) {
+		return 0, InvalidDifficultyInput
+	}
+	if len(timestamps) > coin.DifficultyWindow {
+		timestamps, cumulativeDifficulties = timestamps[:coin.DifficultyWindow], cumulativeDifficulties[:coin.DifficultyWindow]
+	}
+	if len(timestamps) <= 1 {
+		return 1, nil
+	}
+	cutBegin, cutEnd, timeSpan := difficultyCut(coin, timestamps)
+	totalWork := cumulativeDifficulties[cutEnd-1] - cumulativeDifficulties[cutBegin]
+
+	high, low := bits.Mul64(totalWork, coin.TargetSeconds(version))
+	if high != 0 || low+timeSpan-1 < low {
+		return 0, nil
+	}
+	return (low + timeSpan - 1) / timeSpan, nil
+}
+
+// NextDifficulty128 is NextDifficulty for wide cumulative difficulties, which the daemon has used since block version
+// 11.  It returns 0 if the difficulty doesn't fit in 128 bits.  Original: next_difficulty in difficulty.cpp
+func NextDifficulty128(coin CoinParams, timestamps []uint64, cumulativeDifficulties []*big.Int, version uint8) (*big.Int, error) {
+	if len(timestamps) != len(cumulativeDifficulties) {
+		return nil, InvalidDifficultyInput
+	}
+	if len(timestamps) > coin.DifficultyWindow {
+		timestamps, cumulativeDifficulties = timestamps[:coin.DifficultyWindow], cumulativeDifficulties[:coin.DifficultyWindow]
+	}
+	if len(timestamps) <= 1 {
+		return big.NewInt(1), nil
+	}
+	cutBegin, cutEnd, timeSpan := difficultyCut(coin, timestamps)
+
+	res := new(big.Int).Sub(cumulativeDifficulties[cutEnd-1], cumulativeDifficulties[cutBegin])
+	res.Mul(res, new(big.Int).SetUint64(coin.TargetSeconds(version)))
+	res.Add(res, new(big.Int).SetUint64(timeSpan-1))
+	res.Div(res, new(big.Int).SetUint64(timeSpan))
+	if res.BitLen() > 128 {
+		return new(big.Int), nil
+	}
+	return res, nil
+}
+
+// difficultyCut sorts a copy of timestamps and drops the DifficultyCut outliers at either end, returning the range of
+// blocks left and the time they span.  Cumulative difficulties aren't sorted along with the timestamps, the same as
+// the daemon.
+func difficultyCut(coin CoinParams, timestamps []uint64) (cutBegin, cutEnd int, timeSpan uint64) {
+	sorted := append([]uint64(nil), timestamps...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	kept := coin.DifficultyWindow - 2*coin.DifficultyCut
+	if len(sorted) <= kept {
+		cutBegin, cutEnd = 0, len(sorted)
+	} else {
+		cutBegin = (len(sorted) - kept + 1) / 2
+		cutEnd = cutBegin + kept
+	}
+	timeSpan = sorted[cutEnd-1] - sorted[cutBegin]
+	if timeSpan == 0 {
+		timeSpan = 1
+	}
+	return
+}
+
+// DifficultySource looks up block headers by height, *rpc.Node satisfies it.
+type DifficultySource interface {
+	BlockHeadersRange(start, end uint64) ([]rpc.BlockHeader, error)
+}
+
+// ExpectedDifficulty works out the difficulty of the block of version at height from the blocks before it, the way
+// the daemon does.  Original: Blockchain::get_difficulty_for_next_block
+func ExpectedDifficulty(source DifficultySource, coin CoinParams, height uint64, version uint8) (*big.Int, error) {
+	// The genesis block is never part of the window
+	start := uint64(1)
+	if blocksCount := uint64(coin.DifficultyWindow + coin.DifficultyLag); height > blocksCount {
+		start = height - blocksCount
+	}
+	if height <= start {
+		return big.NewInt(1), nil
+	}
+
+	headers, err := source.BlockHeadersRange(start, height-1)
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(headers)) != height-start {
+		return nil, UnexpectedBlockHeaders
+	}
+	timestamps := make([]uint64, len(headers))
+	cumulativeDifficulties := make([]*big.Int, len(headers))
+	for i, h := range headers {
+		if h.Height != start+uint64(i) {
+			return nil, UnexpectedBlockHeaders
+		}
+		timestamps[i] = h.Timestamp
+		cumulativeDifficulties[i] = wideDifficulty(h.CumulativeDifficultyTop, h.CumulativeDifficulty)
+	}
+	return NextDifficulty128(coin, timestamps, cumulativeDifficulties, version)
+}
+
+// wideDifficulty joins the top and low 64 bits of a difficulty.
+func wideDifficulty(top, low uint64) *big.Int {
+	d := new(big.Int).SetUint64(top)
+	return d.Lsh(d, 64).Or(d, new(big.Int).SetUint64(low))
+}
@@ -0,0 +1,254 @@
+package monerocnutils
+
+import (
+	"encoding/json"
+	"github.com/snipa22/monerocnutils/rpc"
+	"math"
+	"math/big"
+	"math/rand"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestCheckHash(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 10000; i++ {
+		var hash [32]byte
+		rng.Read(hash[:])
+		// Shrink the hash by a random number of bytes so that both outcomes turn up
+		for j := 31; j > 31-i%28; j-- {
+			hash[j] = 0
+		}
+		difficulty := rng.Uint64() >> uint(rng.Intn(64))
+
+		h := new(big.Int).SetBytes(reverseBytes(hash[:]))
+		expected := h.Mul(h, new(big.Int).SetUint64(difficulty)).Cmp(maxUint256) <= 0
+		if CheckHash(hash, difficulty) != expected {
+			t.Fatalf("CheckHash(%x, %d) should be %v", hash, difficulty, expected)
+		}
+		if CheckHash128(hash, new(big.Int).SetUint64(difficulty)) != expected {
+			t.Fatalf("CheckHash128(%x, %d) should be %v", hash, difficulty, expected)
+		}
+
+		// The hash's own difficulty is the highest it meets
+		hashDifficulty := HashDifficulty(hash)
+		if !CheckHash128(hash, hashDifficulty) || CheckHash128(hash, new(big.Int).Add(hashDifficulty, big.NewInt(1))) {
+			t.Fatalf("HashDifficulty(%x) = %v isn't the highest difficulty the hash meets", hash, hashDifficulty)
+		}
+	}
+
+	// A wide difficulty needs a hash under 2^192
+	var hash [32]byte
+	hash[23] = 0x80
+	wide := new(big.Int).Lsh(big.NewInt(1), 64)
+	if !CheckHash128(hash, wide) || CheckHash128(hash, wide.Lsh(wide, 1)) {
+		t.Fatal("CheckHash128 got the wrong answer for a wide difficulty")
+	}
+	hash[24] = 1
+	if CheckHash128(hash, new(big.Int).Lsh(big.NewInt(1), 64)) {
+		t.Fatal("CheckHash128 accepted a hash with the top word set for a wide difficulty")
+	}
+}
+
+func TestTargetHex(t *testing.T) {
+	tests := []struct {
+		difficulty uint64
+		target     string
+		target64   string
+	}{
+		{1, "ffffffff", "ffffffffffffffff"},
+		{5000, "711b0d00", "96218e75711b0d00"},
+		{120000, "cf8b0000", "10ece564cf8b0000"},
+		{1 << 31, "01000000", "ffffffff01000000"},
+	}
+	for _, tt := range tests {
+		if target := TargetHex(tt.difficulty); target != tt.target {
+			t.Errorf("TargetHex(%d) got %s, expected %s", tt.difficulty, target, tt.target)
+		}
+		if target := TargetHex64(tt.difficulty); target != tt.target64 {
+			t.Errorf("TargetHex64(%d) got %s, expected %s", tt.difficulty, target, tt.target64)
+		}
+		if d, err := TargetDifficulty(tt.target64); err != nil || d != tt.difficulty {
+			t.Errorf("TargetDifficulty(%s) got %d, %v, expected %d", tt.target64, d, err, tt.difficulty)
+		}
+	}
+	if d, err := TargetDifficulty("711b0d00"); err != nil || d != 5000 {
+		t.Errorf("TargetDifficulty(711b0d00) got %d, %v, expected 5000", d, err)
+	}
+	for _, target := range []string{"", "00000000", "0000000000000000", "ffffff", "zzzzzzzz"} {
+		if _, err := TargetDifficulty(target); err != InvalidTarget {
+			t.Errorf("TargetDifficulty(%q) expected InvalidTarget, got %v", target, err)
+		}
+	}
+	if TargetHex(1<<40) != "01000000" || TargetHex64(0) != TargetHex64(1) || target64(math.MaxUint64) != 1 {
+		t.Error("Extreme difficulties gave unexpected targets")
+	}
+}
+
+func TestNextDifficulty(t *testing.T) {
+	// Unsorted timestamps, short of a full window so nothing is cut
+	d, err := NextDifficulty(MoneroParams, []uint64{0, 100, 50}, []uint64{10, 20, 40}, 16)
+	if err != nil || d != 36 {
+		t.Fatalf("Expected 36, got %d, %v", d, err)
+	}
+	if d, _ = NextDifficulty(MoneroParams, []uint64{5}, []uint64{5}, 16); d != 1 {
+		t.Fatalf("Expected 1 for a single block, got %d", d)
+	}
+	if _, err = NextDifficulty(MoneroParams, []uint64{1, 2}, []uint64{1}, 16); err != InvalidDifficultyInput {
+		t.Fatalf("Expected InvalidDifficultyInput, got %v", err)
+	}
+	if d, _ = NextDifficulty(MoneroParams, []uint64{0, 1}, []uint64{0, math.MaxUint64}, 16); d != 0 {
+		t.Fatalf("Expected 0 on overflow, got %d", d)
+	}
+
+	// Both versions agree whenever the 64 bit one doesn't overflow
+	rng := rand.New(rand.NewSource(1))
+	for i := 0; i < 200; i++ {
+		n := 1 + rng.Intn(DifficultyBlocksCount)
+		timestamps := make([]uint64, n)
+		cumulative := make([]uint64, n)
+		wide := make([]*big.Int, n)
+		var ts, cd uint64 = 1397818193, 1
+		for j := range timestamps {
+			ts += uint64(rng.Intn(400)) - 100
+			cd += uint64(rng.Int63n(1 << 40))
+			timestamps[j], cumulative[j], wide[j] = ts, cd, new(big.Int).SetUint64(cd)
+		}
+		d, _ := NextDifficulty(MoneroParams, timestamps, cumulative, 16)
+		d128, _ := NextDifficulty128(MoneroParams, timestamps, wide, 16)
+		if !d128.IsUint64() || d128.Uint64() != d {
+			t.Fatalf("NextDifficulty %d and NextDifficulty128 %v disagree for %d blocks", d, d128, n)
+		}
+	}
+}
+
+// fakeHeaderChain is a chain with blocks every blockTime seconds, each with the previous block's difficulty plus step.
+type fakeHeaderChain struct {
+	blockTime        uint64
+	difficulty, step *big.Int
+}
+
+func (c *fakeHeaderChain) header(height uint64) rpc.BlockHeader {
+	h := rpc.BlockHeader{Height: height}
+	cumulative := new(big.Int)
+	difficulty := new(big.Int).Set(c.difficulty)
+	for i := uint64(0); i <= height; i++ {
+		h.Timestamp += c.blockTime
+		cumulative.Add(cumulative, difficulty)
+		difficulty.Add(difficulty, c.step)
+	}
+	low := new(big.Int).And(cumulative, maxUint64)
+	h.CumulativeDifficulty, h.CumulativeDifficultyTop = low.Uint64(), cumulative.Rsh(cumulative, 64).Uint64()
+	return h
+}
+
+func (c *fakeHeaderChain) BlockHeadersRange(start, end uint64) ([]rpc.BlockHeader, error) {
+	var headers []rpc.BlockHeader
+	for h := start; h <= end; h++ {
+		headers = append(headers, c.header(h))
+	}
+	return headers, nil
+}
+
+func TestExpectedDifficulty(t *testing.T) {
+	wide := new(big.Int).Lsh(big.NewInt(3), 70)
+	tests := []struct {
+		name     string
+		chain    *fakeHeaderChain
+		height   uint64
+		expected *big.Int
+	}{
+		{"steady", &fakeHeaderChain{blockTime: 120, difficulty: big.NewInt(1000), step: new(big.Int)}, 5000, big.NewInt(1000)},
+		{"fast blocks", &fakeHeaderChain{blockTime: 60, difficulty: big.NewInt(1000), step: new(big.Int)}, 5000, big.NewInt(2000)},
+		{"wide", &fakeHeaderChain{blockTime: 120, difficulty: wide, step: new(big.Int)}, 2000, wide},
+		// The window is blocks 4265 to 4984, leaving out the last DifficultyLag, and after the cut the work is that of
+		// blocks 4326 to 4924, averaging block 4625's difficulty
+		{"growing", &fakeHeaderChain{blockTime: 120, difficulty: big.NewInt(100), step: big.NewInt(10)}, 5000, big.NewInt(100 + 10*4625)},
+		{"genesis", &fakeHeaderChain{blockTime: 120, difficulty: big.NewInt(1000), step: new(big.Int)}, 1, big.NewInt(1)},
+		// A short chain isn't cut, this is the work of blocks 2 to 9 over the time from block 1 to 9, rounded up
+		{"short", &fakeHeaderChain{blockTime: 120, difficulty: big.NewInt(100), step: big.NewInt(10)}, 10, big.NewInt((1240*120 + 959) / 960)},
+	}
+	for _, tt := range tests {
+		d, err := ExpectedDifficulty(tt.chain, MoneroParams, tt.height, 16)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+		} else if d.Cmp(tt.expected) != 0 {
+			t.Errorf("%s: got difficulty %v, expected %v", tt.name, d, tt.expected)
+		}
+	}
+
+	// Version 1 blocks had a one minute target
+	chain := &fakeHeaderChain{blockTime: 60, difficulty: big.NewInt(1000), step: new(big.Int)}
+	if d, err := ExpectedDifficulty(chain, MoneroParams, 5000, 1); err != nil || d.Cmp(big.NewInt(1000)) != 0 {
+		t.Errorf("Expected 1000 for version 1, got %v, %v", d, err)
+	}
+	// Another coin's window is blocks 4939 to 4998, which after the cut averages block 4969's difficulty
+	coin := CoinParams{DifficultyTarget: 240, DifficultyWindow: 60, DifficultyLag: 1, DifficultyCut: 5}
+	chain = &fakeHeaderChain{blockTime: 240, difficulty: big.NewInt(100), step: big.NewInt(10)}
+	if d, err := ExpectedDifficulty(chain, coin, 5000, 16); err != nil || d.Cmp(big.NewInt(100+10*4969)) != 0 {
+		t.Errorf("Expected the coin's own window, got %v, %v", d, err)
+	}
+}
+
+// recordedHeadersRange is monerod's get_block_headers_range response for blocks 1545999 and 1546000.
+const recordedHeadersRange = `{"id":"0","jsonrpc":"2.0","result":{"credits":0,"headers":[{
+	"block_size":301413,"block_weight":301413,"cumulative_difficulty":13185267971483472,"cumulative_difficulty_top64":0,
+	"depth":740464,"difficulty":134636057921,"difficulty_top64":0,
+	"hash":"86d1d20a40cefcf3dd410ff6967e0491613b77bf73ea8f1bf2e335cf9cf7d57a","height":1545999,"long_term_weight":301413,
+	"major_version":6,"miner_tx_hash":"9909c6f8a5267f043c3b2b079fb4eacc49ef9c1dee1c028eeb1a259b95e6e1d9","minor_version":6,
+	"nonce":3246403956,"num_txes":20,"orphan_status":false,"pow_hash":"",
+	"prev_hash":"0ef6e948f77b8f8806621003f5de24b1bcbea150bc0e376835aea099674a5db5","reward":5025593029981,
+	"timestamp":1523002893,"wide_cumulative_difficulty":"0x2ed7ee6db56750","wide_difficulty":"0x1f58ef3541"
+},{
+	"block_size":13322,"block_weight":13322,"cumulative_difficulty":13185402687569710,"cumulative_difficulty_top64":0,
+	"depth":740463,"difficulty":134716086238,"difficulty_top64":0,
+	"hash":"b408bf4cfcd7de13e7e370c84b8314c85b24f0ba4093ca1d6eeb30b35e34e91a","height":1546000,"long_term_weight":13322,
+	"major_version":7,"miner_tx_hash":"7f749c7c64acb35ef427c7454c45e6688781fbead9bbf222cb12ad1a96a4e8f6","minor_version":7,
+	"nonce":3737164176,"num_txes":1,"orphan_status":false,"pow_hash":"",
+	"prev_hash":"86d1d20a40cefcf3dd410ff6967e0491613b77bf73ea8f1bf2e335cf9cf7d57a","reward":4851952181070,
+	"timestamp":1523002931,"wide_cumulative_difficulty":"0x2ed80dcb69bf2e","wide_difficulty":"0x1f5db457de"
+}],"status":"OK","top_hash":"","untrusted":false}}`
+
+// TestExpectedDifficultyOverRPC checks the range ExpectedDifficulty asks a node for and the arithmetic over what comes
+// back.  The recording is only two headers long, not the 735 a Monero window needs, so it isn't compared with the
+// difficulty the daemon reported for the next block.
+func TestExpectedDifficultyOverRPC(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+			Params struct {
+				Start uint64 `json:"start_height"`
+				End   uint64 `json:"end_height"`
+			} `json:"params"`
+		}
+		if json.NewDecoder(r.Body).Decode(&req) != nil || req.Method != "get_block_headers_range" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if req.Params.Start != 1545999 || req.Params.End != 1546000 {
+			w.Write([]byte(`{"id":"0","jsonrpc":"2.0","error":{"code":-1,"message":"Invalid start/end heights."}}`))
+			return
+		}
+		w.Write([]byte(recordedHeadersRange))
+	}))
+	defer srv.Close()
+	node := rpc.NewNode(strings.TrimPrefix(srv.URL, "http://"))
+
+	// The recording only covers two blocks, so this is a coin whose window is just those two, with nothing cut.  Block
+	// 1546000 added 134716086238 of work 38 seconds after block 1545999, scaled to a 120 second target and rounded up.
+	coin := CoinParams{DifficultyTarget: 120, DifficultyWindow: 2}
+	d, err := ExpectedDifficulty(node, coin, 1546001, 7)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expected := big.NewInt((134716086238*120 + 37) / 38); d.Cmp(expected) != 0 {
+		t.Errorf("Expected difficulty %v, got %v", expected, d)
+	}
+
+	// Heights outside the recording come back as the node's error
+	if _, err = ExpectedDifficulty(node, coin, 1546002, 7); err == nil || !strings.Contains(err.Error(), "Invalid start/end heights") {
+		t.Errorf("Expected the node's error, got %v", err)
+	}
+}
@@ -0,0 +1,143 @@
+package monerocnutils
+
+import (
+	"errors"
+	"github.com/snipa22/monerocnutils/crypto"
+	"github.com/snipa22/monerocnutils/serialization"
+	"sort"
+	"sync"
+)
+
+var (
+	UnknownHasher = errors.New("no hasher is registered for the coin and block version")
+)
+
+// Hasher computes the proof of work hash of a hashing blob.  Hashers may keep state between calls, such as a RandomX
+// VM, so they aren't expected to be safe for concurrent use.
+type Hasher interface {
+	Hash(hashingBlob []byte, height uint64, seedHash [32]byte) ([32]byte, error)
+}
+
+// HasherFactory returns a new Hasher, for a goroutine to keep to itself.
+type HasherFactory func() Hasher
+
+// RandomXHasher is a Hasher for RandomX blocks, hashing with its own VM and caches shared through a cache manager.
+type RandomXHasher struct {
+	caches *crypto.RandomXCacheManager
+	vm     *crypto.RandomXVM
+}
+
+// NewRandomXHasher returns a RandomXHasher getting its caches from caches.
+func NewRandomXHasher(caches *crypto.RandomXCacheManager) *RandomXHasher {
+	return &RandomXHasher{caches: caches}
+}
+
+// Hash returns the RandomX hash of hashingBlob with the key seedHash.
+func (h *RandomXHasher) Hash(hashingBlob []byte, height uint64, seedHash [32]byte) ([32]byte, error) {
+	h.vm = h.caches.VM(h.vm, seedHash)
+	return h.vm.Hash(hashingBlob), nil
+}
+
+// CryptonightHasher is a Hasher for one of the CryptoNight variants.  It keeps no state, the seed hash is ignored.
+type CryptonightHasher struct {
+	Variant crypto.CryptonightVariant
+}
+
+// Hash returns the CryptoNight hash of hashingBlob, the height only matters to CryptonightR.
+func (h CryptonightHasher) Hash(hashingBlob []byte, height uint64, seedHash [32]byte) ([32]byte, error) {
+	return crypto.CryptonightHash(hashingBlob, h.Variant, height)
+}
+
+type hasherEntry struct {
+	version uint8
+	factory HasherFactory
+}
+
+var (
+	hashersMu sync.RWMutex
+	hashers   = make(map[Coin][]hasherEntry) // By version
+)
+
+// randomxCaches is shared by the built in RandomX hashers, so all of them only ever build a cache once per key.
+var randomxCaches = crypto.NewRandomXCacheManager()
+
+func init() {
+	// Monero's proof of work forks.  Source: cryptonote_format_utils.cpp and rx-slow-hash.c
+	RegisterHasher(Monero, 1, func() Hasher { return CryptonightHasher{crypto.CryptonightV0} })
+	RegisterHasher(Monero, 7, func() Hasher { return CryptonightHasher{crypto.CryptonightV1} })
+	RegisterHasher(Monero, 8, func() Hasher { return CryptonightHasher{crypto.CryptonightV2} })
+	RegisterHasher(Monero, 10, func() Hasher { return CryptonightHasher{crypto.CryptonightR} })
+	RegisterHasher(Monero, 12, func() Hasher { return NewRandomXHasher(randomxCaches) })
+}
+
+// RegisterHasher sets the proof of work of coin's blocks from majorVersion until the next version with a hasher of its
+// own, replacing whatever was registered at majorVersion.
+func RegisterHasher(c Coin, majorVersion uint8, f HasherFactory) {
+	hashersMu.Lock()
+	defer hashersMu.Unlock()
+	entries := hashers[c]
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].version >= majorVersion })
+	if i < len(entries) && entries[i].version == majorVersion {
+		entries[i].factory = f
+		return
+	}
+	entries = append(entries, hasherEntry{})
+	copy(entries[i+1:], entries[i:])
+	entries[i] = hasherEntry{version: majorVersion, factory: f}
+	hashers[c] = entries
+}
+
+// hasherFor returns the registration covering coin's blocks of majorVersion.
+func hasherFor(c Coin, majorVersion uint8) (hasherEntry, error) {
+	hashersMu.RLock()
+	defer hashersMu.RUnlock()
+	entries := hashers[c]
+	i := sort.Search(len(entries), func(i int) bool { return entries[i].version > majorVersion })
+	if i == 0 {
+		return hasherEntry{}, UnknownHasher
+	}
+	return entries[i-1], nil
+}
+
+// NewHasher returns a new Hasher for coin's blocks of majorVersion.
+func NewHasher(c Coin, majorVersion uint8) (Hasher, error) {
+	entry, err := hasherFor(c, majorVersion)
+	if err != nil {
+		return nil, err
+	}
+	return entry.factory(), nil
+}
+
+// CoinHasher is a Hasher for any of a coin's blocks, picking the registered hasher by the major version at the start
+// of each hashing blob.  The hashers it makes are kept for reuse, so like them a CoinHasher isn't safe for concurrent
+// use.
+type CoinHasher struct {
+	coin    Coin
+	hashers map[uint8]Hasher // By the version they were registered at
+}
+
+// NewCoinHasher returns a CoinHasher for coin.
+func NewCoinHasher(c Coin) *CoinHasher {
+	return &CoinHasher{coin: c, hashers: make(map[uint8]Hasher)}
+}
+
+// Hash hashes hashingBlob with the hasher for its major version.
+func (h *CoinHasher) Hash(hashingBlob []byte, height uint64, seedHash [32]byte) ([32]byte, error) {
+	version, _, err := serialization.ReadUint(hashingBlob)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if version > 0xff {
+		return [32]byte{}, UnknownHasher
+	}
+	entry, err := hasherFor(h.coin, uint8(version))
+	if err != nil {
+		return [32]byte{}, err
+	}
+	hasher, ok := h.hashers[entry.version]
+	if !ok {
+		hasher = entry.factory()
+		h.hashers[entry.version] = hasher
+	}
+	return hasher.Hash(hashingBlob, height, seedHash)
+}
@@ -0,0 +1,96 @@
+package monerocnutils
+
+import (
+	"encoding/hex"
+	"github.com/snipa22/monerocnutils/crypto"
+	"testing"
+)
+
+// fakeHasher stands in for the real proof of work, which is far too slow for tests, by hashing with Keccak.
+type fakeHasher struct {
+	wait  <-chan struct{} // Hash blocks until this is closed, if set
+	calls int
+}
+
+func (h *fakeHasher) Hash(hashingBlob []byte, height uint64, seedHash [32]byte) ([32]byte, error) {
+	if h.wait != nil {
+		<-h.wait
+	}
+	h.calls++
+	return crypto.KeccakOneShot(hashingBlob), nil
+}
+
+func TestHasherRegistry(t *testing.T) {
+	const testCoin Coin = 1000
+	var made []int
+	register := func(version uint8, id int) {
+		RegisterHasher(testCoin, version, func() Hasher {
+			made = append(made, id)
+			return &fakeHasher{}
+		})
+	}
+	register(5, 5)
+	register(2, 2)
+	register(9, 1)
+	register(9, 9)
+
+	if _, err := NewHasher(testCoin, 1); err != UnknownHasher {
+		t.Fatalf("Expected UnknownHasher, got %v", err)
+	}
+	for version, id := range map[uint8]int{2: 2, 4: 2, 5: 5, 8: 5, 9: 9, 255: 9} {
+		made = nil
+		if _, err := NewHasher(testCoin, version); err != nil || len(made) != 1 || made[0] != id {
+			t.Fatalf("Expected version %d to use hasher %d, got %v, %v", version, id, made, err)
+		}
+	}
+	if _, err := NewHasher(testCoin+1, 12); err != UnknownHasher {
+		t.Fatalf("Expected UnknownHasher, got %v", err)
+	}
+}
+
+func TestMoneroHashers(t *testing.T) {
+	for version, variant := range map[uint8]crypto.CryptonightVariant{
+		1: crypto.CryptonightV0, 6: crypto.CryptonightV0, 7: crypto.CryptonightV1, 9: crypto.CryptonightV2,
+		10: crypto.CryptonightR, 11: crypto.CryptonightR,
+	} {
+		h, err := NewHasher(Monero, version)
+		if err != nil {
+			t.Fatal("Error getting a hasher,", err)
+		}
+		if h != (CryptonightHasher{variant}) {
+			t.Fatalf("Expected version %d to use variant %d, got %v", version, variant, h)
+		}
+	}
+	for _, version := range []uint8{12, 16} {
+		if h, _ := NewHasher(Monero, version); h == nil {
+			t.Fatalf("Expected version %d to use RandomX", version)
+		} else if _, ok := h.(*RandomXHasher); !ok {
+			t.Fatalf("Expected version %d to use RandomX, got %T", version, h)
+		}
+	}
+}
+
+func TestCoinHasher(t *testing.T) {
+	const testCoin Coin = 1001
+	var hashers []*fakeHasher
+	RegisterHasher(testCoin, 1, func() Hasher {
+		hashers = append(hashers, &fakeHasher{})
+		return hashers[len(hashers)-1]
+	})
+
+	h := NewCoinHasher(testCoin)
+	hashingBlob, _ := hex.DecodeString(minerTXBlockTemplate2HashingBlob)
+	for i := 0; i < 3; i++ {
+		hash, err := h.Hash(hashingBlob, 0, [32]byte{})
+		if err != nil || hash != crypto.KeccakOneShot(hashingBlob) {
+			t.Fatalf("Expected the fake hash, got %x, %v", hash, err)
+		}
+	}
+	if len(hashers) != 1 || hashers[0].calls != 3 {
+		t.Fatal("Expected the hasher to be made once and reused")
+	}
+
+	if _, err := NewCoinHasher(testCoin+1).Hash(hashingBlob, 0, [32]byte{}); err != UnknownHasher {
+		t.Fatalf("Expected UnknownHasher, got %v", err)
+	}
+}
@@ -0,0 +1,161 @@
+package monerocnutils
+
+import (
+	"errors"
+	"github.com/snipa22/monerocnutils/crypto"
+	"github.com/snipa22/monerocnutils/serialization"
+)
+
+// Merge mining commits to the block hashes of any number of child chains from the parent's miner transaction.  The
+// hashes are the leaves of a merkle tree of 2^depth slots, each chain's slot picked by the bits of its chain ID, and
+// the miner transaction's extra carries the depth and root.  A child chain then accepts the parent's PoW once shown
+// its hash is in the tree and the miner transaction is in the parent block.  Original: bytecoin's merge mining,
+// tree_hash_from_branch in tree-hash.c
+
+// MaxAuxDepth is the deepest aux tree built, looking for slots that don't collide.
+const MaxAuxDepth = 16
+
+var (
+	AuxSlotCollision       = errors.New("child chains collide at every aux tree depth")
+	UnknownAuxChain        = errors.New("chain is not among the aux chains")
+	MissingMergeMiningTag  = serialization.MissingMergeMiningTag
+	AuxBranchDepthMismatch = errors.New("aux branch length doesn't match the merge mining tag's depth")
+	AuxHashNotCommitted    = errors.New("aux hash is not committed to by the merge mining tag")
+)
+
+// AuxChain is a child chain's block to commit to.
+type AuxChain struct {
+	ChainID   [32]byte // Usually the chain's genesis block hash
+	BlockHash [32]byte
+}
+
+// AuxSlot returns the slot of the chain chainID in an aux tree of the given depth.  Bit i of the chain ID picks the
+// side at level i from the root, as tree_hash_from_branch reads its path.  This is Bytecoin's slot scheme, not
+// Monero's get_aux_slot, which takes the sha256 of the chain ID, a nonce and HASH_KEY_MM_SLOT modulo the number of
+// chains and packs both into the tag's depth with encode_mm_depth.  Chains land in different slots under the two, so
+// trees built here don't verify with tools following Monero's.
+func AuxSlot(chainID [32]byte, depth uint64) uint64 {
+	var slot uint64
+	for i := uint64(0); i < depth; i++ {
+		slot = slot<<1 | uint64(chainID[i>>3]>>(i&7)&1)
+	}
+	return slot
+}
+
+// AuxTree lays out the aux chains in the shallowest tree they don't collide in, returning its leaves and depth.
+// Unused slots are zero hashes.
+func AuxTree(chains []AuxChain) ([][32]byte, uint64, error) {
+	for depth := uint64(0); depth <= MaxAuxDepth; depth++ {
+		if len(chains) > 1<<depth {
+			continue
+		}
+		leaves := make([][32]byte, 1<<depth)
+		used := make([]bool, len(leaves))
+		collision := false
+		for _, c := range chains {
+			slot := AuxSlot(c.ChainID, depth)
+			if used[slot] {
+				collision = true
+				break
+			}
+			used[slot] = true
+			leaves[slot] = c.BlockHash
+		}
+		if !collision {
+			return leaves, depth, nil
+		}
+	}
+	return nil, 0, AuxSlotCollision
+}
+
+// AuxMerkleRoot returns the merge mining tag committing to chains.
+func AuxMerkleRoot(chains []AuxChain) (serialization.ExtraMergeMiningTag, error) {
+	leaves, depth, err := AuxTree(chains)
+	if err != nil {
+		return serialization.ExtraMergeMiningTag{}, err
+	}
+	return serialization.ExtraMergeMiningTag{Depth: depth, MerkleRoot: crypto.TreeHash(leaves), Used: true}, nil
+}
+
+// AuxBranch returns the sibling hashes from the root down to the slot of chainID in the aux tree for chains, the
+// branch AuxRootFromBranch takes.
+func AuxBranch(chains []AuxChain, chainID [32]byte) ([][32]byte, error) {
+	leaves, depth, err := AuxTree(chains)
+	if err != nil {
+		return nil, err
+	}
+	found := false
+	for _, c := range chains {
+		found = found || c.ChainID == chainID
+	}
+	if !found {
+		return nil, UnknownAuxChain
+	}
+
+	branch, _, err := crypto.TreeBranch(leaves, int(AuxSlot(chainID, depth)))
+	return branch, err
+}
+
+// AuxRootFromBranch returns the aux tree root given a child's block hash and its branch, root first.  The chain ID is
+// the path to the slot.
+func AuxRootFromBranch(auxHash, chainID [32]byte, branch [][32]byte) [32]byte {
+	return crypto.TreeHashFromBranch(branch, auxHash, chainID[:])
+}
+
+// AddMergeMiningTag sets the merge mining tag in the miner transaction of a block template blob, returning the new
+// blob and where its reserved space, found at reservedOffset in the original, has moved to.
+func AddMergeMiningTag(blob []byte, reservedOffset int, tag serialization.ExtraMergeMiningTag) ([]byte, int, error) {
+	b, err := ParseBlock(blob)
+	if err != nil {
+		return nil, 0, err
+	}
+	if reservedOffset != 0 {
+		if _, err = reservedSpace(b, reservedOffset); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	extra := b.MinerTxn.ExtraFields()
+	extra.SetMergeMiningTag(tag)
+	b.MinerTxn.Extra = extra.Serialize()
+	if reservedOffset != 0 {
+		reservedOffset, _, _ = extraNonceOffset(b)
+	}
+	return b.Serialize(), reservedOffset, nil
+}
+
+// MergeMiningProof shows that a parent block commits to a child chain's block hash.
+type MergeMiningProof struct {
+	ParentBlob []byte     // The parent block, its header, miner transaction and transaction hashes
+	AuxBranch  [][32]byte // See AuxBranch
+}
+
+// NewMergeMiningProof builds the proof that parentBlob, mined with a merge mining tag for chains, commits to the
+// block of the chain chainID.
+func NewMergeMiningProof(parentBlob []byte, chains []AuxChain, chainID [32]byte) (MergeMiningProof, error) {
+	branch, err := AuxBranch(chains, chainID)
+	if err != nil {
+		return MergeMiningProof{}, err
+	}
+	return MergeMiningProof{ParentBlob: parentBlob, AuxBranch: branch}, nil
+}
+
+// Verify checks that the parent block commits to auxHash for the chain chainID, and returns the parent's hashing blob,
+// whose PoW hash has to meet the child chain's difficulty.
+func (p MergeMiningProof) Verify(auxHash, chainID [32]byte) ([]byte, error) {
+	b, err := ParseBlock(p.ParentBlob)
+	if err != nil {
+		return nil, err
+	}
+	tag, ok := b.MinerTxn.ExtraFields().MergeMiningTag()
+	if !ok {
+		return nil, MissingMergeMiningTag
+	}
+	if tag.Depth != uint64(len(p.AuxBranch)) {
+		return nil, AuxBranchDepthMismatch
+	}
+	if AuxRootFromBranch(auxHash, chainID, p.AuxBranch) != tag.MerkleRoot {
+		return nil, AuxHashNotCommitted
+	}
+	return GetBlockHashingBlob(b)
+}
@@ -0,0 +1,95 @@
+package monerocnutils
+
+import (
+	"bytes"
+	"github.com/snipa22/monerocnutils/crypto"
+	"testing"
+)
+
+func testAuxChains() []AuxChain {
+	// The first two IDs share their first bit, so they need a tree of depth 2
+	return []AuxChain{
+		{ChainID: [32]byte{0x00}, BlockHash: crypto.KeccakOneShot([]byte("child a"))},
+		{ChainID: [32]byte{0x02}, BlockHash: crypto.KeccakOneShot([]byte("child b"))},
+		{ChainID: [32]byte{0x01}, BlockHash: crypto.KeccakOneShot([]byte("child c"))},
+	}
+}
+
+func TestAuxMerkleRoot(t *testing.T) {
+	chains := testAuxChains()
+	tag, err := AuxMerkleRoot(chains)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if tag.Depth != 2 {
+		t.Fatalf("Expected a depth of 2, got %d", tag.Depth)
+	}
+	for _, c := range chains {
+		branch, err := AuxBranch(chains, c.ChainID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if AuxRootFromBranch(c.BlockHash, c.ChainID, branch) != tag.MerkleRoot {
+			t.Fatalf("Branch for chain %x doesn't lead to the root", c.ChainID)
+		}
+	}
+
+	if single, _ := AuxMerkleRoot(chains[:1]); single.Depth != 0 || single.MerkleRoot != chains[0].BlockHash {
+		t.Fatal("A single chain should be committed to directly")
+	}
+	if _, err = AuxMerkleRoot([]AuxChain{chains[0], chains[0]}); err != AuxSlotCollision {
+		t.Fatalf("Expected AuxSlotCollision, got %v", err)
+	}
+	if _, err = AuxBranch(chains, [32]byte{0xff}); err != UnknownAuxChain {
+		t.Fatalf("Expected UnknownAuxChain, got %v", err)
+	}
+}
+
+func TestMergeMiningProof(t *testing.T) {
+	chains := testAuxChains()
+	tag, _ := AuxMerkleRoot(chains)
+
+	bt, err := BuildBlockTemplate(MoneroParams, testTemplateParams(nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	blob, reservedOffset, err := AddMergeMiningTag(bt.Blob, bt.ReservedOffset, tag)
+	if err != nil {
+		t.Fatal("Error adding the merge mining tag,", err)
+	}
+	if reserved, err := ReservedSpace(blob, reservedOffset); err != nil || reserved != PoolNonceSize {
+		t.Fatalf("Reserved space got lost, %d bytes, %v", reserved, err)
+	}
+	if _, _, err = AddMergeMiningTag(bt.Blob, bt.ReservedOffset+1, tag); err != InvalidReservedOffset {
+		t.Fatalf("Expected InvalidReservedOffset, got %v", err)
+	}
+
+	parent, _ := ParseBlock(blob)
+	expectedHashingBlob, _ := GetBlockHashingBlob(parent)
+	for _, c := range chains {
+		proof, err := NewMergeMiningProof(blob, chains, c.ChainID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		hashingBlob, err := proof.Verify(c.BlockHash, c.ChainID)
+		if err != nil {
+			t.Fatalf("Proof for chain %x was rejected, %v", c.ChainID, err)
+		}
+		if !bytes.Equal(hashingBlob, expectedHashingBlob) {
+			t.Fatal("Proof didn't return the parent's hashing blob")
+		}
+		if _, err = proof.Verify(crypto.KeccakOneShot([]byte("other")), c.ChainID); err != AuxHashNotCommitted {
+			t.Fatalf("Expected AuxHashNotCommitted, got %v", err)
+		}
+	}
+
+	proof, _ := NewMergeMiningProof(blob, chains, chains[0].ChainID)
+	proof.AuxBranch = proof.AuxBranch[1:]
+	if _, err = proof.Verify(chains[0].BlockHash, chains[0].ChainID); err != AuxBranchDepthMismatch {
+		t.Fatalf("Expected AuxBranchDepthMismatch, got %v", err)
+	}
+	proof.ParentBlob = bt.Blob
+	if _, err = proof.Verify(chains[0].BlockHash, chains[0].ChainID); err != MissingMergeMiningTag {
+		t.Fatalf("Expected MissingMergeMiningTag, got %v", err)
+	}
+}
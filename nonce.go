@@ -0,0 +1,50 @@
+package monerocnutils
+
+import (
+	"github.com/snipa22/monerocnutils/serialization"
+)
+
+// NonceSize is the size of the nonce miners search over.
+const NonceSize = 4
+
+// NonceOffset returns the offset of the nonce in blob, which can be either a full block blob or a hashing blob as
+// both start with the block header.  The offset moves with the widths of the varint encoded versions and timestamp.
+func NonceOffset(blob []byte) (int, error) {
+	// Major version, minor version and timestamp, then skip the previous block id
+	r := serialization.NewReader(blob)
+	for i := 0; i < 3; i++ {
+		if _, err := r.ReadVarint(); err != nil {
+			return 0, archiveError(err)
+		}
+	}
+	if _, err := r.ReadBlob(32); err != nil {
+		return 0, archiveError(err)
+	}
+	offset := r.Offset()
+	if _, err := r.ReadBlob(NonceSize); err != nil {
+		return 0, archiveError(err)
+	}
+	return offset, nil
+}
+
+// BlobNonce reads the nonce out of a block or hashing blob.  The bytes are in blob order, so a block's Nonce is their
+// little endian value.
+func BlobNonce(blob []byte) ([NonceSize]byte, error) {
+	var nonce [NonceSize]byte
+	offset, err := NonceOffset(blob)
+	if err != nil {
+		return nonce, err
+	}
+	copy(nonce[:], blob[offset:offset+NonceSize])
+	return nonce, nil
+}
+
+// SetBlobNonce writes nonce into a block or hashing blob in place, such as the 4 bytes returned by a miner.
+func SetBlobNonce(blob []byte, nonce [NonceSize]byte) error {
+	offset, err := NonceOffset(blob)
+	if err != nil {
+		return err
+	}
+	copy(blob[offset:offset+NonceSize], nonce[:])
+	return nil
+}
@@ -0,0 +1,63 @@
+package monerocnutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/snipa22/monerocnutils/serialization"
+	"testing"
+)
+
+func TestBlobNonce(t *testing.T) {
+	blob, _ := hex.DecodeString(minerTXBlockTemplate2)
+	hashingBlob, _ := hex.DecodeString(minerTXBlockTemplate2HashingBlob)
+	nonce := [NonceSize]byte{0xde, 0xad, 0xbe, 0xef}
+
+	for _, b := range [][]byte{blob, hashingBlob} {
+		offset, err := NonceOffset(b)
+		if err != nil {
+			t.Fatal("Error finding the nonce offset,", err)
+		}
+		// 2 one byte versions, a 5 byte timestamp and the previous id
+		if offset != 39 {
+			t.Fatalf("Expected the nonce at offset 39, got %d", offset)
+		}
+		if err = SetBlobNonce(b, nonce); err != nil {
+			t.Fatal("Error setting the nonce,", err)
+		}
+		read, err := BlobNonce(b)
+		if err != nil || read != nonce {
+			t.Fatalf("Expected to read back %x, got %x", nonce, read)
+		}
+	}
+
+	// The nonce set in the full blob has to end up in the hashing blob built from it
+	block, _ := ParseBlock(blob)
+	if block.Nonce != 0xefbeadde {
+		t.Fatalf("Expected the block nonce to be 0xefbeadde, got %#x", block.Nonce)
+	}
+	rebuilt, _ := GetBlockHashingBlob(block)
+	if !bytes.Equal(rebuilt, hashingBlob) {
+		t.Fatal("Hashing blob with the nonce spliced in doesn't match the one built from the block")
+	}
+
+	if _, err := NonceOffset(blob[:40]); err != InvalidBlobLength {
+		t.Fatalf("Expected InvalidBlobLength for a truncated blob, got %v", err)
+	}
+}
+
+// The header of mainnet block 2751506, from get_block
+const block2751506Header = "1010c58bab9b06b27bdecfc6cd0a46172d136c08831cf67660377ba992332363228b1b722781e7807e07f5"
+
+func TestRecordedBlockNonce(t *testing.T) {
+	// The daemon reports block 2751506's nonce as 4110909056, stored little endian in the blob
+	blob, _ := hex.DecodeString(block2751506Header)
+	nonce, err := BlobNonce(blob)
+	if err != nil || nonce != [NonceSize]byte{0x80, 0x7e, 0x07, 0xf5} {
+		t.Fatalf("Expected nonce bytes 807e07f5, got %x, %v", nonce, err)
+	}
+	header := serialization.BlockHeader{MajorVersion: 16, MinorVersion: 16, Timestamp: 1667941829, Nonce: 4110909056}
+	copy(header.PreviousID[:], blob[7:39])
+	if !bytes.Equal(header.Serialize(), blob) {
+		t.Fatalf("Expected the header with nonce 4110909056 to serialize to %x, got %x", blob, header.Serialize())
+	}
+}
@@ -0,0 +1,154 @@
+package monerocnutils
+
+import (
+	"errors"
+	"github.com/snipa22/monerocnutils/serialization"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+// Monero's emission and block weight parameters.  Source: cryptonote_config.h
+const (
+	MoneySupply                     = math.MaxUint64
+	EmissionSpeedFactorPerMinute    = 20
+	FinalSubsidyPerMinute           = 300000000000 // 0.3 XMR, so 0.6 XMR per two minute block
+	FullRewardZoneV1                = 20000
+	FullRewardZoneV2                = 60000
+	FullRewardZoneV5                = 300000
+	ShortTermBlockWeightSurgeFactor = 50
+
+	// HFVersionLongTermBlockWeight is the block version the long term median started limiting the median weight at
+	HFVersionLongTermBlockWeight = 10
+	// HFVersionExactCoinbase is the block version the miner transaction had to claim exactly the reward plus fees at
+	HFVersionExactCoinbase = 13
+)
+
+var (
+	BlockTooBig              = errors.New("block weight is more than twice the median weight")
+	CoinbaseAmountTooHigh    = errors.New("miner transaction claims more than the block reward plus fees")
+	CoinbaseAmountMismatch   = errors.New("miner transaction doesn't claim exactly the block reward plus fees")
+	CoinbaseAmountOverflowed = errors.New("miner transaction outputs overflow")
+)
+
+// MinBlockWeight returns the full reward zone for a block version, the weight a block can have without penalty
+// whatever the median.  Original: get_min_block_weight in cryptonote_basic_impl.cpp
+func MinBlockWeight(version uint8) uint64 {
+	switch {
+	case version < 2:
+		return FullRewardZoneV1
+	case version < 5:
+		return FullRewardZoneV2
+	}
+	return FullRewardZoneV5
+}
+
+// BaseReward returns the emission for a block once alreadyGeneratedCoins have been mined, before any penalty.  Each
+// extra minute of block target drops the speed factor by one and adds to the tail emission, so Monero's two minute
+// blocks from version 2 emit twice as fast.
+func BaseReward(coin CoinParams, alreadyGeneratedCoins uint64, version uint8) uint64 {
+	targetMinutes := coin.TargetSeconds(version) / 60
+	emissionSpeedFactor := coin.EmissionSpeedFactor - (targetMinutes - 1)
+
+	reward := (coin.MoneySupply - alreadyGeneratedCoins) >> emissionSpeedFactor
+	if reward < coin.FinalSubsidyPerMinute*targetMinutes {
+		reward = coin.FinalSubsidyPerMinute * targetMinutes
+	}
+	return reward
+}
+
+// BlockReward returns the reward, without fees, for a block of blockWeight given the median weight of the blocks
+// before it.  Blocks over the median have their reward cut by ((blockWeight - median) / median)^2, and blocks over
+// twice the median are invalid.  Original: get_block_reward in cryptonote_basic_impl.cpp
+func BlockReward(coin CoinParams, medianWeight, blockWeight, alreadyGeneratedCoins uint64, version uint8) (uint64, error) {
+	baseReward := BaseReward(coin, alreadyGeneratedCoins, version)
+
+	if fullRewardZone := MinBlockWeight(version); medianWeight < fullRewardZone {
+		medianWeight = fullRewardZone
+	}
+	if blockWeight <= medianWeight {
+		return baseReward, nil
+	}
+	if blockWeight > 2*medianWeight {
+		return 0, BlockTooBig
+	}
+
+	// baseReward * (2 * median - weight) * weight / median^2, with a 128 bit product
+	multiplicand := (2*medianWeight - blockWeight) * blockWeight
+	hi, lo := bits.Mul64(baseReward, multiplicand)
+	hi, lo = div128(hi, lo, medianWeight)
+	_, lo = div128(hi, lo, medianWeight)
+	return lo, nil
+}
+
+// div128 divides the 128 bit number hi:lo by d.
+func div128(hi, lo, d uint64) (uint64, uint64) {
+	qhi := hi / d
+	qlo, _ := bits.Div64(hi%d, lo, d)
+	return qhi, qlo
+}
+
+// Median returns the median of weights, the mean of the middle two for an even number of them.  Original:
+// epee::misc_utils::median
+func Median(weights []uint64) uint64 {
+	if len(weights) == 0 {
+		return 0
+	}
+	sorted := append([]uint64(nil), weights...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+	n := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[n]
+	}
+	return sorted[n-1]/2 + sorted[n]/2 + (sorted[n-1]%2+sorted[n]%2)/2
+}
+
+// EffectiveMedianWeight returns the median the penalty is worked out against, from the median weight of the last 100
+// blocks and, from version 10, the median long term weight of the last 100000 blocks.  The long term median stops a
+// run of big blocks from raising the short term median more than ShortTermBlockWeightSurgeFactor times.  Original:
+// Blockchain::update_next_cumulative_weight_limit
+func EffectiveMedianWeight(shortTermMedian, longTermMedian uint64, version uint8) uint64 {
+	fullRewardZone := MinBlockWeight(version)
+	if version < HFVersionLongTermBlockWeight {
+		if shortTermMedian < fullRewardZone {
+			return fullRewardZone
+		}
+		return shortTermMedian
+	}
+
+	if longTermMedian < FullRewardZoneV5 {
+		longTermMedian = FullRewardZoneV5
+	}
+	if shortTermMedian < FullRewardZoneV5 {
+		shortTermMedian = FullRewardZoneV5
+	}
+	if limit := ShortTermBlockWeightSurgeFactor * longTermMedian; shortTermMedian > limit {
+		return limit
+	}
+	return shortTermMedian
+}
+
+// CheckCoinbaseAmount makes sure the miner transaction of b claims no more than the block reward plus fees, and from
+// HFVersionExactCoinbase exactly that.  Original: Blockchain::validate_miner_transaction
+func CheckCoinbaseAmount(coin CoinParams, b serialization.Block, medianWeight, blockWeight, alreadyGeneratedCoins, fees uint64) error {
+	reward, err := BlockReward(coin, medianWeight, blockWeight, alreadyGeneratedCoins, b.MajorVersion)
+	if err != nil {
+		return err
+	}
+	var claimed uint64
+	for _, out := range b.MinerTxn.TransactionsOut {
+		var carry uint64
+		if claimed, carry = bits.Add64(claimed, out.Amount, 0); carry != 0 {
+			return CoinbaseAmountOverflowed
+		}
+	}
+
+	if claimed > reward+fees {
+		return CoinbaseAmountTooHigh
+	}
+	// From version 2 until exact coinbases miners could leave some of the reward unclaimed, to avoid dust
+	if (b.MajorVersion < 2 || b.MajorVersion >= HFVersionExactCoinbase) && claimed != reward+fees {
+		return CoinbaseAmountMismatch
+	}
+	return nil
+}
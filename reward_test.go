@@ -0,0 +1,87 @@
+package monerocnutils
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestBlockReward(t *testing.T) {
+	tests := []struct {
+		name                         string
+		median, weight, alreadyMined uint64
+		version                      uint8
+		reward                       uint64
+	}{
+		{"genesis", 0, 80, 0, 1, 17592186044415},
+		{"two minute blocks", 0, 80, 0, 2, 35184372088831},
+		{"tail emission", 0, 80, MoneySupply - 600000000000<<19, 16, 600000000000},
+		{"median is soft", 1000, FullRewardZoneV5, MoneySupply - 600000000000<<19, 16, 600000000000},
+		// (2 * 300000 - 450000) * 450000 / 300000^2 is exactly three quarters
+		{"penalty", 300000, 450000, MoneySupply - 600000000000<<19, 16, 450000000000},
+		{"penalty rounds down", 300000, 300001, MoneySupply - 600000000000<<19, 16, 599999999993},
+		{"full penalty", 300000, 600000, 0, 16, 0},
+	}
+	for _, tt := range tests {
+		reward, err := BlockReward(MoneroParams, tt.median, tt.weight, tt.alreadyMined, tt.version)
+		if err != nil || reward != tt.reward {
+			t.Errorf("%s: got %d, %v, expected %d", tt.name, reward, err, tt.reward)
+		}
+	}
+	if _, err := BlockReward(MoneroParams, 300000, 600001, 0, 16); err != BlockTooBig {
+		t.Errorf("Expected BlockTooBig, got %v", err)
+	}
+
+	// Four minute blocks take three off the speed factor and quadruple the tail emission
+	coin := CoinParams{EmissionSpeedFactor: 20, FinalSubsidyPerMinute: 1000, DifficultyTarget: 240, MoneySupply: 1 << 40}
+	if reward := BaseReward(coin, 0, 16); reward != 1<<23 {
+		t.Errorf("Expected the coin's emission, got %d", reward)
+	}
+	if reward := BaseReward(coin, 1<<40-1<<20, 16); reward != 4000 {
+		t.Errorf("Expected the coin's tail emission, got %d", reward)
+	}
+}
+
+func TestEffectiveMedianWeight(t *testing.T) {
+	if m := Median([]uint64{5, 1, 3}); m != 3 {
+		t.Errorf("Expected a median of 3, got %d", m)
+	}
+	if m := Median([]uint64{7, 1, 4, 10}); m != 5 {
+		t.Errorf("Expected a median of 5, got %d", m)
+	}
+	if m := EffectiveMedianWeight(1000, 0, 4); m != FullRewardZoneV2 {
+		t.Errorf("Expected the v2 full reward zone, got %d", m)
+	}
+	if m := EffectiveMedianWeight(400000, 0, 9); m != 400000 {
+		t.Errorf("Expected the short term median before long term weights, got %d", m)
+	}
+	if m := EffectiveMedianWeight(20000000, 0, 16); m != ShortTermBlockWeightSurgeFactor*FullRewardZoneV5 {
+		t.Errorf("Expected the surge limit, got %d", m)
+	}
+}
+
+func TestCheckCoinbaseAmount(t *testing.T) {
+	blob, _ := hex.DecodeString(genesisBlock)
+	b, err := ParseBlock(blob)
+	if err != nil {
+		t.Fatal("Error parsing the genesis block,", err)
+	}
+	if err = CheckCoinbaseAmount(MoneroParams, b, 0, uint64(len(blob)), 0, 0); err != nil {
+		t.Fatal("Genesis block coinbase was rejected,", err)
+	}
+
+	b.MinerTxn.TransactionsOut[0].Amount--
+	if err = CheckCoinbaseAmount(MoneroParams, b, 0, uint64(len(blob)), 0, 0); err != CoinbaseAmountMismatch {
+		t.Fatalf("Expected CoinbaseAmountMismatch before version 2, got %v", err)
+	}
+	b.MajorVersion = 2
+	if err = CheckCoinbaseAmount(MoneroParams, b, 0, uint64(len(blob)), 1<<63, 0); err != nil {
+		t.Fatal("Claiming less than the reward should be fine from version 2,", err)
+	}
+	if err = CheckCoinbaseAmount(MoneroParams, b, 0, uint64(len(blob)), MoneySupply, 0); err != CoinbaseAmountTooHigh {
+		t.Fatalf("Expected CoinbaseAmountTooHigh, got %v", err)
+	}
+	b.MajorVersion = HFVersionExactCoinbase
+	if err = CheckCoinbaseAmount(MoneroParams, b, 0, uint64(len(blob)), 1<<63, 0); err != CoinbaseAmountMismatch {
+		t.Fatalf("Expected CoinbaseAmountMismatch for exact coinbases, got %v", err)
+	}
+}
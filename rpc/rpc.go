@@ -135,3 +135,87 @@ func (n *Node) BlockCount() (count int, err error) {
 	_, err = n.rpcSend("/getblockcount", v)
 	return
 }
+
+// jsonRPCSend calls method on the daemon's /json_rpc endpoint and decodes the result into result.
+func (n *Node) jsonRPCSend(method string, params interface{}, result interface{}) error {
+	req := &struct {
+		JSONRPC string      `json:"jsonrpc"`
+		ID      string      `json:"id"`
+		Method  string      `json:"method"`
+		Params  interface{} `json:"params,omitempty"`
+	}{"2.0", "0", method, params}
+
+	var buf bytes.Buffer
+	if err := json.NewEncoder(&buf).Encode(req); err != nil {
+		return err
+	}
+	resp, err := n.client.Post("http://"+n.addr+"/json_rpc", "application/json", &buf)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	reply := &struct {
+		Result interface{} `json:"result"`
+		Error  *struct {
+			Code    int    `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}{Result: result}
+	if err = json.NewDecoder(resp.Body).Decode(reply); err != nil {
+		return err
+	}
+	if reply.Error != nil {
+		return errors.New(reply.Error.Message)
+	}
+	return nil
+}
+
+// BlockHash returns the hex encoded hash of the block at height on the node's main chain.
+func (n *Node) BlockHash(height uint64) (hash string, err error) {
+	err = n.jsonRPCSend("on_get_block_hash", []uint64{height}, &hash)
+	return
+}
+
+// BlockHeader is a block header as the daemon's JSON RPC returns it.  Difficulties are split into their low and top
+// 64 bits since they outgrew a uint64.
+type BlockHeader struct {
+	MajorVersion            uint8  `json:"major_version"`
+	MinorVersion            uint8  `json:"minor_version"`
+	Timestamp               uint64 `json:"timestamp"`
+	PrevHash                string `json:"prev_hash"`
+	Nonce                   uint32 `json:"nonce"`
+	Height                  uint64 `json:"height"`
+	Depth                   uint64 `json:"depth"`
+	Hash                    string `json:"hash"`
+	Difficulty              uint64 `json:"difficulty"`
+	DifficultyTop64         uint64 `json:"difficulty_top64"`
+	CumulativeDifficulty    uint64 `json:"cumulative_difficulty"`
+	CumulativeDifficultyTop uint64 `json:"cumulative_difficulty_top64"`
+	Reward                  uint64 `json:"reward"`
+	BlockSize               uint64 `json:"block_size"`
+	BlockWeight             uint64 `json:"block_weight"`
+	LongTermWeight          uint64 `json:"long_term_weight"`
+	NumTxes                 int    `json:"num_txes"`
+	MinerTxHash             string `json:"miner_tx_hash"`
+	OrphanStatus            bool   `json:"orphan_status"`
+}
+
+// BlockHeadersRange returns the headers of the main chain blocks from start to end, inclusive.
+func (n *Node) BlockHeadersRange(start, end uint64) ([]BlockHeader, error) {
+	req := &struct {
+		Start uint64 `json:"start_height"`
+		End   uint64 `json:"end_height"`
+	}{start, end}
+	reply := &struct {
+		Status  string        `json:"status"`
+		Headers []BlockHeader `json:"headers"`
+	}{}
+	if err := n.jsonRPCSend("get_block_headers_range", req, reply); err != nil {
+		return nil, err
+	}
+	if reply.Status != "OK" {
+		return nil, errors.New(reply.Status)
+	}
+	return reply.Headers, nil
+}
@@ -0,0 +1,69 @@
+package rpc
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestBlockHash(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string   `json:"method"`
+			Params []uint64 `json:"params"`
+		}
+		if r.URL.Path != "/json_rpc" || json.NewDecoder(r.Body).Decode(&req) != nil || req.Method != "on_get_block_hash" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		if req.Params[0] > 100 {
+			w.Write([]byte(`{"id":"0","jsonrpc":"2.0","error":{"code":-2,"message":"Requested block height: 101 greater than current top block height: 100"}}`))
+			return
+		}
+		w.Write([]byte(`{"id":"0","jsonrpc":"2.0","result":"418015bb9ae982a1975da7d79277c2705727a56894ba0fb246adaabb1f4632e3"}`))
+	}))
+	defer srv.Close()
+	n := NewNode(strings.TrimPrefix(srv.URL, "http://"))
+
+	hash, err := n.BlockHash(0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != "418015bb9ae982a1975da7d79277c2705727a56894ba0fb246adaabb1f4632e3" {
+		t.Errorf("got hash %s", hash)
+	}
+	if _, err = n.BlockHash(101); err == nil || !strings.Contains(err.Error(), "greater than current top block height") {
+		t.Errorf("expected the daemon's error, got %v", err)
+	}
+}
+
+func TestBlockHeadersRange(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+			Params struct {
+				Start uint64 `json:"start_height"`
+				End   uint64 `json:"end_height"`
+			} `json:"params"`
+		}
+		if json.NewDecoder(r.Body).Decode(&req) != nil || req.Method != "get_block_headers_range" || req.Params.Start != 5 || req.Params.End != 6 {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		w.Write([]byte(`{"id":"0","jsonrpc":"2.0","result":{"headers":[
+			{"height":5,"timestamp":1397818225,"difficulty":1,"cumulative_difficulty":5},
+			{"height":6,"timestamp":1397818264,"difficulty":1,"difficulty_top64":2,"cumulative_difficulty":6,"cumulative_difficulty_top64":2}
+		],"status":"OK"}}`))
+	}))
+	defer srv.Close()
+
+	headers, err := NewNode(strings.TrimPrefix(srv.URL, "http://")).BlockHeadersRange(5, 6)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(headers) != 2 || headers[0].Height != 5 || headers[1].Timestamp != 1397818264 || headers[1].CumulativeDifficultyTop != 2 {
+		t.Errorf("got headers %+v", headers)
+	}
+}
@@ -0,0 +1,114 @@
+package monerocnutils
+
+import (
+	"encoding/hex"
+	"errors"
+	"github.com/snipa22/monerocnutils/crypto"
+	"sync"
+)
+
+var (
+	InvalidSeedHash  = errors.New("seed hash is not 32 hex encoded bytes")
+	SeedHashMismatch = errors.New("seed hash does not match the seed block for the template's height")
+)
+
+// BlockHashSource looks up main chain block hashes by height, *rpc.Node satisfies it.
+type BlockHashSource interface {
+	BlockHash(height uint64) (string, error)
+}
+
+// seedHashesKept is how many seed hashes a SeedHashResolver remembers, the current and next key plus a couple of old
+// ones for stale shares.
+const seedHashesKept = 4
+
+// SeedHashResolver works out the RandomX key for a height from the chain itself, rather than trusting the seed_hash
+// of a template.  Seed blocks are at least RandomXEpochLag blocks deep, so hashes are cached once looked up.  It's safe
+// for concurrent use.
+type SeedHashResolver struct {
+	mu     sync.Mutex
+	source BlockHashSource
+	hashes map[uint64][32]byte
+}
+
+// NewSeedHashResolver returns a SeedHashResolver looking up seed blocks with source, usually an *rpc.Node.
+func NewSeedHashResolver(source BlockHashSource) *SeedHashResolver {
+	return &SeedHashResolver{source: source, hashes: make(map[uint64][32]byte)}
+}
+
+// SeedHash returns the RandomX key for a block at height.
+func (r *SeedHashResolver) SeedHash(height uint64) ([32]byte, error) {
+	return r.hashAt(crypto.RandomXSeedHeight(height))
+}
+
+// SeedHashes returns the RandomX key for a block at height and the key that takes over RandomXEpochLag blocks later,
+// the seed_hash and next_seed_hash of a template.  next is the same as seed unless the key is about to change.
+func (r *SeedHashResolver) SeedHashes(height uint64) (seed, next [32]byte, err error) {
+	seedHeight, nextHeight := crypto.RandomXSeedHeights(height)
+	if seed, err = r.hashAt(seedHeight); err != nil {
+		return
+	}
+	if nextHeight == seedHeight {
+		return seed, seed, nil
+	}
+	next, err = r.hashAt(nextHeight)
+	return
+}
+
+// CheckSeedHash makes sure seedHash, hex encoded as in get_block_template, is the RandomX key for a block at height.
+func (r *SeedHashResolver) CheckSeedHash(height uint64, seedHash string) error {
+	given, err := decodeHash(seedHash)
+	if err != nil {
+		return err
+	}
+	expected, err := r.SeedHash(height)
+	if err != nil {
+		return err
+	}
+	if given != expected {
+		return SeedHashMismatch
+	}
+	return nil
+}
+
+// hashAt returns the hash of the block at seedHeight, from the cache if possible.
+func (r *SeedHashResolver) hashAt(seedHeight uint64) ([32]byte, error) {
+	r.mu.Lock()
+	hash, ok := r.hashes[seedHeight]
+	r.mu.Unlock()
+	if ok {
+		return hash, nil
+	}
+
+	hexHash, err := r.source.BlockHash(seedHeight)
+	if err != nil {
+		return [32]byte{}, err
+	}
+	if hash, err = decodeHash(hexHash); err != nil {
+		return [32]byte{}, err
+	}
+
+	r.mu.Lock()
+	r.hashes[seedHeight] = hash
+	// Forget the oldest seeds, heights only go up so they won't be asked for again
+	for len(r.hashes) > seedHashesKept {
+		oldest := seedHeight
+		for h := range r.hashes {
+			if h < oldest {
+				oldest = h
+			}
+		}
+		delete(r.hashes, oldest)
+	}
+	r.mu.Unlock()
+	return hash, nil
+}
+
+func decodeHash(s string) ([32]byte, error) {
+	var hash [32]byte
+	b, err := hex.DecodeString(s)
+	if err != nil || len(b) != len(hash) {
+		return hash, InvalidSeedHash
+	}
+	copy(hash[:], b)
+	return hash, nil
+}
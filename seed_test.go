@@ -0,0 +1,57 @@
+package monerocnutils
+
+import (
+	"errors"
+	"fmt"
+	"github.com/snipa22/monerocnutils/rpc"
+	"testing"
+)
+
+// fakeChain hands out a made up hash for each height, counting lookups.
+type fakeChain struct {
+	top     uint64
+	lookups map[uint64]int
+}
+
+func (c *fakeChain) BlockHash(height uint64) (string, error) {
+	if height > c.top {
+		return "", errors.New("height past the top of the chain")
+	}
+	c.lookups[height]++
+	return fmt.Sprintf("%064x", height+1), nil
+}
+
+var _ BlockHashSource = (*rpc.Node)(nil)
+
+func TestSeedHashResolver(t *testing.T) {
+	chain := &fakeChain{top: 10000, lookups: make(map[uint64]int)}
+	r := NewSeedHashResolver(chain)
+
+	seed, next, err := r.SeedHashes(2100)
+	if err != nil {
+		t.Fatal("Error resolving seed hashes,", err)
+	}
+	if fmt.Sprintf("%x", seed) != fmt.Sprintf("%064x", 1) || fmt.Sprintf("%x", next) != fmt.Sprintf("%064x", 2049) {
+		t.Fatalf("Expected the hashes of blocks 0 and 2048, got %x and %x", seed, next)
+	}
+	seed, next, err = r.SeedHashes(2113)
+	if err != nil || seed != next {
+		t.Fatalf("Expected no pending key change, got %x, %x, %v", seed, next, err)
+	}
+	if chain.lookups[0] != 1 || chain.lookups[2048] != 1 {
+		t.Fatalf("Seed hashes weren't cached, lookups %v", chain.lookups)
+	}
+
+	if err = r.CheckSeedHash(4000, fmt.Sprintf("%064x", 2049)); err != nil {
+		t.Fatal("Correct seed hash was rejected,", err)
+	}
+	if err = r.CheckSeedHash(4000, fmt.Sprintf("%064x", 1)); err != SeedHashMismatch {
+		t.Fatalf("Expected SeedHashMismatch, got %v", err)
+	}
+	if err = r.CheckSeedHash(4000, "abcd"); err != InvalidSeedHash {
+		t.Fatalf("Expected InvalidSeedHash, got %v", err)
+	}
+	if _, err = r.SeedHash(20000); err == nil {
+		t.Fatal("Expected the source's error for a seed block past the top of the chain")
+	}
+}
@@ -0,0 +1,328 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+var (
+	InvalidBlobLength = errors.New("blob ended before it was fully read")
+	TrailingData      = errors.New("blob has data left over after it was read")
+	UnexpectedTag     = errors.New("variant tag doesn't match the type being read")
+)
+
+// Marshaler is implemented by every type with a binary form, written in the layout of the daemon's binary_archive.
+// Original: serialization/binary_archive.h
+type Marshaler interface {
+	WriteArchive(w *Writer)
+}
+
+// Unmarshaler is implemented by pointers to types that can be read back from their binary form.
+type Unmarshaler interface {
+	ReadArchive(r *Reader) error
+}
+
+// Serializable is implemented by pointers to types that can be both written and read.
+type Serializable interface {
+	Marshaler
+	Unmarshaler
+}
+
+// Marshal returns the blob of m.
+func Marshal(m Marshaler) []byte {
+	w := NewWriter()
+	m.WriteArchive(w)
+	return w.Bytes()
+}
+
+// Unmarshal reads u from b, which has to hold u and nothing else.
+func Unmarshal(b []byte, u Unmarshaler) error {
+	r := NewReader(b)
+	if err := u.ReadArchive(r); err != nil {
+		return err
+	}
+	if r.Len() > 0 {
+		return r.Fail(TrailingData)
+	}
+	return nil
+}
+
+// ArchiveError is an error reading a blob, along with the offset of the value that couldn't be read.
+type ArchiveError struct {
+	Offset int
+	Err    error
+}
+
+func (e *ArchiveError) Error() string {
+	return fmt.Sprintf("%v at offset %d", e.Err, e.Offset)
+}
+
+func (e *ArchiveError) Unwrap() error {
+	return e.Err
+}
+
+// Writer builds a binary blob in the same layout as the daemon's binary_archive, every count and integer that isn't
+// a fixed width POD field is written as a varint.  It writes either to memory or to an io.Writer, keeping the first
+// error the io.Writer returns and writing nothing after it.
+type Writer struct {
+	buf *bytes.Buffer // Only when writing to memory
+	w   io.Writer
+	n   int
+	err error
+	// Data for an io.Writer is copied through here, so that what's written doesn't escape to the heap just because
+	// it's handed to an interface
+	scratch [64]byte
+}
+
+// NewWriter returns a Writer writing to memory.
+func NewWriter() *Writer {
+	return &Writer{buf: new(bytes.Buffer)}
+}
+
+// NewStreamWriter returns a Writer writing to w.
+func NewStreamWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) put(b []byte) {
+	if w.buf != nil {
+		w.n += len(b)
+		w.buf.Write(b)
+		return
+	}
+	for len(b) > 0 && w.err == nil {
+		chunk := copy(w.scratch[:], b)
+		b = b[chunk:]
+		var n int
+		n, w.err = w.w.Write(w.scratch[:chunk])
+		w.n += n
+	}
+}
+
+// WriteVarint writes v as a varint.
+func (w *Writer) WriteVarint(v uint64) {
+	n := binary.PutUvarint(w.scratch[:], v)
+	w.put(w.scratch[:n])
+}
+
+// WriteTag writes a single byte variant tag.
+func (w *Writer) WriteTag(tag byte) {
+	w.scratch[0] = tag
+	w.put(w.scratch[:1])
+}
+
+// WriteUint16 writes v as a little endian POD.
+func (w *Writer) WriteUint16(v uint16) {
+	binary.LittleEndian.PutUint16(w.scratch[:], v)
+	w.put(w.scratch[:2])
+}
+
+// WriteUint32 writes v as a little endian POD.
+func (w *Writer) WriteUint32(v uint32) {
+	binary.LittleEndian.PutUint32(w.scratch[:], v)
+	w.put(w.scratch[:4])
+}
+
+// WriteBlob writes b as is, with no length prefix.
+func (w *Writer) WriteBlob(b []byte) {
+	w.put(b)
+}
+
+// WriteVector writes the length of b as a varint followed by b itself.
+func (w *Writer) WriteVector(b []byte) {
+	w.WriteVarint(uint64(len(b)))
+	w.put(b)
+}
+
+// Len returns the number of bytes written so far.
+func (w *Writer) Len() int {
+	return w.n
+}
+
+// Bytes returns the blob written so far, or nil when writing to an io.Writer.
+func (w *Writer) Bytes() []byte {
+	if w.buf == nil {
+		return nil
+	}
+	return w.buf.Bytes()
+}
+
+// Err returns the first error writing to the io.Writer.
+func (w *Writer) Err() error {
+	return w.err
+}
+
+// Reader reads a binary blob in the layout of the daemon's binary_archive.  Errors are ArchiveErrors giving the offset
+// of the value that couldn't be read, wrapping errors such as InvalidBlobLength or VarintOverflow.  Blobs and vectors
+// read refer back into the blob rather than being copied.
+type Reader struct {
+	b   []byte
+	off int
+}
+
+// NewReader returns a Reader reading b from the start.
+func NewReader(b []byte) *Reader {
+	return &Reader{b: b}
+}
+
+// Offset returns the offset of the next byte to be read.
+func (r *Reader) Offset() int {
+	return r.off
+}
+
+// Len returns the number of bytes left to read.
+func (r *Reader) Len() int {
+	return len(r.b) - r.off
+}
+
+// Fail returns err at the reader's offset, for types to report their own errors.
+func (r *Reader) Fail(err error) error {
+	return &ArchiveError{Offset: r.off, Err: err}
+}
+
+// ReadVarint reads a varint, see ReadUint.
+func (r *Reader) ReadVarint() (uint64, error) {
+	v, rest, err := ReadUint(r.b[r.off:])
+	if err != nil {
+		return 0, r.Fail(err)
+	}
+	r.off = len(r.b) - len(rest)
+	return v, nil
+}
+
+// ReadVarint8 reads a varint into a byte, such as a block version.
+func (r *Reader) ReadVarint8() (uint8, error) {
+	off := r.off
+	v, err := r.ReadVarint()
+	if err != nil {
+		return 0, err
+	}
+	if v > 0xff {
+		r.off = off
+		return 0, r.Fail(VarintOverflow)
+	}
+	return uint8(v), nil
+}
+
+// PeekTag returns the next byte without reading it, usually a variant tag.
+func (r *Reader) PeekTag() (byte, error) {
+	if r.Len() < 1 {
+		return 0, r.Fail(InvalidBlobLength)
+	}
+	return r.b[r.off], nil
+}
+
+// ReadTag reads a single byte.
+func (r *Reader) ReadTag() (byte, error) {
+	tag, err := r.PeekTag()
+	if err == nil {
+		r.off++
+	}
+	return tag, err
+}
+
+// ExpectTag reads a variant tag, failing with UnexpectedTag unless it's tag.
+func (r *Reader) ExpectTag(tag byte) error {
+	read, err := r.PeekTag()
+	if err != nil {
+		return err
+	}
+	if read != tag {
+		return r.Fail(UnexpectedTag)
+	}
+	r.off++
+	return nil
+}
+
+// ReadUint16 reads a little endian POD.
+func (r *Reader) ReadUint16() (uint16, error) {
+	b, err := r.ReadBlob(2)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint16(b), nil
+}
+
+// ReadUint32 reads a little endian POD.
+func (r *Reader) ReadUint32() (uint32, error) {
+	b, err := r.ReadBlob(4)
+	if err != nil {
+		return 0, err
+	}
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+// ReadPOD fills dst, such as a hash or key.
+func (r *Reader) ReadPOD(dst []byte) error {
+	b, err := r.ReadBlob(uint64(len(dst)))
+	if err != nil {
+		return err
+	}
+	copy(dst, b)
+	return nil
+}
+
+// ReadBlob reads n bytes.
+func (r *Reader) ReadBlob(n uint64) ([]byte, error) {
+	if uint64(r.Len()) < n {
+		return nil, r.Fail(InvalidBlobLength)
+	}
+	b := r.b[r.off : r.off+int(n)]
+	r.off += int(n)
+	return b, nil
+}
+
+// ReadVector reads a varint length followed by that many bytes.
+func (r *Reader) ReadVector() ([]byte, error) {
+	off := r.off
+	n, err := r.ReadVarint()
+	if err != nil {
+		return nil, err
+	}
+	b, err := r.ReadBlob(n)
+	if err != nil {
+		r.off = off
+		return nil, r.Fail(InvalidBlobLength)
+	}
+	return b, nil
+}
+
+// ReadCount reads the varint element count of a container whose elements take at least minSize bytes each, failing
+// rather than returning a count the rest of the blob couldn't hold.
+func (r *Reader) ReadCount(minSize uint64) (uint64, error) {
+	off := r.off
+	n, err := r.ReadVarint()
+	if err != nil {
+		return 0, err
+	}
+	if minSize > 0 && n > uint64(r.Len())/minSize {
+		r.off = off
+		return 0, r.Fail(InvalidBlobLength)
+	}
+	return n, nil
+}
+
+// ReadHashes reads n hashes.
+func (r *Reader) ReadHashes(n uint64) ([][32]byte, error) {
+	if n > uint64(r.Len())/32 {
+		return nil, r.Fail(InvalidBlobLength)
+	}
+	var hashes [][32]byte
+	if n > 0 {
+		hashes = make([][32]byte, n)
+	}
+	for i := range hashes {
+		r.off += copy(hashes[i][:], r.b[r.off:])
+	}
+	return hashes, nil
+}
+
+// ReadRest reads everything left.
+func (r *Reader) ReadRest() []byte {
+	b := r.b[r.off:]
+	r.off = len(r.b)
+	return b
+}
@@ -0,0 +1,145 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"testing"
+)
+
+// Mainnet transaction 45b27c7c, with one input, two tagged outputs and BP+ and CLSAG signatures
+const tx45b27c7c = "020001020010899cde23f4c8800784cf8e07e2af04d48a0cbdb142ccbb0c965bf79901bd8505bbfb01a18401a6a60189138034cf11045fdea0ca6f106cb9dd9da659d31af2f7f08ba79b10148a6f5d1f424d7107c5020003eec278d5d419e41e67815bb71ebd7a223a89230137e98a2368c5ba2852ac65abbd00032dee302749c79fef24c6129397308a35844de5710674c4ddfacc71eb00e5e1ef4c2c011dead87c2a407674d80d93b7804384ad59361d3b01629dce957ebe23fdbd5205020901c43add31d092c19b0680f1d03a401150d04a7559e8807036489f864b379f00a45bd1925d25b9fd031f494b1aeb1e091a28d2d98d61c4391a31631951589265a6b33c5476eb5820b4f0c7f2ac469041b8647436cb92ec1d53cc661d470601f9b297c178988f2352daf8340a0fd53baf91b582c6d4aa7a8518b767577a57724e90af645534fdc1fa04e9ce4f1c4be026afb329a4f591530511131e5e0ae43b68faf877b14200d7d1d9086ea29bd68d0183209edc92d394209a5191d2fe49cc5b7bde842eaa416b132c7ace699f6070d7cd4fec92b8925fa413b22a3282a100bd624df12f7ce700bf82a0c36844909ab76876e46195018bfbaf239697443f00cf8df38c1f7ed066df4ef7670ca9db12ac431b2492759ff2ba8860313c1cca0c073aed2bef3e8245d565f92187de80f170b6a5466b77a27ea0eb4bcdecb2e6bb0d881b042fefd3fffa0578c90ce33d0b19b82e57d4a1053e2a9dc37634b2fb895e2f810d81002721b392c1a353772103698c5f719abd9a5b977e7f3b28ddd4796845d7310bc6e98f3c3cf307ad74e05046a24cd935c52d08b254bf8f6a654641793655498f5eae8d665729e1cb010437bbc5f2fc35d83ea52c30c6695bd2e0bd8db2d6279237322ab8889d49266ac3b55654b10c18ad626adcfe38a1a0423dda13c0fd48a8f8ecd5725911f5904e680d067cb40214183ede1796523ba5d083ba590707849d559d2e7dceff780bf0abd17ba521ec37a4d2b67f9fea9a995c2f9c94018e72c26cf10b93350fc3931a1467577a0335f7780d4c6f1b5767b34eff9a1e306706c32703cbfcb633a310e010166f819c200d93799b3cb61d95366329894b6d880c39130b0b63b97c9cb8c564e76c0d739f798b804c30d6514dd8c3c78490316e43067ec4b59f28d63eb04efd68acae0d1499c03b66c04beaf7021710106117310adee51daa00ba916020181e969aaa8fe223769d1c9875d88875166015bc3440cca9b41155b29bf643305d3b580012a431b249714991762a1725877ffe6e3a0c58b9b4eb2ec059f515eb2667947db621361e68f9dcd754135c559bb22038094443e5285e74a76e999d1de61ab5dd23442995ec082e6e17bb77d2d478c62f0a40a241e8a3a6fd3eded5705608dcb753b020f54c5f3364d3e64628f2e5619f0e7a33e9d35392c211855cee063cf42e0c3242a47abe9bb94446925e72c0a4ab037e5b562a34c5aed8581cd01ef7d4e9784cfc180845fe55fbd16359574644bd03d2c7452df728556b1e47bf3923f4084dc8c6bcbbea2006115a9138105713b303f3074e6874ad1dc7f7c0eddf4eb9ae3ac53a4cd15eb92783ef364a6bd86ff402ac2f1b59cf2eb3dc5d41f66dadb899f30a32d548adac4edbedfe1531a57d7e0bbef8280c7fdd5f0c571fab82c31a1eeaff2727d905cf8fd1fc0cb3acc3186d07e552853bc1af71068a9c4826a372bcae861a52848d65eb9c40953d53bbc6170ed60ebbb590744fbe887364a54d4faf8c523306c5c4f60dcf96f510b2d9567401b05f6e5c0e17605d5a109239df3f67731a1d996b35ea227f1e7b41630be3b00d2218adc2b83bf4fac6482c4d5da0b1e55470a6b491a66df9cc72e8d72ac92507920d03d7ca70bbe5290da49cd369bfbb6276b42bdbf02787266af005011960059614e552792be8f717ca556cb1e83bd877fa5f3df055b8e4e9dbf7204fe26a07347357b6bd172d245121ecdf4616f0dd1332c8b3da54b5ab61fe7d71aacb810dc8c826a82de08cd2eeb458a6695742589cbb09ae5e710542cc2873dc393ac205b831181566b7c10118811fde0fd71de09b4b055a84bb6f011a72df2d670e0b6a6e6a5760dd579f55b863174753b379123d18f75aa6d3681b6ab66a9c269ff466"
+
+func recordedTransaction(t *testing.T) Transaction {
+	blob, _ := hex.DecodeString(tx45b27c7c)
+	var tx Transaction
+	if err := Unmarshal(blob, &tx); err != nil {
+		t.Fatal(err)
+	}
+	return tx
+}
+
+func TestArchiveRoundTrip(t *testing.T) {
+	tx := recordedTransaction(t)
+	blob := Marshal(tx)
+	if !bytes.Equal(blob, tx.Serialize()) {
+		t.Fatalf("Expected Marshal to match Serialize")
+	}
+	var got Transaction
+	if err := Unmarshal(blob, &got); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(Marshal(got), blob) {
+		t.Fatalf("Expected %x back, got %x", blob, Marshal(got))
+	}
+	if got.RctSignatures.Type != RctTypeBulletproofPlus || got.RctSignatures.TxnFee != 122960000 {
+		t.Fatalf("Expected the RingCT type and fee, got %+v", got.RctSignatures)
+	}
+	if got.TransactionsOut[1].TaggedKey.ViewTag != 0x4c || got.TransactionsIn[0].Key.KeyOffsets[2] != 14919556 {
+		t.Fatalf("Expected the inputs and outputs back, got %+v", got.TransactionPrefix)
+	}
+
+	b := Block{
+		BlockHeader: BlockHeader{MajorVersion: 16, MinorVersion: 16, Timestamp: 1650000000, Nonce: 0xdeadbeef},
+		MinerTxn: Transaction{TransactionPrefix: TransactionPrefix{
+			Version:         2,
+			UnlockTime:      100,
+			TransactionsIn:  []TransactionIn{{Genesis: TransactionInGenesis{Height: 40, Used: true}}},
+			TransactionsOut: []TransactionOut{{Amount: 600000000000, Key: TransactionOutToKey{Used: true}}},
+			Extra:           []byte{},
+		}},
+		TxnHashes: [][32]byte{{1}, {2}},
+	}
+	blob = b.Serialize()
+	var gotBlock Block
+	if err := Unmarshal(blob, &gotBlock); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(gotBlock.Serialize(), blob) || gotBlock.Nonce != b.Nonce || len(gotBlock.TxnHashes) != 2 {
+		t.Fatalf("Expected the block back, got %+v", gotBlock)
+	}
+}
+
+func TestArchiveErrors(t *testing.T) {
+	blob, _ := hex.DecodeString(tx45b27c7c)
+	for _, c := range []struct {
+		blob   []byte
+		err    error
+		offset int
+	}{
+		// Cut in the key image of the only input, which starts after the version, unlock time, tag, amount and offsets
+		{blob[:60], InvalidBlobLength, 53},
+		// A miner transaction, whose null RingCT signature is a single byte
+		{[]byte{2, 100, 1, 0xff, 40, 0, 0, 0, 0}, TrailingData, 8},
+		{[]byte{2, 0, 1, 0x01, 0, 0}, UnsupportedInput, 3},
+		{[]byte{1, 0, 1, 0x02, 0x80}, VarintTruncated, 4},
+		{[]byte{2, 0x80, 0x00}, VarintNotCanonical, 1},
+		{append([]byte{2, 0, 0, 1, 0, 0x05}, make([]byte, 32)...), UnsupportedOutput, 5},
+	} {
+		var tx Transaction
+		err := Unmarshal(c.blob, &tx)
+		var archiveErr *ArchiveError
+		if !errors.Is(err, c.err) || !errors.As(err, &archiveErr) || archiveErr.Offset != c.offset {
+			t.Fatalf("Expected %v at offset %d reading %x, got %v", c.err, c.offset, c.blob, err)
+		}
+	}
+
+	r := NewReader([]byte{0x02})
+	if err := r.ExpectTag(0x03); !errors.Is(err, UnexpectedTag) || r.Offset() != 0 {
+		t.Fatalf("Expected UnexpectedTag without reading the tag, got %v", err)
+	}
+	if _, err := NewReader([]byte{0x80, 0x02}).ReadVarint8(); !errors.Is(err, VarintOverflow) {
+		t.Fatalf("Expected VarintOverflow reading 256 into a byte, got %v", err)
+	}
+	if _, err := NewReader([]byte{0xff, 0xff, 0xff, 0x0f}).ReadCount(32); !errors.Is(err, InvalidBlobLength) {
+		t.Fatalf("Expected InvalidBlobLength for a count the blob can't hold, got %v", err)
+	}
+}
+
+type failingWriter struct {
+	written int
+	limit   int
+}
+
+var writeFailed = errors.New("write failed")
+
+func (f *failingWriter) Write(b []byte) (int, error) {
+	if f.written+len(b) > f.limit {
+		return 0, writeFailed
+	}
+	f.written += len(b)
+	return len(b), nil
+}
+
+func TestStreamWriter(t *testing.T) {
+	tx := recordedTransaction(t)
+	var buf bytes.Buffer
+	w := NewStreamWriter(&buf)
+	tx.WriteArchive(w)
+	if w.Err() != nil || w.Bytes() != nil || w.Len() != buf.Len() || !bytes.Equal(buf.Bytes(), Marshal(tx)) {
+		t.Fatalf("Expected the stream to match Marshal, got %x, %v", buf.Bytes(), w.Err())
+	}
+
+	fw := &failingWriter{limit: 10}
+	w = NewStreamWriter(fw)
+	tx.WriteArchive(w)
+	if w.Err() != writeFailed || w.Len() != fw.written || w.Len() > 10 {
+		t.Fatalf("Expected the first write error and nothing after it, got %v after %d bytes", w.Err(), w.Len())
+	}
+}
+
+// Every type should be checked to implement Serializable
+var _ = []Serializable{&Block{}, &ForknoteBlock{}, &WowneroBlock{}, &OxenBlock{}, &Transaction{}, &OxenTransaction{}, &Extra{}, &ExtraField{}}
+
+func TestWriterAllocations(t *testing.T) {
+	tx := recordedTransaction(t)
+	w := NewStreamWriter(ioutil.Discard)
+	allocs := testing.AllocsPerRun(100, func() {
+		w.n = 0
+		tx.WriteArchive(w)
+	})
+	if allocs != 0 {
+		t.Fatalf("Expected writing to a stream not to allocate, got %v allocations", allocs)
+	}
+}
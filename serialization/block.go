@@ -1,34 +1,49 @@
 package serialization
 
-import (
-	"encoding/binary"
-)
-
 type BlockHeader struct {
 	MajorVersion uint8
 	MinorVersion uint8
 	Timestamp    uint64
 	PreviousID   [32]byte
-	Nonce        uint32
+	// Nonce is the little endian value of the nonce's 4 bytes, the value the daemon reports.  It used to be read big
+	// endian, so nonces stored or compared before then are byte swapped.
+	Nonce uint32
 }
 
 func (bh BlockHeader) Serialize() []byte {
-	var s []byte
+	w := NewWriter()
+	bh.WriteArchive(w)
+	return w.Bytes()
+}
 
-	s = WriteUint(s, uint64(bh.MajorVersion))
-	s = WriteUint(s, uint64(bh.MinorVersion))
-	s = WriteUint(s, bh.Timestamp)
+func (bh BlockHeader) WriteArchive(w *Writer) {
+	w.WriteVarint(uint64(bh.MajorVersion))
+	w.WriteVarint(uint64(bh.MinorVersion))
+	w.WriteVarint(bh.Timestamp)
 
 	// Previous Block ID
-	var tempBlob []byte = make([]byte, 32)
-	copy(tempBlob[:], bh.PreviousID[:])
-	s = append(s, tempBlob...)
+	w.WriteBlob(bh.PreviousID[:])
 
-	// Nonce
-	binary.BigEndian.PutUint32(tempBlob, bh.Nonce)
-	s = append(s, tempBlob[0:4]...)
+	// Nonce, a little endian POD rather than a varint
+	w.WriteUint32(bh.Nonce)
+}
 
-	return s
+func (bh *BlockHeader) ReadArchive(r *Reader) error {
+	var err error
+	if bh.MajorVersion, err = r.ReadVarint8(); err != nil {
+		return err
+	}
+	if bh.MinorVersion, err = r.ReadVarint8(); err != nil {
+		return err
+	}
+	if bh.Timestamp, err = r.ReadVarint(); err != nil {
+		return err
+	}
+	if err = r.ReadPOD(bh.PreviousID[:]); err != nil {
+		return err
+	}
+	bh.Nonce, err = r.ReadUint32()
+	return err
 }
 
 type Block struct {
@@ -38,17 +53,46 @@ type Block struct {
 }
 
 func (b Block) Serialize() []byte {
-	var s []byte = b.BlockHeader.Serialize()
-	s = append(s, b.MinerTxn.Serialize()...)
-	s = append(s, byte(len(b.TxnHashes)))
+	w := NewWriter()
+	b.WriteArchive(w)
+	return w.Bytes()
+}
 
-	for _, e := range b.TxnHashes {
-		s = append(s, e[:]...)
-	}
+func (b Block) WriteArchive(w *Writer) {
+	b.BlockHeader.WriteArchive(w)
+	b.MinerTxn.WriteArchive(w)
+	writeHashes(w, b.TxnHashes)
+}
 
-	return s
+// ReadArchive reads a block.  The miner transaction's extra refers back into the blob.
+func (b *Block) ReadArchive(r *Reader) error {
+	if err := b.BlockHeader.ReadArchive(r); err != nil {
+		return err
+	}
+	if err := b.MinerTxn.ReadArchive(r); err != nil {
+		return err
+	}
+	var err error
+	b.TxnHashes, err = readHashes(r)
+	return err
 }
 
 func (b Block) GetBlob() []byte {
 	return b.Serialize()
 }
+
+// writeHashes writes a vector of hashes, the count is a varint like every other container length.
+func writeHashes(w *Writer, hashes [][32]byte) {
+	w.WriteVarint(uint64(len(hashes)))
+	for _, e := range hashes {
+		w.WriteBlob(e[:])
+	}
+}
+
+func readHashes(r *Reader) ([][32]byte, error) {
+	n, err := r.ReadCount(32)
+	if err != nil {
+		return nil, err
+	}
+	return r.ReadHashes(n)
+}
@@ -0,0 +1,409 @@
+package serialization
+
+import (
+	"errors"
+)
+
+var (
+	InvalidExtraField = errors.New("tx_extra field is malformed")
+)
+
+// tx_extra field tags, from tx_extra.h
+const (
+	ExtraTagPadding              byte = 0x00
+	ExtraTagPublicKey            byte = 0x01
+	ExtraTagNonce                byte = 0x02
+	ExtraTagMergeMining          byte = 0x03
+	ExtraTagAdditionalPublicKeys byte = 0x04
+	ExtraTagMysteriousMinergate  byte = 0xde
+)
+
+// Limits and payment ID markers, from tx_extra.h
+const (
+	ExtraPaddingMaxCount              = 255
+	ExtraNonceMaxCount                = 255
+	ExtraNoncePaymentID          byte = 0x00
+	ExtraNonceEncryptedPaymentID byte = 0x01
+)
+
+// ExtraPadding is a run of zero bytes, Size includes the tag byte.  Padding is always the last field.
+type ExtraPadding struct {
+	Size int
+	Used bool
+}
+
+func (ep ExtraPadding) WriteArchive(w *Writer) {
+	w.WriteBlob(make([]byte, ep.Size))
+}
+
+// ReadArchive reads padding, which runs to the end of the extra and has to be all zeroes.
+func (ep *ExtraPadding) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(ExtraTagPadding); err != nil {
+		return err
+	}
+	for _, e := range r.b[r.off:] {
+		if e != 0 {
+			return r.Fail(InvalidExtraField)
+		}
+	}
+	size := r.Len() + 1
+	if size > ExtraPaddingMaxCount {
+		return r.Fail(InvalidExtraField)
+	}
+	r.ReadRest()
+	*ep = ExtraPadding{Size: size, Used: true}
+	return nil
+}
+
+// ExtraPublicKey is the transaction public key used by recipients to derive their one time keys.
+type ExtraPublicKey struct {
+	PublicKey [32]byte
+	Used      bool
+}
+
+func (epk ExtraPublicKey) WriteArchive(w *Writer) {
+	w.WriteTag(ExtraTagPublicKey)
+	w.WriteBlob(epk.PublicKey[:])
+}
+
+func (epk *ExtraPublicKey) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(ExtraTagPublicKey); err != nil {
+		return err
+	}
+	if err := r.ReadPOD(epk.PublicKey[:]); err != nil {
+		return err
+	}
+	epk.Used = true
+	return nil
+}
+
+// ExtraNonce is free form data, pools reserve space here for their own per job nonces.
+type ExtraNonce struct {
+	Nonce []byte
+	Used  bool
+}
+
+func (en ExtraNonce) WriteArchive(w *Writer) {
+	w.WriteTag(ExtraTagNonce)
+	w.WriteVector(en.Nonce)
+}
+
+func (en *ExtraNonce) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(ExtraTagNonce); err != nil {
+		return err
+	}
+	nonce, err := r.ReadVector()
+	if err != nil {
+		return err
+	}
+	if len(nonce) > ExtraNonceMaxCount {
+		return r.Fail(InvalidExtraField)
+	}
+	*en = ExtraNonce{Nonce: append([]byte(nil), nonce...), Used: true}
+	return nil
+}
+
+// PaymentID returns the unencrypted payment ID stored in the nonce, if there is one.
+func (en ExtraNonce) PaymentID() ([32]byte, bool) {
+	var id [32]byte
+	if len(en.Nonce) != len(id)+1 || en.Nonce[0] != ExtraNoncePaymentID {
+		return id, false
+	}
+	copy(id[:], en.Nonce[1:])
+	return id, true
+}
+
+// EncryptedPaymentID returns the encrypted payment ID stored in the nonce, if there is one.
+func (en ExtraNonce) EncryptedPaymentID() ([8]byte, bool) {
+	var id [8]byte
+	if len(en.Nonce) != len(id)+1 || en.Nonce[0] != ExtraNonceEncryptedPaymentID {
+		return id, false
+	}
+	copy(id[:], en.Nonce[1:])
+	return id, true
+}
+
+// SetPaymentID replaces the nonce with an unencrypted payment ID.
+func (en *ExtraNonce) SetPaymentID(id [32]byte) {
+	en.Nonce = append([]byte{ExtraNoncePaymentID}, id[:]...)
+	en.Used = true
+}
+
+// SetEncryptedPaymentID replaces the nonce with an encrypted payment ID.
+func (en *ExtraNonce) SetEncryptedPaymentID(id [8]byte) {
+	en.Nonce = append([]byte{ExtraNonceEncryptedPaymentID}, id[:]...)
+	en.Used = true
+}
+
+// ExtraMergeMiningTag commits to the merkle root of merge mined chains.
+type ExtraMergeMiningTag struct {
+	Depth      uint64
+	MerkleRoot [32]byte
+	Used       bool
+}
+
+func (emm ExtraMergeMiningTag) WriteArchive(w *Writer) {
+	// The tag is stored as a string, so the depth and root are prefixed by their combined length
+	inner := NewWriter()
+	inner.WriteVarint(emm.Depth)
+	inner.WriteBlob(emm.MerkleRoot[:])
+	w.WriteTag(ExtraTagMergeMining)
+	w.WriteVector(inner.Bytes())
+}
+
+func (emm *ExtraMergeMiningTag) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(ExtraTagMergeMining); err != nil {
+		return err
+	}
+	data, err := r.ReadVector()
+	if err != nil {
+		return err
+	}
+	inner := NewReader(data)
+	if emm.Depth, err = inner.ReadVarint(); err != nil {
+		return r.Fail(InvalidExtraField)
+	}
+	if inner.Len() != len(emm.MerkleRoot) {
+		return r.Fail(InvalidExtraField)
+	}
+	copy(emm.MerkleRoot[:], inner.ReadRest())
+	emm.Used = true
+	return nil
+}
+
+// ExtraAdditionalPublicKeys are the per output public keys used when paying to subaddresses.
+type ExtraAdditionalPublicKeys struct {
+	PublicKeys [][32]byte
+	Used       bool
+}
+
+func (eapk ExtraAdditionalPublicKeys) WriteArchive(w *Writer) {
+	w.WriteTag(ExtraTagAdditionalPublicKeys)
+	w.WriteVarint(uint64(len(eapk.PublicKeys)))
+	for _, e := range eapk.PublicKeys {
+		w.WriteBlob(e[:])
+	}
+}
+
+func (eapk *ExtraAdditionalPublicKeys) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(ExtraTagAdditionalPublicKeys); err != nil {
+		return err
+	}
+	count, err := r.ReadCount(32)
+	if err != nil {
+		return err
+	}
+	if eapk.PublicKeys, err = r.ReadHashes(count); err != nil {
+		return err
+	}
+	if eapk.PublicKeys == nil {
+		eapk.PublicKeys = [][32]byte{}
+	}
+	eapk.Used = true
+	return nil
+}
+
+// ExtraMysteriousMinergate is an opaque field minergate used to put into its transactions.
+type ExtraMysteriousMinergate struct {
+	Data []byte
+	Used bool
+}
+
+func (emm ExtraMysteriousMinergate) WriteArchive(w *Writer) {
+	w.WriteTag(ExtraTagMysteriousMinergate)
+	w.WriteVector(emm.Data)
+}
+
+func (emm *ExtraMysteriousMinergate) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(ExtraTagMysteriousMinergate); err != nil {
+		return err
+	}
+	data, err := r.ReadVector()
+	if err != nil {
+		return err
+	}
+	*emm = ExtraMysteriousMinergate{Data: append([]byte(nil), data...), Used: true}
+	return nil
+}
+
+// ExtraField is a single tx_extra field, only the member flagged as Used is valid.  The Oxen members are only found in
+// Loki and Oxen transactions.
+type ExtraField struct {
+	Padding              ExtraPadding
+	PublicKey            ExtraPublicKey
+	Nonce                ExtraNonce
+	MergeMiningTag       ExtraMergeMiningTag
+	AdditionalPublicKeys ExtraAdditionalPublicKeys
+	MysteriousMinergate  ExtraMysteriousMinergate
+	OxenServiceNodeKey   ExtraOxenServiceNodeKey
+	OxenContributor      ExtraOxenContributor
+	OxenTxSecretKey      ExtraOxenTxSecretKey
+	OxenBurn             ExtraOxenBurn
+}
+
+func (ef ExtraField) WriteArchive(w *Writer) {
+	switch {
+	case ef.Padding.Used:
+		ef.Padding.WriteArchive(w)
+	case ef.PublicKey.Used:
+		ef.PublicKey.WriteArchive(w)
+	case ef.Nonce.Used:
+		ef.Nonce.WriteArchive(w)
+	case ef.MergeMiningTag.Used:
+		ef.MergeMiningTag.WriteArchive(w)
+	case ef.AdditionalPublicKeys.Used:
+		ef.AdditionalPublicKeys.WriteArchive(w)
+	case ef.MysteriousMinergate.Used:
+		ef.MysteriousMinergate.WriteArchive(w)
+	case ef.OxenServiceNodeKey.Used:
+		ef.OxenServiceNodeKey.WriteArchive(w)
+	case ef.OxenContributor.Used:
+		ef.OxenContributor.WriteArchive(w)
+	case ef.OxenTxSecretKey.Used:
+		ef.OxenTxSecretKey.WriteArchive(w)
+	case ef.OxenBurn.Used:
+		ef.OxenBurn.WriteArchive(w)
+	}
+}
+
+func (ef *ExtraField) ReadArchive(r *Reader) error {
+	tag, err := r.PeekTag()
+	if err != nil {
+		return err
+	}
+	switch tag {
+	case ExtraTagPadding:
+		return ef.Padding.ReadArchive(r)
+	case ExtraTagPublicKey:
+		return ef.PublicKey.ReadArchive(r)
+	case ExtraTagNonce:
+		return ef.Nonce.ReadArchive(r)
+	case ExtraTagMergeMining:
+		return ef.MergeMiningTag.ReadArchive(r)
+	case ExtraTagAdditionalPublicKeys:
+		return ef.AdditionalPublicKeys.ReadArchive(r)
+	case ExtraTagMysteriousMinergate:
+		return ef.MysteriousMinergate.ReadArchive(r)
+	case ExtraTagOxenServiceNodeWinner, ExtraTagOxenServiceNodePubkey:
+		return ef.OxenServiceNodeKey.ReadArchive(r)
+	case ExtraTagOxenServiceNodeContributor:
+		return ef.OxenContributor.ReadArchive(r)
+	case ExtraTagOxenTxSecretKey:
+		return ef.OxenTxSecretKey.ReadArchive(r)
+	case ExtraTagOxenBurn:
+		return ef.OxenBurn.ReadArchive(r)
+	}
+	return r.Fail(InvalidExtraField)
+}
+
+// Extra is a parsed tx_extra.  Anything after the last field that could be parsed is kept in Unparsed, so that
+// re-serializing always reproduces the original blob.
+type Extra struct {
+	Fields   []ExtraField
+	Unparsed []byte
+}
+
+// ParseExtra splits a tx_extra blob into its fields.  Like the daemon it stops at the first unknown or malformed
+// field, the remainder is kept as is rather than treated as an error.
+func ParseExtra(b []byte) Extra {
+	var e Extra
+	e.ReadArchive(NewReader(b))
+	return e
+}
+
+// ReadArchive reads fields up to the end of the blob, keeping whatever follows the last field that could be read in
+// Unparsed.  It never fails.
+func (e *Extra) ReadArchive(r *Reader) error {
+	for r.Len() > 0 {
+		start := r.Offset()
+		var f ExtraField
+		if err := f.ReadArchive(r); err != nil {
+			r.off = start
+			break
+		}
+		e.Fields = append(e.Fields, f)
+	}
+	if r.Len() > 0 {
+		e.Unparsed = append([]byte(nil), r.ReadRest()...)
+	}
+	return nil
+}
+
+// Serialize re-emits the extra, fields first and then any unparsed trailing data.
+func (e Extra) Serialize() []byte {
+	w := NewWriter()
+	e.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (e Extra) WriteArchive(w *Writer) {
+	for _, f := range e.Fields {
+		f.WriteArchive(w)
+	}
+	w.WriteBlob(e.Unparsed)
+}
+
+// FieldOffset returns the offset of field i's tag byte within the serialized extra.
+func (e Extra) FieldOffset(i int) int {
+	w := NewWriter()
+	for _, f := range e.Fields[:i] {
+		f.WriteArchive(w)
+	}
+	return w.Len()
+}
+
+// PublicKey returns the first transaction public key in the extra.
+func (e Extra) PublicKey() ([32]byte, bool) {
+	for _, f := range e.Fields {
+		if f.PublicKey.Used {
+			return f.PublicKey.PublicKey, true
+		}
+	}
+	return [32]byte{}, false
+}
+
+// Nonce returns the first extra nonce along with the offset of its data within the serialized extra.  This is the
+// reserved area the daemon leaves in block templates.
+func (e Extra) Nonce() (ExtraNonce, int, bool) {
+	for i, f := range e.Fields {
+		if f.Nonce.Used {
+			// Skip the tag and the length varint
+			offset := e.FieldOffset(i) + 1 + len(WriteUint(nil, uint64(len(f.Nonce.Nonce))))
+			return f.Nonce, offset, true
+		}
+	}
+	return ExtraNonce{}, 0, false
+}
+
+// MergeMiningTag returns the first merge mining tag in the extra.
+func (e Extra) MergeMiningTag() (ExtraMergeMiningTag, bool) {
+	for _, f := range e.Fields {
+		if f.MergeMiningTag.Used {
+			return f.MergeMiningTag, true
+		}
+	}
+	return ExtraMergeMiningTag{}, false
+}
+
+// SetMergeMiningTag replaces the first merge mining tag in the extra, or adds one after the public key and nonce.
+func (e *Extra) SetMergeMiningTag(tag ExtraMergeMiningTag) {
+	tag.Used = true
+	at := len(e.Fields)
+	for i, f := range e.Fields {
+		if f.MergeMiningTag.Used {
+			e.Fields[i].MergeMiningTag = tag
+			return
+		}
+		if at == len(e.Fields) && !f.PublicKey.Used && !f.Nonce.Used {
+			at = i
+		}
+	}
+	e.Fields = append(e.Fields, ExtraField{})
+	copy(e.Fields[at+1:], e.Fields[at:])
+	e.Fields[at] = ExtraField{MergeMiningTag: tag}
+}
+
+// ExtraFields parses the transaction's extra.
+func (tp TransactionPrefix) ExtraFields() Extra {
+	return ParseExtra(tp.Extra)
+}
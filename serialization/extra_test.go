@@ -0,0 +1,138 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/hex"
+	"testing"
+)
+
+// Miner transaction extra from a block template, a public key followed by a 17 byte reserved nonce.
+const templateExtra = "01c251fe844c41d6e3429efe40d017c7963bb15a4816c6ff611bf8cf3e0ac7bd5702110000000000000000000000000000000000"
+
+func TestParseExtraTemplate(t *testing.T) {
+	blob, _ := hex.DecodeString(templateExtra)
+	e := ParseExtra(blob)
+	if len(e.Fields) != 2 || len(e.Unparsed) != 0 {
+		t.Fatalf("Expected two fields and nothing unparsed, got %d fields and %d unparsed bytes", len(e.Fields), len(e.Unparsed))
+	}
+	pk, ok := e.PublicKey()
+	if !ok || hex.EncodeToString(pk[:]) != templateExtra[2:66] {
+		t.Fatalf("Failed to find the transaction public key, got %x", pk)
+	}
+	n, offset, ok := e.Nonce()
+	if !ok || len(n.Nonce) != 17 || offset != 35 {
+		t.Fatalf("Failed to find the reserved nonce, got %d bytes at offset %d", len(n.Nonce), offset)
+	}
+	if !bytes.Equal(e.Serialize(), blob) {
+		t.Fatal("Failed to re-serialize the extra into the original blob")
+	}
+}
+
+func TestExtraBuilder(t *testing.T) {
+	var e Extra
+	var pk [32]byte
+	pk[0] = 0xaa
+
+	var nonce ExtraField
+	nonce.Nonce.SetEncryptedPaymentID([8]byte{1, 2, 3, 4, 5, 6, 7, 8})
+
+	e.Fields = append(e.Fields,
+		ExtraField{PublicKey: ExtraPublicKey{PublicKey: pk, Used: true}},
+		nonce,
+		ExtraField{MergeMiningTag: ExtraMergeMiningTag{Depth: 3, MerkleRoot: pk, Used: true}},
+		ExtraField{AdditionalPublicKeys: ExtraAdditionalPublicKeys{PublicKeys: [][32]byte{pk, pk}, Used: true}},
+		ExtraField{MysteriousMinergate: ExtraMysteriousMinergate{Data: []byte{9, 9, 9}, Used: true}},
+		ExtraField{Padding: ExtraPadding{Size: 4, Used: true}},
+	)
+	blob := e.Serialize()
+
+	parsed := ParseExtra(blob)
+	if len(parsed.Fields) != len(e.Fields) || len(parsed.Unparsed) != 0 {
+		t.Fatalf("Expected %d fields back, got %d and %d unparsed bytes", len(e.Fields), len(parsed.Fields), len(parsed.Unparsed))
+	}
+	if id, ok := parsed.Fields[1].Nonce.EncryptedPaymentID(); !ok || id != [8]byte{1, 2, 3, 4, 5, 6, 7, 8} {
+		t.Fatalf("Failed to read back the encrypted payment ID, got %x", id)
+	}
+	if _, ok := parsed.Fields[1].Nonce.PaymentID(); ok {
+		t.Fatal("Encrypted payment ID was read as an unencrypted one")
+	}
+	if mm := parsed.Fields[2].MergeMiningTag; !mm.Used || mm.Depth != 3 || mm.MerkleRoot != pk {
+		t.Fatal("Failed to read back the merge mining tag")
+	}
+	if len(parsed.Fields[3].AdditionalPublicKeys.PublicKeys) != 2 {
+		t.Fatal("Failed to read back the additional public keys")
+	}
+	if parsed.Fields[5].Padding.Size != 4 {
+		t.Fatalf("Expected 4 bytes of padding, got %d", parsed.Fields[5].Padding.Size)
+	}
+	if !bytes.Equal(parsed.Serialize(), blob) {
+		t.Fatal("Failed to re-serialize the extra into the original blob")
+	}
+}
+
+func TestParseExtraUnknownTrailingData(t *testing.T) {
+	blob, _ := hex.DecodeString(templateExtra)
+	// An unknown tag, and a public key cut short
+	for _, trailing := range [][]byte{{0x7f, 1, 2, 3}, {0x01, 1, 2, 3}} {
+		withTrailing := append(append([]byte{}, blob...), trailing...)
+		e := ParseExtra(withTrailing)
+		if len(e.Fields) != 2 || !bytes.Equal(e.Unparsed, trailing) {
+			t.Fatalf("Expected two fields and %x unparsed, got %d fields and %x", trailing, len(e.Fields), e.Unparsed)
+		}
+		if !bytes.Equal(e.Serialize(), withTrailing) {
+			t.Fatal("Failed to re-serialize the extra with its trailing data into the original blob")
+		}
+	}
+}
+
+func TestSetMergeMiningTag(t *testing.T) {
+	blob, _ := hex.DecodeString(templateExtra)
+	e := ParseExtra(blob)
+	e.Fields = append(e.Fields, ExtraField{Padding: ExtraPadding{Size: 2, Used: true}})
+	if _, ok := e.MergeMiningTag(); ok {
+		t.Fatal("Found a merge mining tag in an extra without one")
+	}
+
+	e.SetMergeMiningTag(ExtraMergeMiningTag{Depth: 1, MerkleRoot: [32]byte{1}})
+	if len(e.Fields) != 4 || !e.Fields[2].MergeMiningTag.Used {
+		t.Fatalf("Expected the tag between the nonce and the padding, got %+v", e.Fields)
+	}
+	e.SetMergeMiningTag(ExtraMergeMiningTag{Depth: 2, MerkleRoot: [32]byte{2}})
+	parsed := ParseExtra(e.Serialize())
+	if tag, ok := parsed.MergeMiningTag(); !ok || len(parsed.Fields) != 4 || tag.Depth != 2 || tag.MerkleRoot != [32]byte{2} {
+		t.Fatalf("Expected the tag to be replaced, got %+v", parsed.Fields)
+	}
+}
+
+func TestParseOxenExtra(t *testing.T) {
+	// An Oxen miner transaction's extra has the winning service node's key after the public key
+	var e Extra
+	e.Fields = append(e.Fields,
+		ExtraField{PublicKey: ExtraPublicKey{PublicKey: [32]byte{1}, Used: true}},
+		ExtraField{OxenServiceNodeKey: ExtraOxenServiceNodeKey{Tag: ExtraTagOxenServiceNodeWinner, PublicKey: [32]byte{2}, Used: true}},
+		ExtraField{OxenServiceNodeKey: ExtraOxenServiceNodeKey{Tag: ExtraTagOxenServiceNodePubkey, PublicKey: [32]byte{3}, Used: true}},
+		ExtraField{OxenContributor: ExtraOxenContributor{SpendPublicKey: [32]byte{4}, ViewPublicKey: [32]byte{5}, Used: true}},
+		ExtraField{OxenTxSecretKey: ExtraOxenTxSecretKey{SecretKey: [32]byte{6}, Used: true}},
+		ExtraField{OxenBurn: ExtraOxenBurn{Amount: 0x0102030405, Used: true}},
+	)
+	blob := e.Serialize()
+	if len(blob) != 4*33+65+9 || blob[33] != ExtraTagOxenServiceNodeWinner || !bytes.Equal(blob[len(blob)-8:], []byte{5, 4, 3, 2, 1, 0, 0, 0}) {
+		t.Fatalf("Unexpected Oxen extra %x", blob)
+	}
+
+	// A state change isn't parsed, and is kept along with everything after it
+	stateChange := []byte{0x78, 4, 0x80, 0x01, 7}
+	parsed := ParseExtra(append(append([]byte{}, blob...), stateChange...))
+	if len(parsed.Fields) != len(e.Fields) || !bytes.Equal(parsed.Unparsed, stateChange) {
+		t.Fatalf("Expected %d fields and %x unparsed, got %d and %x", len(e.Fields), stateChange, len(parsed.Fields), parsed.Unparsed)
+	}
+	if k := parsed.Fields[1].OxenServiceNodeKey; k.Tag != ExtraTagOxenServiceNodeWinner || k.PublicKey != [32]byte{2} {
+		t.Fatalf("Failed to read back the winner's key, got %+v", k)
+	}
+	if parsed.Fields[5].OxenBurn.Amount != 0x0102030405 {
+		t.Fatalf("Failed to read back the burn, got %d", parsed.Fields[5].OxenBurn.Amount)
+	}
+	if !bytes.Equal(parsed.Serialize(), append(blob, stateChange...)) {
+		t.Fatal("Failed to re-serialize the extra into the original blob")
+	}
+}
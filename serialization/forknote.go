@@ -0,0 +1,160 @@
+package serialization
+
+import (
+	"errors"
+)
+
+// ForknoteParentBlockVersion is the block version Forknote blocks gained a parent block at.
+const ForknoteParentBlockVersion = 2
+
+// maxBlockchainBranchSize is the deepest aux tree a parent block's merge mining tag can commit to.
+const maxBlockchainBranchSize = 8 * 32
+
+var (
+	MissingMergeMiningTag = errors.New("parent miner transaction has no merge mining tag")
+	InvalidParentBlock    = errors.New("parent block has no transactions")
+	MergeMiningTagTooDeep = errors.New("merge mining tag is deeper than a hash has bits")
+)
+
+// ParentBlock is the block of the chain a Forknote block is merge mined with.  From block version 2 the proof of work
+// is on the parent, and the Forknote block is committed to by the merge mining tag in the parent's miner transaction.
+// Original: ParentBlockSerializer in CryptoNoteSerialization.cpp
+type ParentBlock struct {
+	MajorVersion     uint8
+	MinorVersion     uint8
+	Timestamp        uint64
+	PreviousID       [32]byte
+	Nonce            uint32
+	TxCount          uint64     // Including the miner transaction
+	MinerTxnBranch   [][32]byte // From the miner transaction to the parent's merkle root, as long as the tree is deep
+	MinerTxn         Transaction
+	BlockchainBranch [][32]byte // From the Forknote block to the aux tree root, as deep as the merge mining tag says
+}
+
+func (pb ParentBlock) WriteArchive(w *Writer) {
+	pb.header().WriteArchive(w)
+	w.WriteVarint(pb.TxCount)
+	for _, h := range pb.MinerTxnBranch {
+		w.WriteBlob(h[:])
+	}
+	pb.MinerTxn.WriteArchive(w)
+	for _, h := range pb.BlockchainBranch {
+		w.WriteBlob(h[:])
+	}
+}
+
+func (pb *ParentBlock) ReadArchive(r *Reader) error {
+	var header BlockHeader
+	if err := header.ReadArchive(r); err != nil {
+		return err
+	}
+	pb.MajorVersion, pb.MinorVersion = header.MajorVersion, header.MinorVersion
+	pb.Timestamp, pb.PreviousID, pb.Nonce = header.Timestamp, header.PreviousID, header.Nonce
+
+	var err error
+	if pb.TxCount, err = r.ReadVarint(); err != nil {
+		return err
+	}
+	if pb.TxCount < 1 {
+		return r.Fail(InvalidParentBlock)
+	}
+	// The miner transaction's branch is as long as the tree is deep, floor(log2(count)).  Original: tree_depth in
+	// tree-hash.c
+	depth := uint64(0)
+	for count := pb.TxCount; count > 1; count >>= 1 {
+		depth++
+	}
+	if pb.MinerTxnBranch, err = r.ReadHashes(depth); err != nil {
+		return err
+	}
+
+	if err = pb.MinerTxn.ReadArchive(r); err != nil {
+		return err
+	}
+	tag, ok := pb.MinerTxn.ExtraFields().MergeMiningTag()
+	if !ok {
+		return r.Fail(MissingMergeMiningTag)
+	}
+	if tag.Depth > maxBlockchainBranchSize {
+		return r.Fail(MergeMiningTagTooDeep)
+	}
+	pb.BlockchainBranch, err = r.ReadHashes(tag.Depth)
+	return err
+}
+
+// header returns the parent's header fields, which are laid out as a Monero block header.
+func (pb ParentBlock) header() BlockHeader {
+	return BlockHeader{
+		MajorVersion: pb.MajorVersion,
+		MinorVersion: pb.MinorVersion,
+		Timestamp:    pb.Timestamp,
+		PreviousID:   pb.PreviousID,
+		Nonce:        pb.Nonce,
+	}
+}
+
+// SerializeHeader returns the parent's header, the start of its hashing blob.
+func (pb ParentBlock) SerializeHeader() []byte {
+	return pb.header().Serialize()
+}
+
+// ForknoteBlock is a block of Bytecoin or one of its forks.  Up to block version 1 it's laid out as a Monero block, from
+// version 2 the header only has the versions and previous id, the timestamp and nonce having moved to the parent block.
+// Original: serialize(BlockTemplate&) in CryptoNoteSerialization.cpp
+type ForknoteBlock struct {
+	BlockHeader
+	Parent    ParentBlock // From version 2
+	MinerTxn  Transaction
+	TxnHashes [][32]byte
+}
+
+func (b ForknoteBlock) Serialize() []byte {
+	w := NewWriter()
+	b.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (b ForknoteBlock) WriteArchive(w *Writer) {
+	if b.MajorVersion < ForknoteParentBlockVersion {
+		b.BlockHeader.WriteArchive(w)
+	} else {
+		w.WriteVarint(uint64(b.MajorVersion))
+		w.WriteVarint(uint64(b.MinorVersion))
+		w.WriteBlob(b.PreviousID[:])
+		b.Parent.WriteArchive(w)
+	}
+	b.MinerTxn.WriteArchive(w)
+	writeHashes(w, b.TxnHashes)
+}
+
+func (b *ForknoteBlock) ReadArchive(r *Reader) error {
+	major, err := r.PeekTag()
+	if err != nil {
+		return err
+	}
+	// Versions are varints, but any version with a parent block fits in a byte
+	if major < ForknoteParentBlockVersion {
+		if err = b.BlockHeader.ReadArchive(r); err != nil {
+			return err
+		}
+	} else {
+		if b.MajorVersion, err = r.ReadVarint8(); err != nil {
+			return err
+		}
+		if b.MinorVersion, err = r.ReadVarint8(); err != nil {
+			return err
+		}
+		if err = r.ReadPOD(b.PreviousID[:]); err != nil {
+			return err
+		}
+		if err = b.Parent.ReadArchive(r); err != nil {
+			return err
+		}
+	}
+
+	if err = b.MinerTxn.ReadArchive(r); err != nil {
+		return err
+	}
+	b.TxnHashes, err = readHashes(r)
+	return err
+}
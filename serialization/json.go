@@ -0,0 +1,849 @@
+package serialization
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strconv"
+)
+
+// Blocks and transactions are encoded to JSON in the schema of the daemon's json_archive, as returned by get_block and
+// get_transactions with as_json.  POD fields such as hashes and keys are hex strings, variants are objects keyed by
+// their type's name and the extra is an array of numbers.  Original: serialization/json_archive.h, and the
+// BEGIN_SERIALIZE_OBJECT blocks of cryptonote_basic.h and rctTypes.h
+
+var (
+	InvalidHexLength     = errors.New("hex field has the wrong length")
+	UnsupportedRctType   = errors.New("RingCT signature type is not supported")
+	RctSignatureMismatch = errors.New("RingCT signatures don't match the transaction's inputs and outputs")
+)
+
+// Sizes of the Borromean range signature blobs, s0, s1 and ee, and the 64 commitments Ci.  Source: rctTypes.h
+const (
+	boroSigSize = 64*32*2 + 32
+	key64Size   = 64 * 32
+)
+
+// hexBlob is a POD field, which json_archive writes as a hex string.
+type hexBlob []byte
+
+func (h hexBlob) MarshalJSON() ([]byte, error) {
+	return json.Marshal(hex.EncodeToString(h))
+}
+
+func (h *hexBlob) UnmarshalJSON(b []byte) error {
+	var s string
+	if err := json.Unmarshal(b, &s); err != nil {
+		return err
+	}
+	var err error
+	*h, err = hex.DecodeString(s)
+	return err
+}
+
+// copyTo fills dst, which has to be exactly as long as h.
+func (h hexBlob) copyTo(dst []byte) error {
+	if len(h) != len(dst) {
+		return InvalidHexLength
+	}
+	copy(dst, h)
+	return nil
+}
+
+// byteArray is a blob json_archive writes as an array of numbers rather than a hex string, such as the extra.
+type byteArray []byte
+
+func (a byteArray) MarshalJSON() ([]byte, error) {
+	b := append(make([]byte, 0, 4*len(a)+2), '[')
+	for i, e := range a {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = strconv.AppendUint(b, uint64(e), 10)
+	}
+	return append(b, ']'), nil
+}
+
+func (a *byteArray) UnmarshalJSON(b []byte) error {
+	var nums []uint8
+	if err := json.Unmarshal(b, &nums); err != nil {
+		return err
+	}
+	*a = nums
+	return nil
+}
+
+// blockJSON is a block and the header fields of the coins built on Monero's, each only set for its coin.
+type blockJSON struct {
+	MajorVersion uint8                      `json:"major_version"`
+	MinorVersion uint8                      `json:"minor_version"`
+	Timestamp    uint64                     `json:"timestamp"`
+	PrevID       hexBlob                    `json:"prev_id"`
+	Nonce        uint32                     `json:"nonce"`
+	Signature    hexBlob                    `json:"signature,omitempty"` // Wownero
+	Vote         *uint16                    `json:"vote,omitempty"`      // Wownero
+	Pulse        *oxenPulseJSON             `json:"pulse,omitempty"`     // Oxen
+	MinerTx      json.RawMessage            `json:"miner_tx"`
+	TxHashes     []hexBlob                  `json:"tx_hashes"`
+	Signatures   *[]oxenQuorumSignatureJSON `json:"signatures,omitempty"` // Oxen
+}
+
+type oxenPulseJSON struct {
+	RandomValue     hexBlob `json:"random_value"`
+	Round           uint8   `json:"round"`
+	ValidatorBitset uint16  `json:"validator_bitset"`
+}
+
+type oxenQuorumSignatureJSON struct {
+	VoterIndex uint16  `json:"voter_index"`
+	Signature  hexBlob `json:"signature"`
+}
+
+// newBlockJSON sets the fields every block has.
+func newBlockJSON(h BlockHeader, minerTxn json.Marshaler, txnHashes [][32]byte) (blockJSON, error) {
+	j := blockJSON{
+		MajorVersion: h.MajorVersion,
+		MinorVersion: h.MinorVersion,
+		Timestamp:    h.Timestamp,
+		PrevID:       h.PreviousID[:],
+		Nonce:        h.Nonce,
+		TxHashes:     make([]hexBlob, len(txnHashes)),
+	}
+	for i := range txnHashes {
+		j.TxHashes[i] = txnHashes[i][:]
+	}
+	var err error
+	j.MinerTx, err = minerTxn.MarshalJSON()
+	return j, err
+}
+
+// read reads back the fields every block has.
+func (j blockJSON) read(h *BlockHeader, minerTxn json.Unmarshaler, txnHashes *[][32]byte) error {
+	*h = BlockHeader{
+		MajorVersion: j.MajorVersion,
+		MinorVersion: j.MinorVersion,
+		Timestamp:    j.Timestamp,
+		Nonce:        j.Nonce,
+	}
+	if err := j.PrevID.copyTo(h.PreviousID[:]); err != nil {
+		return err
+	}
+	if err := minerTxn.UnmarshalJSON(j.MinerTx); err != nil {
+		return err
+	}
+	*txnHashes = nil
+	if len(j.TxHashes) > 0 {
+		*txnHashes = make([][32]byte, len(j.TxHashes))
+	}
+	for i, h := range j.TxHashes {
+		if err := h.copyTo((*txnHashes)[i][:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (b Block) MarshalJSON() ([]byte, error) {
+	j, err := newBlockJSON(b.BlockHeader, b.MinerTxn, b.TxnHashes)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+func (b *Block) UnmarshalJSON(data []byte) error {
+	var j blockJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	var block Block
+	if err := j.read(&block.BlockHeader, &block.MinerTxn, &block.TxnHashes); err != nil {
+		return err
+	}
+	*b = block
+	return nil
+}
+
+// MarshalJSON encodes the block, which would otherwise be encoded as a Block without its signature and vote.
+func (b WowneroBlock) MarshalJSON() ([]byte, error) {
+	j, err := newBlockJSON(b.BlockHeader, b.MinerTxn, b.TxnHashes)
+	if err != nil {
+		return nil, err
+	}
+	if b.MajorVersion >= WowneroMinerSignatureVersion {
+		j.Signature = b.Signature[:]
+		j.Vote = &b.Vote
+	}
+	return json.Marshal(j)
+}
+
+func (b *WowneroBlock) UnmarshalJSON(data []byte) error {
+	var j blockJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	var block WowneroBlock
+	if err := j.read(&block.BlockHeader, &block.MinerTxn, &block.TxnHashes); err != nil {
+		return err
+	}
+	if block.MajorVersion >= WowneroMinerSignatureVersion {
+		if err := j.Signature.copyTo(block.Signature[:]); err != nil {
+			return err
+		}
+		if j.Vote != nil {
+			block.Vote = *j.Vote
+		}
+	}
+	*b = block
+	return nil
+}
+
+func (b OxenBlock) MarshalJSON() ([]byte, error) {
+	j, err := newBlockJSON(b.BlockHeader, b.MinerTxn, b.TxnHashes)
+	if err != nil {
+		return nil, err
+	}
+	if b.MajorVersion >= OxenPulseVersion {
+		j.Pulse = &oxenPulseJSON{RandomValue: b.Pulse.RandomValue[:], Round: b.Pulse.Round, ValidatorBitset: b.Pulse.ValidatorBitset}
+		signatures := make([]oxenQuorumSignatureJSON, len(b.Signatures))
+		for i := range b.Signatures {
+			signatures[i] = oxenQuorumSignatureJSON{VoterIndex: b.Signatures[i].VoterIndex, Signature: b.Signatures[i].Signature[:]}
+		}
+		j.Signatures = &signatures
+	}
+	return json.Marshal(j)
+}
+
+func (b *OxenBlock) UnmarshalJSON(data []byte) error {
+	var j blockJSON
+	if err := json.Unmarshal(data, &j); err != nil {
+		return err
+	}
+	var block OxenBlock
+	if err := j.read(&block.BlockHeader, &block.MinerTxn, &block.TxnHashes); err != nil {
+		return err
+	}
+	if block.MajorVersion >= OxenPulseVersion {
+		if j.Pulse != nil {
+			if err := j.Pulse.RandomValue.copyTo(block.Pulse.RandomValue[:]); err != nil {
+				return err
+			}
+			block.Pulse.Round = j.Pulse.Round
+			block.Pulse.ValidatorBitset = j.Pulse.ValidatorBitset
+		}
+		if j.Signatures != nil && len(*j.Signatures) > 0 {
+			block.Signatures = make([]OxenQuorumSignature, len(*j.Signatures))
+			for i, sig := range *j.Signatures {
+				block.Signatures[i].VoterIndex = sig.VoterIndex
+				if err := sig.Signature.copyTo(block.Signatures[i].Signature[:]); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	*b = block
+	return nil
+}
+
+// transactionJSON is a transaction, and the prefix fields Oxen adds from OxenTxVersionOutputUnlockTimes.
+type transactionJSON struct {
+	Version           uint64               `json:"version"`
+	OutputUnlockTimes *[]uint64            `json:"output_unlock_times,omitempty"` // Oxen
+	IsStateChange     *bool                `json:"is_state_change,omitempty"`     // Oxen
+	UnlockTime        uint64               `json:"unlock_time"`
+	Vin               []transactionInJSON  `json:"vin"`
+	Vout              []transactionOutJSON `json:"vout"`
+	Extra             byteArray            `json:"extra"`
+	Type              *uint64              `json:"type,omitempty"`       // Oxen
+	Signatures        *[]json.RawMessage   `json:"signatures,omitempty"` // Version 1 only
+	RctSignatures     *rctBaseJSON         `json:"rct_signatures,omitempty"`
+	RctPrunable       *rctPrunableJSON     `json:"rctsig_prunable,omitempty"`
+}
+
+// transactionInJSON is the txin_v variant, only one member is set.
+type transactionInJSON struct {
+	Gen *txinGenJSON   `json:"gen,omitempty"`
+	Key *txinToKeyJSON `json:"key,omitempty"`
+}
+
+type txinGenJSON struct {
+	Height uint64 `json:"height"`
+}
+
+type txinToKeyJSON struct {
+	Amount     uint64   `json:"amount"`
+	KeyOffsets []uint64 `json:"key_offsets"`
+	KImage     hexBlob  `json:"k_image"`
+}
+
+// transactionOutJSON is an output, its target is the txout_target_v variant.
+type transactionOutJSON struct {
+	Amount uint64 `json:"amount"`
+	Target struct {
+		Key       hexBlob             `json:"key,omitempty"`
+		TaggedKey *txoutTaggedKeyJSON `json:"tagged_key,omitempty"`
+	} `json:"target"`
+}
+
+type txoutTaggedKeyJSON struct {
+	Key     hexBlob `json:"key"`
+	ViewTag hexBlob `json:"view_tag"`
+}
+
+func (t Transaction) MarshalJSON() ([]byte, error) {
+	j, err := t.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(j)
+}
+
+func (t Transaction) toJSON() (transactionJSON, error) {
+	j := transactionJSON{
+		Version:    t.Version,
+		UnlockTime: t.UnlockTime,
+		Vin:        make([]transactionInJSON, len(t.TransactionsIn)),
+		Vout:       make([]transactionOutJSON, len(t.TransactionsOut)),
+		Extra:      t.Extra,
+	}
+	for i := range t.TransactionsIn {
+		ti := &t.TransactionsIn[i]
+		in := &j.Vin[i]
+		switch {
+		case ti.Genesis.Used:
+			in.Gen = &txinGenJSON{Height: ti.Genesis.Height}
+		case ti.Key.Used:
+			in.Key = &txinToKeyJSON{
+				Amount:     ti.Key.Amount,
+				KeyOffsets: append([]uint64{}, ti.Key.KeyOffsets...),
+				KImage:     ti.Key.KeyImage[:],
+			}
+		default:
+			return j, UnsupportedInput
+		}
+	}
+	for i := range t.TransactionsOut {
+		to := &t.TransactionsOut[i]
+		out := &j.Vout[i]
+		out.Amount = to.Amount
+		switch {
+		case to.Key.Used:
+			out.Target.Key = to.Key.PublicKey[:]
+		case to.TaggedKey.Used:
+			out.Target.TaggedKey = &txoutTaggedKeyJSON{Key: to.TaggedKey.PublicKey[:], ViewTag: []byte{to.TaggedKey.ViewTag}}
+		default:
+			return j, UnsupportedOutput
+		}
+	}
+
+	if t.Version == 1 {
+		// Only miner transactions are supported at version 1, and their inputs have no signatures
+		j.Signatures = &[]json.RawMessage{}
+	} else {
+		var err error
+		if j.RctSignatures, j.RctPrunable, err = t.RctSignatures.toJSON(len(t.TransactionsIn), len(t.TransactionsOut), t.ringSize()); err != nil {
+			return j, err
+		}
+	}
+	return j, nil
+}
+
+func (t *Transaction) UnmarshalJSON(b []byte) error {
+	var j transactionJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	return j.read(t)
+}
+
+func (j transactionJSON) read(t *Transaction) error {
+	var tx Transaction
+	tx.Version = j.Version
+	tx.UnlockTime = j.UnlockTime
+	tx.TransactionsIn = make([]TransactionIn, len(j.Vin))
+	for i, in := range j.Vin {
+		ti := &tx.TransactionsIn[i]
+		switch {
+		case in.Gen != nil:
+			ti.Genesis = TransactionInGenesis{Height: in.Gen.Height, Used: true}
+		case in.Key != nil:
+			ti.Key = TransactionInToKey{Amount: in.Key.Amount, KeyOffsets: in.Key.KeyOffsets, Used: true}
+			if err := in.Key.KImage.copyTo(ti.Key.KeyImage[:]); err != nil {
+				return err
+			}
+		default:
+			return UnsupportedInput
+		}
+	}
+	tx.TransactionsOut = make([]TransactionOut, len(j.Vout))
+	for i, out := range j.Vout {
+		to := &tx.TransactionsOut[i]
+		to.Amount = out.Amount
+		switch {
+		case out.Target.Key != nil:
+			to.Key.Used = true
+			if err := out.Target.Key.copyTo(to.Key.PublicKey[:]); err != nil {
+				return err
+			}
+		case out.Target.TaggedKey != nil:
+			to.TaggedKey.Used = true
+			if err := out.Target.TaggedKey.Key.copyTo(to.TaggedKey.PublicKey[:]); err != nil {
+				return err
+			}
+			var viewTag [1]byte
+			if err := out.Target.TaggedKey.ViewTag.copyTo(viewTag[:]); err != nil {
+				return err
+			}
+			to.TaggedKey.ViewTag = viewTag[0]
+		default:
+			return UnsupportedOutput
+		}
+	}
+	tx.Extra = j.Extra
+
+	if tx.Version == 1 {
+		if j.Signatures != nil && len(*j.Signatures) > 0 {
+			return UnsupportedTransaction
+		}
+		for _, ti := range tx.TransactionsIn {
+			if !ti.Genesis.Used {
+				return UnsupportedTransaction
+			}
+		}
+	} else {
+		var err error
+		if tx.RctSignatures, err = rctFromJSON(j.RctSignatures, j.RctPrunable, len(tx.TransactionsIn), len(tx.TransactionsOut), tx.ringSize()); err != nil {
+			return err
+		}
+	}
+	*t = tx
+	return nil
+}
+
+// MarshalJSON encodes the transaction, which would otherwise be encoded as a Transaction without its Oxen fields.
+func (t OxenTransaction) MarshalJSON() ([]byte, error) {
+	j, err := t.Transaction.toJSON()
+	if err != nil {
+		return nil, err
+	}
+	if t.Version >= OxenTxVersionOutputUnlockTimes {
+		unlockTimes := append([]uint64{}, t.OutputUnlockTimes...)
+		j.OutputUnlockTimes = &unlockTimes
+		if t.Version == OxenTxVersionOutputUnlockTimes {
+			stateChange := t.Type == OxenTxTypeStateChange
+			j.IsStateChange = &stateChange
+		}
+	}
+	if t.Version >= OxenTxVersionTypes {
+		j.Type = &t.Type
+	}
+	return json.Marshal(j)
+}
+
+func (t *OxenTransaction) UnmarshalJSON(b []byte) error {
+	var j transactionJSON
+	if err := json.Unmarshal(b, &j); err != nil {
+		return err
+	}
+	var tx OxenTransaction
+	if err := j.read(&tx.Transaction); err != nil {
+		return err
+	}
+	if tx.Version >= OxenTxVersionOutputUnlockTimes {
+		if j.OutputUnlockTimes == nil || len(*j.OutputUnlockTimes) != len(tx.TransactionsOut) {
+			return OutputUnlockTimesMismatch
+		}
+		tx.OutputUnlockTimes = *j.OutputUnlockTimes
+		if tx.Version == OxenTxVersionOutputUnlockTimes && j.IsStateChange != nil && *j.IsStateChange {
+			tx.Type = OxenTxTypeStateChange
+		}
+	}
+	if tx.Version >= OxenTxVersionTypes && j.Type != nil {
+		tx.Type = *j.Type
+	}
+	*t = tx
+	return nil
+}
+
+// ringSize returns the number of ring members each input's signature covers, taken from the first input like the
+// daemon does.
+func (t Transaction) ringSize() int {
+	if len(t.TransactionsIn) > 0 && t.TransactionsIn[0].Key.Used {
+		return len(t.TransactionsIn[0].Key.KeyOffsets)
+	}
+	return 1
+}
+
+// rctBaseJSON is what rctSigBase::serialize_rctsig_base writes, rct_signatures in the transaction.
+type rctBaseJSON struct {
+	Type       uint8      `json:"type"`
+	TxnFee     uint64     `json:"txnFee"`
+	PseudoOuts []hexBlob  `json:"pseudoOuts,omitempty"` // RctTypeSimple only, later types moved them to the prunable data
+	EcdhInfo   []ecdhJSON `json:"ecdhInfo"`
+	OutPk      []hexBlob  `json:"outPk"`
+}
+
+// ecdhJSON is an ecdhTuple.  From RctTypeBulletproof2 the mask is dropped along with all but 8 bytes of the amount,
+// which daemons since v0.18.3 write as trunc_amount and older ones as amount.
+type ecdhJSON struct {
+	Mask        hexBlob `json:"mask,omitempty"`
+	Amount      hexBlob `json:"amount,omitempty"`
+	TruncAmount hexBlob `json:"trunc_amount,omitempty"`
+}
+
+func (b rctBaseJSON) MarshalJSON() ([]byte, error) {
+	// Null signatures stop after the type
+	if b.Type == RctTypeNull {
+		return []byte(`{"type":0}`), nil
+	}
+	type plain rctBaseJSON
+	return json.Marshal(plain(b))
+}
+
+// rctPrunableJSON is what rctSigPrunable::serialize_rctsig_prunable writes, rctsig_prunable in the transaction.
+type rctPrunableJSON struct {
+	Nbp        *uint64               `json:"nbp,omitempty"`
+	Bpp        []bulletproofPlusJSON `json:"bpp,omitempty"`
+	Bp         []bulletproofJSON     `json:"bp,omitempty"`
+	RangeSigs  []rangeSigJSON        `json:"rangeSigs,omitempty"`
+	CLSAGs     []clsagJSON           `json:"CLSAGs,omitempty"`
+	MGs        []mgSigJSON           `json:"MGs,omitempty"`
+	PseudoOuts []hexBlob             `json:"pseudoOuts,omitempty"`
+}
+
+type bulletproofJSON struct {
+	A    hexBlob   `json:"A"`
+	S    hexBlob   `json:"S"`
+	T1   hexBlob   `json:"T1"`
+	T2   hexBlob   `json:"T2"`
+	Taux hexBlob   `json:"taux"`
+	Mu   hexBlob   `json:"mu"`
+	L    []hexBlob `json:"L"`
+	R    []hexBlob `json:"R"`
+	LowA hexBlob   `json:"a"`
+	LowB hexBlob   `json:"b"`
+	T    hexBlob   `json:"t"`
+}
+
+type bulletproofPlusJSON struct {
+	A  hexBlob   `json:"A"`
+	A1 hexBlob   `json:"A1"`
+	B  hexBlob   `json:"B"`
+	R1 hexBlob   `json:"r1"`
+	S1 hexBlob   `json:"s1"`
+	D1 hexBlob   `json:"d1"`
+	L  []hexBlob `json:"L"`
+	R  []hexBlob `json:"R"`
+}
+
+type rangeSigJSON struct {
+	Asig hexBlob `json:"asig"`
+	Ci   hexBlob `json:"Ci"`
+}
+
+type clsagJSON struct {
+	S  []hexBlob `json:"s"`
+	C1 hexBlob   `json:"c1"`
+	D  hexBlob   `json:"D"`
+}
+
+type mgSigJSON struct {
+	SS [][]hexBlob `json:"ss"`
+	CC hexBlob     `json:"cc"`
+}
+
+// mgLayout returns the number of MLSAG signatures of a RingCT type, and the number of columns in each one's ss.
+func mgLayout(rctType uint8, inputs int) (int, int) {
+	if rctType == RctTypeFull {
+		return 1, inputs + 1
+	}
+	return inputs, 2
+}
+
+// rctReader reads RingCT signatures field by field, keeping the first error.
+type rctReader struct {
+	r   *Reader
+	err error
+}
+
+func (rr *rctReader) blob(n uint64) hexBlob {
+	if rr.err != nil {
+		return nil
+	}
+	var b []byte
+	b, rr.err = rr.r.ReadBlob(n)
+	return b
+}
+
+func (rr *rctReader) key() hexBlob {
+	return rr.blob(32)
+}
+
+func (rr *rctReader) keys(n uint64) []hexBlob {
+	keys := []hexBlob{}
+	for i := uint64(0); i < n && rr.err == nil; i++ {
+		keys = append(keys, rr.key())
+	}
+	return keys
+}
+
+func (rr *rctReader) varint() uint64 {
+	if rr.err != nil {
+		return 0
+	}
+	var v uint64
+	v, rr.err = rr.r.ReadVarint()
+	return v
+}
+
+func (rr *rctReader) vector() []hexBlob {
+	if rr.err != nil {
+		return nil
+	}
+	var n uint64
+	n, rr.err = rr.r.ReadCount(32)
+	return rr.keys(n)
+}
+
+// toJSON splits Raw into its fields for a transaction with the given numbers of inputs, outputs and ring members.
+func (rs RctSignatures) toJSON(inputs, outputs, ringSize int) (*rctBaseJSON, *rctPrunableJSON, error) {
+	// An empty Raw is written as a null signature
+	if len(rs.Raw) == 0 {
+		return &rctBaseJSON{Type: RctTypeNull}, nil, nil
+	}
+	rr := &rctReader{r: NewReader(rs.Raw)}
+	base := new(rctBaseJSON)
+	base.Type, rr.err = rr.r.ReadTag()
+	if rr.err != nil || base.Type == RctTypeNull {
+		return base, nil, rr.err
+	}
+	if base.Type > RctTypeBulletproofPlus {
+		return nil, nil, rr.r.Fail(UnsupportedRctType)
+	}
+
+	base.TxnFee = rr.varint()
+	if base.Type == RctTypeSimple {
+		base.PseudoOuts = rr.keys(uint64(inputs))
+	}
+	base.EcdhInfo = make([]ecdhJSON, outputs)
+	for i := range base.EcdhInfo {
+		if base.Type >= RctTypeBulletproof2 {
+			base.EcdhInfo[i].TruncAmount = rr.blob(8)
+		} else {
+			base.EcdhInfo[i] = ecdhJSON{Mask: rr.key(), Amount: rr.key()}
+		}
+	}
+	base.OutPk = rr.keys(uint64(outputs))
+
+	p := new(rctPrunableJSON)
+	switch base.Type {
+	case RctTypeBulletproofPlus:
+		nbp := rr.varint()
+		p.Nbp = &nbp
+		p.Bpp = []bulletproofPlusJSON{}
+		for i := uint64(0); i < nbp && rr.err == nil; i++ {
+			p.Bpp = append(p.Bpp, bulletproofPlusJSON{
+				A: rr.key(), A1: rr.key(), B: rr.key(), R1: rr.key(), S1: rr.key(), D1: rr.key(),
+				L: rr.vector(), R: rr.vector(),
+			})
+		}
+	case RctTypeBulletproof, RctTypeBulletproof2, RctTypeCLSAG:
+		// The first bulletproof transactions stored the count as a POD
+		var nbp uint64
+		if base.Type == RctTypeBulletproof {
+			if rr.err == nil {
+				var n uint32
+				n, rr.err = rr.r.ReadUint32()
+				nbp = uint64(n)
+			}
+		} else {
+			nbp = rr.varint()
+		}
+		p.Nbp = &nbp
+		p.Bp = []bulletproofJSON{}
+		for i := uint64(0); i < nbp && rr.err == nil; i++ {
+			p.Bp = append(p.Bp, bulletproofJSON{
+				A: rr.key(), S: rr.key(), T1: rr.key(), T2: rr.key(), Taux: rr.key(), Mu: rr.key(),
+				L: rr.vector(), R: rr.vector(),
+				LowA: rr.key(), LowB: rr.key(), T: rr.key(),
+			})
+		}
+	default:
+		p.RangeSigs = make([]rangeSigJSON, outputs)
+		for i := range p.RangeSigs {
+			p.RangeSigs[i] = rangeSigJSON{Asig: rr.blob(boroSigSize), Ci: rr.blob(key64Size)}
+		}
+	}
+
+	if base.Type == RctTypeCLSAG || base.Type == RctTypeBulletproofPlus {
+		p.CLSAGs = make([]clsagJSON, inputs)
+		for i := range p.CLSAGs {
+			p.CLSAGs[i] = clsagJSON{S: rr.keys(uint64(ringSize)), C1: rr.key(), D: rr.key()}
+		}
+	} else {
+		mgs, columns := mgLayout(base.Type, inputs)
+		p.MGs = make([]mgSigJSON, mgs)
+		for i := range p.MGs {
+			p.MGs[i].SS = make([][]hexBlob, ringSize)
+			for j := range p.MGs[i].SS {
+				p.MGs[i].SS[j] = rr.keys(uint64(columns))
+			}
+			p.MGs[i].CC = rr.key()
+		}
+	}
+	if base.Type >= RctTypeBulletproof {
+		p.PseudoOuts = rr.keys(uint64(inputs))
+	}
+
+	if rr.err == nil && rr.r.Len() > 0 {
+		rr.err = rr.r.Fail(TrailingData)
+	}
+	return base, p, rr.err
+}
+
+// rctWriter writes RingCT signatures field by field, checking each field's size and keeping the first error.
+type rctWriter struct {
+	w   *Writer
+	err error
+}
+
+func (rw *rctWriter) blob(b hexBlob, n int) {
+	if rw.err == nil && len(b) != n {
+		rw.err = InvalidHexLength
+	}
+	if rw.err == nil {
+		rw.w.WriteBlob(b)
+	}
+}
+
+func (rw *rctWriter) key(k hexBlob) {
+	rw.blob(k, 32)
+}
+
+func (rw *rctWriter) keys(keys []hexBlob, n int) {
+	if rw.err == nil && len(keys) != n {
+		rw.err = RctSignatureMismatch
+	}
+	for _, k := range keys {
+		rw.key(k)
+	}
+}
+
+func (rw *rctWriter) vector(keys []hexBlob) {
+	rw.w.WriteVarint(uint64(len(keys)))
+	rw.keys(keys, len(keys))
+}
+
+// count checks that a list has as many entries as the transaction calls for.
+func (rw *rctWriter) count(n, want int) {
+	if rw.err == nil && n != want {
+		rw.err = RctSignatureMismatch
+	}
+}
+
+// rctFromJSON rebuilds the RingCT signatures of a transaction with the given numbers of inputs, outputs and ring
+// members from their JSON form.
+func rctFromJSON(base *rctBaseJSON, p *rctPrunableJSON, inputs, outputs, ringSize int) (RctSignatures, error) {
+	var rs RctSignatures
+	if base == nil {
+		return rs, RctSignatureMismatch
+	}
+	if base.Type == RctTypeNull {
+		return rs, nil
+	}
+	if base.Type > RctTypeBulletproofPlus {
+		return rs, UnsupportedRctType
+	}
+	if p == nil {
+		return rs, RctSignatureMismatch
+	}
+
+	rw := &rctWriter{w: NewWriter()}
+	rw.w.WriteTag(base.Type)
+	rw.w.WriteVarint(base.TxnFee)
+	if base.Type == RctTypeSimple {
+		rw.keys(base.PseudoOuts, inputs)
+	}
+	rw.count(len(base.EcdhInfo), outputs)
+	for _, e := range base.EcdhInfo {
+		if base.Type >= RctTypeBulletproof2 {
+			if e.TruncAmount != nil {
+				e.Amount = e.TruncAmount
+			}
+			rw.blob(e.Amount, 8)
+		} else {
+			rw.key(e.Mask)
+			rw.key(e.Amount)
+		}
+	}
+	rw.keys(base.OutPk, outputs)
+
+	switch base.Type {
+	case RctTypeBulletproofPlus:
+		if p.Nbp != nil {
+			rw.count(int(*p.Nbp), len(p.Bpp))
+		}
+		rw.w.WriteVarint(uint64(len(p.Bpp)))
+		for _, bp := range p.Bpp {
+			for _, k := range []hexBlob{bp.A, bp.A1, bp.B, bp.R1, bp.S1, bp.D1} {
+				rw.key(k)
+			}
+			rw.vector(bp.L)
+			rw.vector(bp.R)
+		}
+	case RctTypeBulletproof, RctTypeBulletproof2, RctTypeCLSAG:
+		if p.Nbp != nil {
+			rw.count(int(*p.Nbp), len(p.Bp))
+		}
+		if base.Type == RctTypeBulletproof {
+			rw.w.WriteUint32(uint32(len(p.Bp)))
+		} else {
+			rw.w.WriteVarint(uint64(len(p.Bp)))
+		}
+		for _, bp := range p.Bp {
+			for _, k := range []hexBlob{bp.A, bp.S, bp.T1, bp.T2, bp.Taux, bp.Mu} {
+				rw.key(k)
+			}
+			rw.vector(bp.L)
+			rw.vector(bp.R)
+			for _, k := range []hexBlob{bp.LowA, bp.LowB, bp.T} {
+				rw.key(k)
+			}
+		}
+	default:
+		rw.count(len(p.RangeSigs), outputs)
+		for _, rs := range p.RangeSigs {
+			rw.blob(rs.Asig, boroSigSize)
+			rw.blob(rs.Ci, key64Size)
+		}
+	}
+
+	if base.Type == RctTypeCLSAG || base.Type == RctTypeBulletproofPlus {
+		rw.count(len(p.CLSAGs), inputs)
+		for _, c := range p.CLSAGs {
+			rw.keys(c.S, ringSize)
+			rw.key(c.C1)
+			rw.key(c.D)
+		}
+	} else {
+		mgs, columns := mgLayout(base.Type, inputs)
+		rw.count(len(p.MGs), mgs)
+		for _, mg := range p.MGs {
+			rw.count(len(mg.SS), ringSize)
+			for _, ss := range mg.SS {
+				rw.keys(ss, columns)
+			}
+			rw.key(mg.CC)
+		}
+	}
+	if base.Type >= RctTypeBulletproof {
+		rw.keys(p.PseudoOuts, inputs)
+	}
+	if rw.err != nil {
+		return rs, rw.err
+	}
+
+	// Reading the blob back fills in the fee and bulletproof sizes the way parsing the transaction would have
+	err := rs.read(NewReader(rw.w.Bytes()), outputs)
+	return rs, err
+}
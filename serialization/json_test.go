@@ -0,0 +1,309 @@
+package serialization
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+)
+
+// Mainnet block 2751506 and the json get_block returned alongside it, with the whitespace removed
+const (
+	block2751506     = "1010c58bab9b06b27bdecfc6cd0a46172d136c08831cf67660377ba992332363228b1b722781e7807e07f502cef8a70101ff92f8a7010180e0a596bb1103d7cbf826b665d7a532c316982dc8dbc24f285cbc18bbcc27c7164cd9b3277a85d034019f629d8b36bd16a2bfce3ea80c31dc4d8762c67165aec21845494e32b7582fe00211000000297a787a000000000000000000000000"
+	block2751506JSON = `{"major_version":16,"minor_version":16,"timestamp":1667941829,"prev_id":"b27bdecfc6cd0a46172d136c08831cf67660377ba992332363228b1b722781e7","nonce":4110909056,"miner_tx":{"version":2,"unlock_time":2751566,"vin":[{"gen":{"height":2751506}}],"vout":[{"amount":600000000000,"target":{"tagged_key":{"key":"d7cbf826b665d7a532c316982dc8dbc24f285cbc18bbcc27c7164cd9b3277a85","view_tag":"d0"}}}],"extra":[1,159,98,157,139,54,189,22,162,191,206,62,168,12,49,220,77,135,98,198,113,101,174,194,24,69,73,78,50,183,88,47,224,2,17,0,0,0,41,122,120,122,0,0,0,0,0,0,0,0,0,0],"rct_signatures":{"type":0}},"tx_hashes":[]}`
+)
+
+// The as_json a v0.18.3 daemon's get_transactions returned for tx45b27c7c, and a second mainnet transaction with two
+// outputs and BP+ and CLSAG signatures from the transaction pool of an older daemon, which wrote ecdhInfo's truncated
+// amounts as amount rather than trunc_amount.
+const (
+	tx45b27c7cJSON = `{"version":2,"unlock_time":0,"vin":[{"key":{"amount":0,"key_offsets":[74944009,14689396,14919556,71650,197972,1087677,204236,11670,19703,82621,32187,16929,21286,2441,6656,2255],"k_image":"045fdea0ca6f106cb9dd9da659d31af2f7f08ba79b10148a6f5d1f424d7107c5"}}],"vout":[{"amount":0,"target":{"tagged_key":{"key":"eec278d5d419e41e67815bb71ebd7a223a89230137e98a2368c5ba2852ac65ab","view_tag":"bd"}}},{"amount":0,"target":{"tagged_key":{"key":"2dee302749c79fef24c6129397308a35844de5710674c4ddfacc71eb00e5e1ef","view_tag":"4c"}}}],"extra":[1,29,234,216,124,42,64,118,116,216,13,147,183,128,67,132,173,89,54,29,59,1,98,157,206,149,126,190,35,253,189,82,5,2,9,1,196,58,221,49,208,146,193,155],"rct_signatures":{"type":6,"txnFee":122960000,"ecdhInfo":[{"trunc_amount":"401150d04a7559e8"},{"trunc_amount":"807036489f864b37"}],"outPk":["9f00a45bd1925d25b9fd031f494b1aeb1e091a28d2d98d61c4391a3163195158","9265a6b33c5476eb5820b4f0c7f2ac469041b8647436cb92ec1d53cc661d4706"]},"rctsig_prunable":{"nbp":1,"bpp":[{"A":"f9b297c178988f2352daf8340a0fd53baf91b582c6d4aa7a8518b767577a5772","A1":"4e90af645534fdc1fa04e9ce4f1c4be026afb329a4f591530511131e5e0ae43b","B":"68faf877b14200d7d1d9086ea29bd68d0183209edc92d394209a5191d2fe49cc","r1":"5b7bde842eaa416b132c7ace699f6070d7cd4fec92b8925fa413b22a3282a100","s1":"bd624df12f7ce700bf82a0c36844909ab76876e46195018bfbaf239697443f00","d1":"cf8df38c1f7ed066df4ef7670ca9db12ac431b2492759ff2ba8860313c1cca0c","L":["3aed2bef3e8245d565f92187de80f170b6a5466b77a27ea0eb4bcdecb2e6bb0d","881b042fefd3fffa0578c90ce33d0b19b82e57d4a1053e2a9dc37634b2fb895e","2f810d81002721b392c1a353772103698c5f719abd9a5b977e7f3b28ddd47968","45d7310bc6e98f3c3cf307ad74e05046a24cd935c52d08b254bf8f6a65464179","3655498f5eae8d665729e1cb010437bbc5f2fc35d83ea52c30c6695bd2e0bd8d","b2d6279237322ab8889d49266ac3b55654b10c18ad626adcfe38a1a0423dda13","c0fd48a8f8ecd5725911f5904e680d067cb40214183ede1796523ba5d083ba59"],"R":["07849d559d2e7dceff780bf0abd17ba521ec37a4d2b67f9fea9a995c2f9c9401","8e72c26cf10b93350fc3931a1467577a0335f7780d4c6f1b5767b34eff9a1e30","6706c32703cbfcb633a310e010166f819c200d93799b3cb61d95366329894b6d","880c39130b0b63b97c9cb8c564e76c0d739f798b804c30d6514dd8c3c7849031","6e43067ec4b59f28d63eb04efd68acae0d1499c03b66c04beaf7021710106117","310adee51daa00ba916020181e969aaa8fe223769d1c9875d88875166015bc34","40cca9b41155b29bf643305d3b580012a431b249714991762a1725877ffe6e3a"]}],"CLSAGs":[{"s":["0c58b9b4eb2ec059f515eb2667947db621361e68f9dcd754135c559bb2203809","4443e5285e74a76e999d1de61ab5dd23442995ec082e6e17bb77d2d478c62f0a","40a241e8a3a6fd3eded5705608dcb753b020f54c5f3364d3e64628f2e5619f0e","7a33e9d35392c211855cee063cf42e0c3242a47abe9bb94446925e72c0a4ab03","7e5b562a34c5aed8581cd01ef7d4e9784cfc180845fe55fbd16359574644bd03","d2c7452df728556b1e47bf3923f4084dc8c6bcbbea2006115a9138105713b303","f3074e6874ad1dc7f7c0eddf4eb9ae3ac53a4cd15eb92783ef364a6bd86ff402","ac2f1b59cf2eb3dc5d41f66dadb899f30a32d548adac4edbedfe1531a57d7e0b","bef8280c7fdd5f0c571fab82c31a1eeaff2727d905cf8fd1fc0cb3acc3186d07","e552853bc1af71068a9c4826a372bcae861a52848d65eb9c40953d53bbc6170e","d60ebbb590744fbe887364a54d4faf8c523306c5c4f60dcf96f510b2d9567401","b05f6e5c0e17605d5a109239df3f67731a1d996b35ea227f1e7b41630be3b00d","2218adc2b83bf4fac6482c4d5da0b1e55470a6b491a66df9cc72e8d72ac92507","920d03d7ca70bbe5290da49cd369bfbb6276b42bdbf02787266af00501196005","9614e552792be8f717ca556cb1e83bd877fa5f3df055b8e4e9dbf7204fe26a07","347357b6bd172d245121ecdf4616f0dd1332c8b3da54b5ab61fe7d71aacb810d"],"c1":"c8c826a82de08cd2eeb458a6695742589cbb09ae5e710542cc2873dc393ac205","D":"b831181566b7c10118811fde0fd71de09b4b055a84bb6f011a72df2d670e0b6a"}],"pseudoOuts":["6e6a5760dd579f55b863174753b379123d18f75aa6d3681b6ab66a9c269ff466"]}}`
+	tx793da061     = "020001020010f0c2ca03c5be0af1cb4080d3058db20bdba801d38507f86adc32df2aac04d10aa603f703d00128fc5655d843ed8b30a3563bbff1d02b606b089b1725c717823b0898c52f0478730200030993e6ca2d66871e4869adb2c3a524ad7205fcd3e0b3339daafaea76fc5518ee1b000384f3dd9b4e7df18c5662606a4f6a11ceede3f0cefb41a8586e691baf2930a6fcff2c01b984318d464e56b443af22d5f880470606435172a3bad71966e2a4bae5d18a8002090190d13c4c7d9222d206b0b8ea288b2fe303da838a84779fe795bc0ba77509cd23fa0e8ec03a348ed6e80386c93c276ef69f1c223f811ffc6ce1e88c030a28ceaa373700ce1aeb4167861ec41494edf53f3d7b7568fa7ac05db0aaf324da012644a5380b8de1652a3d47654ecee118eca9506655e77fb0e339aef31da452dd360227a720fca490111110bb23126a49cf783cb67ab8cd91de4891db2e7898ab6923bc04f5917dbe17dd5e6ef9d248cd7bb01afb4675eef4bc8fb7707c7a470ae1bd93860a4ad45f2d1ca2bddefa4f1598cf20be56051cae5b61c3f379f6160e1298b6aeaa25fdfb8631a32dd9bf8efeb66387304516e8bd00599caaa8a77104600b39b3e3f9390e7f6cb61062021d2e8d7f6a6fbb7b04318f35077a3243390f07b8bdee2c2c2997f46c9dd024f5bad1004a52cc8cbcb051fcc63de46476962fd79bce03d001b6ed12d6417ae5871e2a05574316ac53050712cf4129c5b00534798facd82baf29aaa8a96dd3e04cf6c742544b3aa6b37bce394c416869be0bb145f64be9871eda186cdce9c8fefec3cda6a70574492c42ff4c998e82f494192f02f98a7ecc762c59608409508924bed2665b53c20b93fb3338c2edad582ca19ef77cc02f17f547386b014b1ad6a79df59130f71c05cf7f50abd447c01249afdd7ffafdf6f43138b4905838243884fe16216df87300e1bf5e20e78ecea69bc53e1a07c2da698b34dce738ec74a2cba0b130378d1cf15a3697566a59bbcea9a082cd16e72907754e50b6b3daa866f459634f8e53ba531953c227309cf8f7a7fbfaac2daa5a4811b347f89eb981f331b752313aa8dc7aad366a40bc3e2ef68c51733e0e228769927c8d8eaccd0640a02916604234e7a1b1cc7f7e8311815452668becfc3d76332ea1de6ee160660fc310148d49135b718e611d1ade4146dd813253928721c48f76ca5d59d19b257afdd8c2d5abfe1c905ec00c34d150b90a52683c58d33506f70f64346d5ca69a26007689eb79755e9953f21bce011087d065ca137e4bdfae579e248336f3d39f4a880823b68e571ca8c3adbbd91fb90be2f5c7832007b39e788f94f3ccd48dc6b09d87d3b3d71c0a6df53658969b5a18d7864be6a00ab356d93b50cd3aae005c891cb72047726b7a40228bd1ac547f08b0ba2b5b630a693582bb3a5e39ebe2a66b44d5fe856875efffec516e2ca5229fb9689a92c1087cfabb788fc5925f23a45b675e28ff696009d928d25e3edce01703135ffc6404159297800e32b019ee70b15e73d4d91d4c439ad13bde42eee8f59120aedf0607b95ba55a6497a52e476718d0f4c8353190418fe6b2f4cc7050ced06451fb6d049e92a46ad7d55fe6aaf07faa17d791d7ee8ca2ac49e98417392575857bcdc206c72d57a1933434c5cd8b5fa167cb7d8b512347956fd6bc60caeb269f30beb60e5991d37f9543d81b0cd4a04087b8fbc19eb98102d3b460608da705354ac28a0a923382f6792d746b9c7bc5f7f00b01bebcf3a173c78c268872feb49422d8840e541f7c83b4da45bf3289eb36772444e08e703347313ab0500614c8b571b35d07279006100ed62a32e592071e8e749895090e27c347f2567bfbace5a7823100007b29c0c7d11657ead227902d6a95e855cf38a63bdd963fe99f80c7a5da27fc0b7f7fd35f789b110cac086707a498f03b692ec210a2a52f90114827bb8b53da058f443440db05a72ccaa68ac8cc022b067e122c563b5c277703fecac7bb876609ef5c502d5ab8701c613b7ee3ed20069681e0e98b54169e4a0e2f165ee1fc9e0e6213c0f6e752de084e9f90a492d1a5b42fe2b82ebd4f1f1228dfcaea591d4271c39fb4de4c13906eb11eb2da196165ac075f5d797301cb5f88e80023532a063f"
+	tx793da061JSON = `{"version":2,"unlock_time":0,"vin":[{"key":{"amount":0,"key_offsets":[7512432,171845,1058289,92544,186637,21595,115411,13688,6492,5471,556,1361,422,503,208,40],"k_image":"fc5655d843ed8b30a3563bbff1d02b606b089b1725c717823b0898c52f047873"}}],"vout":[{"amount":0,"target":{"tagged_key":{"key":"0993e6ca2d66871e4869adb2c3a524ad7205fcd3e0b3339daafaea76fc5518ee","view_tag":"1b"}}},{"amount":0,"target":{"tagged_key":{"key":"84f3dd9b4e7df18c5662606a4f6a11ceede3f0cefb41a8586e691baf2930a6fc","view_tag":"ff"}}}],"extra":[1,185,132,49,141,70,78,86,180,67,175,34,213,248,128,71,6,6,67,81,114,163,186,215,25,102,226,164,186,229,209,138,128,2,9,1,144,209,60,76,125,146,34,210],"rct_signatures":{"type":6,"txnFee":85630000,"ecdhInfo":[{"amount":"8b2fe303da838a84"},{"amount":"779fe795bc0ba775"}],"outPk":["09cd23fa0e8ec03a348ed6e80386c93c276ef69f1c223f811ffc6ce1e88c030a","28ceaa373700ce1aeb4167861ec41494edf53f3d7b7568fa7ac05db0aaf324da"]},"rctsig_prunable":{"nbp":1,"bpp":[{"A":"2644a5380b8de1652a3d47654ecee118eca9506655e77fb0e339aef31da452dd","A1":"360227a720fca490111110bb23126a49cf783cb67ab8cd91de4891db2e7898ab","B":"6923bc04f5917dbe17dd5e6ef9d248cd7bb01afb4675eef4bc8fb7707c7a470a","r1":"e1bd93860a4ad45f2d1ca2bddefa4f1598cf20be56051cae5b61c3f379f6160e","s1":"1298b6aeaa25fdfb8631a32dd9bf8efeb66387304516e8bd00599caaa8a77104","d1":"600b39b3e3f9390e7f6cb61062021d2e8d7f6a6fbb7b04318f35077a3243390f","L":["b8bdee2c2c2997f46c9dd024f5bad1004a52cc8cbcb051fcc63de46476962fd7","9bce03d001b6ed12d6417ae5871e2a05574316ac53050712cf4129c5b0053479","8facd82baf29aaa8a96dd3e04cf6c742544b3aa6b37bce394c416869be0bb145","f64be9871eda186cdce9c8fefec3cda6a70574492c42ff4c998e82f494192f02","f98a7ecc762c59608409508924bed2665b53c20b93fb3338c2edad582ca19ef7","7cc02f17f547386b014b1ad6a79df59130f71c05cf7f50abd447c01249afdd7f","fafdf6f43138b4905838243884fe16216df87300e1bf5e20e78ecea69bc53e1a"],"R":["c2da698b34dce738ec74a2cba0b130378d1cf15a3697566a59bbcea9a082cd16","e72907754e50b6b3daa866f459634f8e53ba531953c227309cf8f7a7fbfaac2d","aa5a4811b347f89eb981f331b752313aa8dc7aad366a40bc3e2ef68c51733e0e","228769927c8d8eaccd0640a02916604234e7a1b1cc7f7e8311815452668becfc","3d76332ea1de6ee160660fc310148d49135b718e611d1ade4146dd8132539287","21c48f76ca5d59d19b257afdd8c2d5abfe1c905ec00c34d150b90a52683c58d3","3506f70f64346d5ca69a26007689eb79755e9953f21bce011087d065ca137e4b"]}],"CLSAGs":[{"s":["dfae579e248336f3d39f4a880823b68e571ca8c3adbbd91fb90be2f5c7832007","b39e788f94f3ccd48dc6b09d87d3b3d71c0a6df53658969b5a18d7864be6a00a","b356d93b50cd3aae005c891cb72047726b7a40228bd1ac547f08b0ba2b5b630a","693582bb3a5e39ebe2a66b44d5fe856875efffec516e2ca5229fb9689a92c108","7cfabb788fc5925f23a45b675e28ff696009d928d25e3edce01703135ffc6404","159297800e32b019ee70b15e73d4d91d4c439ad13bde42eee8f59120aedf0607","b95ba55a6497a52e476718d0f4c8353190418fe6b2f4cc7050ced06451fb6d04","9e92a46ad7d55fe6aaf07faa17d791d7ee8ca2ac49e98417392575857bcdc206","c72d57a1933434c5cd8b5fa167cb7d8b512347956fd6bc60caeb269f30beb60e","5991d37f9543d81b0cd4a04087b8fbc19eb98102d3b460608da705354ac28a0a","923382f6792d746b9c7bc5f7f00b01bebcf3a173c78c268872feb49422d8840e","541f7c83b4da45bf3289eb36772444e08e703347313ab0500614c8b571b35d07","279006100ed62a32e592071e8e749895090e27c347f2567bfbace5a782310000","7b29c0c7d11657ead227902d6a95e855cf38a63bdd963fe99f80c7a5da27fc0b","7f7fd35f789b110cac086707a498f03b692ec210a2a52f90114827bb8b53da05","8f443440db05a72ccaa68ac8cc022b067e122c563b5c277703fecac7bb876609"],"c1":"ef5c502d5ab8701c613b7ee3ed20069681e0e98b54169e4a0e2f165ee1fc9e0e","D":"6213c0f6e752de084e9f90a492d1a5b42fe2b82ebd4f1f1228dfcaea591d4271"}],"pseudoOuts":["c39fb4de4c13906eb11eb2da196165ac075f5d797301cb5f88e80023532a063f"]}}`
+)
+
+func TestBlockJSON(t *testing.T) {
+	blob, _ := hex.DecodeString(block2751506)
+	var b Block
+	if err := Unmarshal(blob, &b); err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != block2751506JSON {
+		t.Fatalf("Expected %s, got %s", block2751506JSON, got)
+	}
+
+	var back Block
+	if err = json.Unmarshal([]byte(block2751506JSON), &back); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(back.Serialize(), blob) {
+		t.Fatalf("Expected %x back, got %x", blob, back.Serialize())
+	}
+
+	// Version 1 miner transactions have an empty list of signatures rather than RingCT signatures
+	b.MinerTxn.Version = 1
+	if got, err = json.Marshal(b.MinerTxn); err != nil || !bytes.HasSuffix(got, []byte(`0,0,0,0],"signatures":[]}`)) {
+		t.Fatalf("Expected empty signatures, got %s, %v", got, err)
+	}
+}
+
+func TestRecordedTransactionJSON(t *testing.T) {
+	blob, _ := hex.DecodeString(tx45b27c7c)
+	var tx Transaction
+	if err := Unmarshal(blob, &tx); err != nil {
+		t.Fatal(err)
+	}
+	got, err := json.Marshal(tx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != tx45b27c7cJSON {
+		t.Fatalf("Expected %s, got %s", tx45b27c7cJSON, got)
+	}
+
+	for _, c := range []struct{ blob, json string }{{tx45b27c7c, tx45b27c7cJSON}, {tx793da061, tx793da061JSON}} {
+		blob, _ := hex.DecodeString(c.blob)
+		var back Transaction
+		if err = json.Unmarshal([]byte(c.json), &back); err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(Marshal(back), blob) {
+			t.Fatalf("Expected %x back, got %x", blob, Marshal(back))
+		}
+	}
+}
+
+// testRctRaw lays out RingCT signatures of type rctType for a transaction with the given numbers of inputs, outputs and
+// ring members, with a single bulletproof of 7 rounds for the types that have them.  Every key is different, so that a
+// field read out of order shows up.
+func testRctRaw(rctType uint8, inputs, outputs, ringSize int) []byte {
+	w := NewWriter()
+	var next uint16
+	keys := func(n int) {
+		for i := 0; i < n; i++ {
+			next++
+			w.WriteBlob([]byte{byte(next), byte(next >> 8)})
+			w.WriteBlob(make([]byte, 30))
+		}
+	}
+	vector := func(n int) {
+		w.WriteVarint(uint64(n))
+		keys(n)
+	}
+
+	w.WriteTag(rctType)
+	w.WriteVarint(30720000)
+	if rctType == RctTypeSimple {
+		keys(inputs)
+	}
+	for i := 0; i < outputs; i++ {
+		if rctType >= RctTypeBulletproof2 {
+			w.WriteBlob([]byte{1, 2, 3, 4, 5, 6, 7, byte(i)})
+		} else {
+			keys(2)
+		}
+	}
+	keys(outputs)
+
+	switch rctType {
+	case RctTypeBulletproofPlus:
+		w.WriteVarint(1)
+		keys(6)
+		vector(7)
+		vector(7)
+	case RctTypeBulletproof, RctTypeBulletproof2, RctTypeCLSAG:
+		if rctType == RctTypeBulletproof {
+			w.WriteUint32(1)
+		} else {
+			w.WriteVarint(1)
+		}
+		keys(6)
+		vector(7)
+		vector(7)
+		keys(3)
+	default:
+		for i := 0; i < outputs; i++ {
+			w.WriteBlob(bytes.Repeat([]byte{byte(i + 1)}, boroSigSize+key64Size))
+		}
+	}
+
+	if rctType == RctTypeCLSAG || rctType == RctTypeBulletproofPlus {
+		for i := 0; i < inputs; i++ {
+			keys(ringSize + 2)
+		}
+	} else {
+		mgs, columns := mgLayout(rctType, inputs)
+		for i := 0; i < mgs; i++ {
+			keys(ringSize*columns + 1)
+		}
+	}
+	if rctType >= RctTypeBulletproof {
+		keys(inputs)
+	}
+	return w.Bytes()
+}
+
+// testRctTransaction puts the signatures testRctRaw lays out on the prefix of tx45b27c7c, with its input repeated, so
+// that the RingCT types no recorded transaction covers can be checked
+func testRctTransaction(t *testing.T, rctType uint8) Transaction {
+	tx := recordedTransaction(t)
+	tx.TransactionsIn = append(tx.TransactionsIn, tx.TransactionsIn[0])
+	tx.RctSignatures = RctSignatures{Raw: testRctRaw(rctType, 2, 2, len(tx.TransactionsIn[0].Key.KeyOffsets))}
+	return tx
+}
+
+func TestTransactionJSON(t *testing.T) {
+	for _, c := range []struct {
+		rctType  uint8
+		prunable []string
+	}{
+		{RctTypeFull, []string{"rangeSigs", "MGs"}},
+		{RctTypeSimple, []string{"rangeSigs", "MGs"}},
+		{RctTypeBulletproof, []string{"nbp", "bp", "MGs", "pseudoOuts"}},
+		{RctTypeBulletproof2, []string{"nbp", "bp", "MGs", "pseudoOuts"}},
+		{RctTypeCLSAG, []string{"nbp", "bp", "CLSAGs", "pseudoOuts"}},
+		{RctTypeBulletproofPlus, []string{"nbp", "bpp", "CLSAGs", "pseudoOuts"}},
+	} {
+		blob := Marshal(testRctTransaction(t, c.rctType))
+		var tx Transaction
+		if err := Unmarshal(blob, &tx); err != nil {
+			t.Fatalf("Type %d: %v", c.rctType, err)
+		}
+		got, err := json.Marshal(tx)
+		if err != nil {
+			t.Fatalf("Type %d: %v", c.rctType, err)
+		}
+
+		var fields struct {
+			Vin           []map[string]json.RawMessage `json:"vin"`
+			RctSignatures map[string]json.RawMessage   `json:"rct_signatures"`
+			RctPrunable   map[string]json.RawMessage   `json:"rctsig_prunable"`
+		}
+		if err = json.Unmarshal(got, &fields); err != nil {
+			t.Fatal(err)
+		}
+		if len(fields.Vin) != 2 || fields.Vin[0]["key"] == nil || string(fields.RctSignatures["txnFee"]) != "30720000" {
+			t.Fatalf("Type %d: expected key inputs and the fee, got %s", c.rctType, got)
+		}
+		if len(fields.RctPrunable) != len(c.prunable) {
+			t.Fatalf("Type %d: expected prunable fields %v, got %s", c.rctType, c.prunable, got)
+		}
+		for _, f := range c.prunable {
+			if fields.RctPrunable[f] == nil {
+				t.Fatalf("Type %d: expected prunable field %s, got %s", c.rctType, f, got)
+			}
+		}
+
+		var back Transaction
+		if err = json.Unmarshal(got, &back); err != nil {
+			t.Fatalf("Type %d: %v", c.rctType, err)
+		}
+		if !bytes.Equal(Marshal(back), blob) {
+			t.Fatalf("Type %d: expected %x back, got %x", c.rctType, blob, Marshal(back))
+		}
+		if back.RctSignatures.Type != c.rctType || back.RctSignatures.TxnFee != 30720000 {
+			t.Fatalf("Type %d: expected the type and fee read back, got %+v", c.rctType, back.RctSignatures)
+		}
+	}
+}
+
+func TestTransactionJSONErrors(t *testing.T) {
+	got := []byte(tx45b27c7cJSON)
+	for _, c := range []struct {
+		from, to string
+		err      error
+	}{
+		{`"k_image":"04`, `"k_image":"`, InvalidHexLength},
+		{`{"key":{"amount"`, `{"ring":{"amount"`, UnsupportedInput},
+		{`"target":{"tagged_key"`, `"target":{"script"`, UnsupportedOutput},
+		{`"ecdhInfo":[{"trunc_amount":"401150d04a7559e8"},`, `"ecdhInfo":[`, RctSignatureMismatch},
+		{`"nbp":1`, `"nbp":2`, RctSignatureMismatch},
+		{`"type":6`, `"type":7`, UnsupportedRctType},
+	} {
+		if !bytes.Contains(got, []byte(c.from)) {
+			t.Fatalf("Expected %s in %s", c.from, got)
+		}
+		var tx Transaction
+		if err := json.Unmarshal(bytes.Replace(got, []byte(c.from), []byte(c.to), 1), &tx); !errors.Is(err, c.err) {
+			t.Fatalf("Expected %v replacing %s, got %v", c.err, c.from, err)
+		}
+	}
+}
+
+func TestForkBlockJSON(t *testing.T) {
+	minerTxn := Transaction{TransactionPrefix: TransactionPrefix{
+		Version:         2,
+		UnlockTime:      100,
+		TransactionsIn:  []TransactionIn{{Genesis: TransactionInGenesis{Height: 40, Used: true}}},
+		TransactionsOut: []TransactionOut{{Amount: 600000000000, Key: TransactionOutToKey{PublicKey: [32]byte{0xcd}, Used: true}}},
+		Extra:           []byte{1, 255, 0},
+	}}
+	header := BlockHeader{MajorVersion: 18, MinorVersion: 18, Timestamp: 1650000000, PreviousID: [32]byte{0xab}, Nonce: 7}
+	oxenTxn := OxenTransaction{Transaction: minerTxn, OutputUnlockTimes: []uint64{100}, Type: OxenTxTypeStateChange}
+	oxenTxn.Version = OxenTxVersionOutputUnlockTimes
+	oxenBlock := OxenBlock{
+		BlockHeader: header,
+		Pulse:       OxenPulse{RandomValue: [16]byte{0x12}, Round: 3, ValidatorBitset: 0x0fff},
+		MinerTxn:    oxenTxn,
+		TxnHashes:   [][32]byte{{0xef}},
+		Signatures:  []OxenQuorumSignature{{VoterIndex: 5, Signature: [64]byte{0x56}}},
+	}
+	zeroes := strings.Repeat("00", 63)
+	for _, c := range []struct {
+		block    interface{}
+		back     interface{ Serialize() []byte }
+		expected []string
+	}{
+		{
+			WowneroBlock{Block: Block{BlockHeader: header, MinerTxn: minerTxn}, Signature: [64]byte{0x34}, Vote: 2},
+			&WowneroBlock{},
+			[]string{`"nonce":7,"signature":"34` + zeroes + `","vote":2,"miner_tx"`},
+		},
+		{
+			oxenBlock,
+			&OxenBlock{},
+			[]string{
+				`"nonce":7,"pulse":{"random_value":"12` + strings.Repeat("00", 15) + `","round":3,"validator_bitset":4095},`,
+				`"miner_tx":{"version":3,"output_unlock_times":[100],"is_state_change":true,"unlock_time":100,`,
+				`"signatures":[{"voter_index":5,"signature":"56` + zeroes + `"}]}`,
+			},
+		},
+	} {
+		got, err := json.Marshal(c.block)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, e := range c.expected {
+			if !bytes.Contains(got, []byte(e)) {
+				t.Fatalf("Expected %s in %s", e, got)
+			}
+		}
+		if err = json.Unmarshal(got, c.back); err != nil {
+			t.Fatal(err)
+		}
+		expected := Marshal(c.block.(Marshaler))
+		if !bytes.Equal(c.back.Serialize(), expected) {
+			t.Fatalf("Expected %x back, got %x", expected, c.back.Serialize())
+		}
+	}
+
+	// Version 4 transactions have a type after the extra instead of the state change flag
+	oxenTxn.Version, oxenTxn.Type = OxenTxVersionTypes, 4
+	got, err := json.Marshal(oxenTxn)
+	if err != nil || bytes.Contains(got, []byte("is_state_change")) || !bytes.Contains(got, []byte(`"extra":[1,255,0],"type":4,`)) {
+		t.Fatalf("Expected the type after the extra, got %s, %v", got, err)
+	}
+	var back OxenTransaction
+	if err = json.Unmarshal(got, &back); err != nil || !bytes.Equal(back.Serialize(), oxenTxn.Serialize()) {
+		t.Fatalf("Expected %x back, got %x, %v", oxenTxn.Serialize(), back.Serialize(), err)
+	}
+	if err = json.Unmarshal(bytes.Replace(got, []byte("[100]"), []byte("[]"), 1), &back); err != OutputUnlockTimesMismatch {
+		t.Fatalf("Expected %v, got %v", OutputUnlockTimesMismatch, err)
+	}
+}
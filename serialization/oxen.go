@@ -0,0 +1,343 @@
+package serialization
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// OxenPulseVersion is the block version Oxen block headers gained a pulse header, and blocks the signatures of the
+// service node quorum that produced them.  Source: hf16_pulse in Oxen's cryptonote_config.h
+const OxenPulseVersion = 16
+
+// Loki and Oxen transaction versions and types.  Source: txversion and txtype in Oxen's cryptonote_basic.h
+const (
+	OxenTxVersionOutputUnlockTimes = 3 // Outputs each have an unlock time, and service node state changes are flagged
+	OxenTxVersionTypes             = 4 // The flag became a type after the extra
+
+	OxenTxTypeStandard    = 0
+	OxenTxTypeStateChange = 1
+)
+
+// Oxen's tx_extra field tags for service nodes and burns.  Registrations, state changes, key image unlocks and their
+// proofs and name system records have layouts that changed from one hard fork to the next and aren't parsed, like
+// any other unknown field they end the parsed fields and are kept in Extra.Unparsed.  Source: tx_extra.h in Oxen
+const (
+	ExtraTagOxenServiceNodeWinner      byte = 0x72
+	ExtraTagOxenServiceNodeContributor byte = 0x73
+	ExtraTagOxenServiceNodePubkey      byte = 0x74
+	ExtraTagOxenTxSecretKey            byte = 0x75
+	ExtraTagOxenBurn                   byte = 0x79
+)
+
+var (
+	OutputUnlockTimesMismatch = errors.New("transaction has a different number of output unlock times than outputs")
+	InvalidStateChangeFlag    = errors.New("transaction state change flag is neither 0 nor 1")
+)
+
+// OxenTransaction is a Loki or Oxen transaction, a Monero transaction whose prefix has service node fields from
+// OxenTxVersionOutputUnlockTimes.  Version 3 transactions can only be of OxenTxTypeStandard or OxenTxTypeStateChange.
+// Original: transaction_prefix in Oxen's cryptonote_basic.h
+type OxenTransaction struct {
+	Transaction
+	OutputUnlockTimes []uint64
+	Type              uint64
+}
+
+// SerializePrefix returns the transaction's prefix, the part its prefix hash is taken over.
+func (t OxenTransaction) SerializePrefix() []byte {
+	w := NewWriter()
+	t.writePrefix(w)
+	return w.Bytes()
+}
+
+func (t OxenTransaction) writePrefix(w *Writer) {
+	w.WriteVarint(t.Version)
+	if t.Version >= OxenTxVersionOutputUnlockTimes {
+		w.WriteVarint(uint64(len(t.OutputUnlockTimes)))
+		for _, u := range t.OutputUnlockTimes {
+			w.WriteVarint(u)
+		}
+		if t.Version == OxenTxVersionOutputUnlockTimes {
+			if t.Type == OxenTxTypeStateChange {
+				w.WriteTag(1)
+			} else {
+				w.WriteTag(0)
+			}
+		}
+	}
+	t.TransactionPrefix.writeBody(w)
+	if t.Version >= OxenTxVersionTypes {
+		w.WriteVarint(t.Type)
+	}
+}
+
+func (t OxenTransaction) Serialize() []byte {
+	w := NewWriter()
+	t.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (t OxenTransaction) WriteArchive(w *Writer) {
+	t.writePrefix(w)
+	if t.Version > 1 {
+		t.RctSignatures.WriteArchive(w)
+	}
+}
+
+// ReadArchive reads the transaction, see Transaction.ReadArchive.
+func (t *OxenTransaction) ReadArchive(r *Reader) error {
+	var err error
+	if t.Version, err = r.ReadVarint(); err != nil {
+		return err
+	}
+	t.OutputUnlockTimes, t.Type = nil, OxenTxTypeStandard
+	if t.Version >= OxenTxVersionOutputUnlockTimes {
+		count, err := r.ReadCount(1)
+		if err != nil {
+			return err
+		}
+		t.OutputUnlockTimes = make([]uint64, count)
+		for i := range t.OutputUnlockTimes {
+			if t.OutputUnlockTimes[i], err = r.ReadVarint(); err != nil {
+				return err
+			}
+		}
+		if t.Version == OxenTxVersionOutputUnlockTimes {
+			stateChange, err := r.ReadTag()
+			if err != nil {
+				return err
+			}
+			if stateChange > 1 {
+				return r.Fail(InvalidStateChangeFlag)
+			}
+			t.Type = uint64(stateChange)
+		}
+	}
+	if err = t.TransactionPrefix.readBody(r); err != nil {
+		return err
+	}
+	if t.Version >= OxenTxVersionOutputUnlockTimes && len(t.OutputUnlockTimes) != len(t.TransactionsOut) {
+		return r.Fail(OutputUnlockTimesMismatch)
+	}
+	if t.Version >= OxenTxVersionTypes {
+		if t.Type, err = r.ReadVarint(); err != nil {
+			return err
+		}
+	}
+	return t.readSignatures(r)
+}
+
+// OxenPulse is the pulse header of an Oxen block, zero for blocks mined the usual way.
+type OxenPulse struct {
+	RandomValue     [16]byte
+	Round           uint8
+	ValidatorBitset uint16
+}
+
+func (p OxenPulse) WriteArchive(w *Writer) {
+	w.WriteBlob(p.RandomValue[:])
+	w.WriteTag(p.Round)
+	w.WriteUint16(p.ValidatorBitset)
+}
+
+func (p *OxenPulse) ReadArchive(r *Reader) error {
+	if err := r.ReadPOD(p.RandomValue[:]); err != nil {
+		return err
+	}
+	var err error
+	if p.Round, err = r.ReadTag(); err != nil {
+		return err
+	}
+	p.ValidatorBitset, err = r.ReadUint16()
+	return err
+}
+
+// OxenQuorumSignature is a service node's signature of a pulse block.
+type OxenQuorumSignature struct {
+	VoterIndex uint16
+	Signature  [64]byte
+}
+
+// OxenBlock is a Loki or Oxen block.  From OxenPulseVersion the header ends with the pulse header, and the block with
+// the quorum's signatures.  Original: block_header and block in Oxen's cryptonote_basic.h
+type OxenBlock struct {
+	BlockHeader
+	Pulse      OxenPulse
+	MinerTxn   OxenTransaction
+	TxnHashes  [][32]byte
+	Signatures []OxenQuorumSignature
+}
+
+// SerializeHeader returns the block's header, the pulse header included.
+func (b OxenBlock) SerializeHeader() []byte {
+	w := NewWriter()
+	b.writeHeader(w)
+	return w.Bytes()
+}
+
+func (b OxenBlock) writeHeader(w *Writer) {
+	b.BlockHeader.WriteArchive(w)
+	if b.MajorVersion >= OxenPulseVersion {
+		b.Pulse.WriteArchive(w)
+	}
+}
+
+func (b OxenBlock) Serialize() []byte {
+	w := NewWriter()
+	b.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (b OxenBlock) WriteArchive(w *Writer) {
+	b.writeHeader(w)
+	b.MinerTxn.WriteArchive(w)
+	writeHashes(w, b.TxnHashes)
+	if b.MajorVersion >= OxenPulseVersion {
+		w.WriteVarint(uint64(len(b.Signatures)))
+		for _, s := range b.Signatures {
+			w.WriteUint16(s.VoterIndex)
+			w.WriteBlob(s.Signature[:])
+		}
+	}
+}
+
+// ReadArchive reads a block.  The miner transaction's extra refers back into the blob.
+func (b *OxenBlock) ReadArchive(r *Reader) error {
+	if err := b.BlockHeader.ReadArchive(r); err != nil {
+		return err
+	}
+	if b.MajorVersion >= OxenPulseVersion {
+		if err := b.Pulse.ReadArchive(r); err != nil {
+			return err
+		}
+	}
+	if err := b.MinerTxn.ReadArchive(r); err != nil {
+		return err
+	}
+	var err error
+	if b.TxnHashes, err = readHashes(r); err != nil {
+		return err
+	}
+	b.Signatures = nil
+	if b.MajorVersion < OxenPulseVersion {
+		return nil
+	}
+	count, err := r.ReadCount(2 + 64)
+	if err != nil {
+		return err
+	}
+	if count > 0 {
+		b.Signatures = make([]OxenQuorumSignature, count)
+	}
+	for i := range b.Signatures {
+		if b.Signatures[i].VoterIndex, err = r.ReadUint16(); err != nil {
+			return err
+		}
+		if err = r.ReadPOD(b.Signatures[i].Signature[:]); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ExtraOxenServiceNodeKey is a service node's public key, the node a miner transaction pays with
+// ExtraTagOxenServiceNodeWinner or the node a registration is for with ExtraTagOxenServiceNodePubkey.
+type ExtraOxenServiceNodeKey struct {
+	Tag       byte
+	PublicKey [32]byte
+	Used      bool
+}
+
+func (k ExtraOxenServiceNodeKey) WriteArchive(w *Writer) {
+	w.WriteTag(k.Tag)
+	w.WriteBlob(k.PublicKey[:])
+}
+
+func (k *ExtraOxenServiceNodeKey) ReadArchive(r *Reader) error {
+	tag, err := r.ReadTag()
+	if err != nil {
+		return err
+	}
+	if tag != ExtraTagOxenServiceNodeWinner && tag != ExtraTagOxenServiceNodePubkey {
+		return r.Fail(UnexpectedTag)
+	}
+	if err = r.ReadPOD(k.PublicKey[:]); err != nil {
+		return err
+	}
+	k.Tag, k.Used = tag, true
+	return nil
+}
+
+// ExtraOxenContributor is the address of a service node contributor, its public spend and view keys.
+type ExtraOxenContributor struct {
+	SpendPublicKey [32]byte
+	ViewPublicKey  [32]byte
+	Used           bool
+}
+
+func (c ExtraOxenContributor) WriteArchive(w *Writer) {
+	w.WriteTag(ExtraTagOxenServiceNodeContributor)
+	w.WriteBlob(c.SpendPublicKey[:])
+	w.WriteBlob(c.ViewPublicKey[:])
+}
+
+func (c *ExtraOxenContributor) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(ExtraTagOxenServiceNodeContributor); err != nil {
+		return err
+	}
+	if err := r.ReadPOD(c.SpendPublicKey[:]); err != nil {
+		return err
+	}
+	if err := r.ReadPOD(c.ViewPublicKey[:]); err != nil {
+		return err
+	}
+	c.Used = true
+	return nil
+}
+
+// ExtraOxenTxSecretKey is a transaction's secret key, published so that anyone can check a stake's outputs.
+type ExtraOxenTxSecretKey struct {
+	SecretKey [32]byte
+	Used      bool
+}
+
+func (k ExtraOxenTxSecretKey) WriteArchive(w *Writer) {
+	w.WriteTag(ExtraTagOxenTxSecretKey)
+	w.WriteBlob(k.SecretKey[:])
+}
+
+func (k *ExtraOxenTxSecretKey) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(ExtraTagOxenTxSecretKey); err != nil {
+		return err
+	}
+	if err := r.ReadPOD(k.SecretKey[:]); err != nil {
+		return err
+	}
+	k.Used = true
+	return nil
+}
+
+// ExtraOxenBurn is the part of a transaction's fee that's burnt rather than paid to the miner, a little endian POD.
+type ExtraOxenBurn struct {
+	Amount uint64
+	Used   bool
+}
+
+func (b ExtraOxenBurn) WriteArchive(w *Writer) {
+	var amount [8]byte
+	binary.LittleEndian.PutUint64(amount[:], b.Amount)
+	w.WriteTag(ExtraTagOxenBurn)
+	w.WriteBlob(amount[:])
+}
+
+func (b *ExtraOxenBurn) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(ExtraTagOxenBurn); err != nil {
+		return err
+	}
+	amount, err := r.ReadBlob(8)
+	if err != nil {
+		return err
+	}
+	*b = ExtraOxenBurn{Amount: binary.LittleEndian.Uint64(amount), Used: true}
+	return nil
+}
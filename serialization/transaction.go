@@ -1,5 +1,15 @@
 package serialization
 
+import (
+	"errors"
+)
+
+var (
+	UnsupportedInput       = errors.New("transaction input type is not supported")
+	UnsupportedOutput      = errors.New("transaction output type is not supported")
+	UnsupportedTransaction = errors.New("version 1 transactions with key inputs are not supported")
+)
+
 // Transaction Inputs
 type TransactionInGenesis struct {
 	Height uint64
@@ -7,9 +17,24 @@ type TransactionInGenesis struct {
 }
 
 func (tig TransactionInGenesis) Serialize() []byte {
-	var s []byte = []byte{0xff}
-	s = WriteUint(s, tig.Height)
-	return s
+	w := NewWriter()
+	tig.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (tig TransactionInGenesis) WriteArchive(w *Writer) {
+	w.WriteTag(0xff)
+	w.WriteVarint(tig.Height)
+}
+
+func (tig *TransactionInGenesis) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(0xff); err != nil {
+		return err
+	}
+	var err error
+	tig.Height, err = r.ReadVarint()
+	tig.Used = err == nil
+	return err
 }
 
 type TransactionInToScript struct {
@@ -34,6 +59,48 @@ type TransactionInToKey struct {
 	Used       bool
 }
 
+func (titk TransactionInToKey) Serialize() []byte {
+	w := NewWriter()
+	titk.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (titk TransactionInToKey) WriteArchive(w *Writer) {
+	w.WriteTag(0x02)
+	w.WriteVarint(titk.Amount)
+	w.WriteVarint(uint64(len(titk.KeyOffsets)))
+	for _, o := range titk.KeyOffsets {
+		w.WriteVarint(o)
+	}
+	w.WriteBlob(titk.KeyImage[:])
+}
+
+func (titk *TransactionInToKey) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(0x02); err != nil {
+		return err
+	}
+	var err error
+	if titk.Amount, err = r.ReadVarint(); err != nil {
+		return err
+	}
+	// Every offset is at least a byte, don't let a bad count allocate more than the blob could hold
+	count, err := r.ReadCount(1)
+	if err != nil {
+		return err
+	}
+	titk.KeyOffsets = make([]uint64, count)
+	for i := range titk.KeyOffsets {
+		if titk.KeyOffsets[i], err = r.ReadVarint(); err != nil {
+			return err
+		}
+	}
+	if err = r.ReadPOD(titk.KeyImage[:]); err != nil {
+		return err
+	}
+	titk.Used = true
+	return nil
+}
+
 type TransactionIn struct {
 	Genesis    TransactionInGenesis
 	Script     TransactionInToScript
@@ -42,11 +109,32 @@ type TransactionIn struct {
 }
 
 func (ti TransactionIn) Serialize() []byte {
-	var s []byte
+	w := NewWriter()
+	ti.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (ti TransactionIn) WriteArchive(w *Writer) {
 	if ti.Genesis.Used {
-		s = append(s, ti.Genesis.Serialize()...)
+		ti.Genesis.WriteArchive(w)
+	}
+	if ti.Key.Used {
+		ti.Key.WriteArchive(w)
+	}
+}
+
+func (ti *TransactionIn) ReadArchive(r *Reader) error {
+	tag, err := r.PeekTag()
+	if err != nil {
+		return err
+	}
+	switch tag {
+	case 0xff:
+		return ti.Genesis.ReadArchive(r)
+	case 0x02:
+		return ti.Key.ReadArchive(r)
 	}
-	return s
+	return r.Fail(UnsupportedInput)
 }
 
 // Transaction Outputs
@@ -67,9 +155,60 @@ type TransactionOutToKey struct {
 }
 
 func (totk TransactionOutToKey) Serialize() []byte {
-	var s []byte = []byte{0x02}
-	s = append(s, totk.PublicKey[:]...)
-	return s
+	w := NewWriter()
+	totk.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (totk TransactionOutToKey) WriteArchive(w *Writer) {
+	w.WriteTag(0x02)
+	w.WriteBlob(totk.PublicKey[:])
+}
+
+func (totk *TransactionOutToKey) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(0x02); err != nil {
+		return err
+	}
+	if err := r.ReadPOD(totk.PublicKey[:]); err != nil {
+		return err
+	}
+	totk.Used = true
+	return nil
+}
+
+// TransactionOutToTaggedKey is an output key with a one byte view tag, used from block version 15 so wallets can skip
+// most outputs without a full key derivation.
+type TransactionOutToTaggedKey struct {
+	PublicKey [32]byte
+	ViewTag   uint8
+	Used      bool
+}
+
+func (tottk TransactionOutToTaggedKey) Serialize() []byte {
+	w := NewWriter()
+	tottk.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (tottk TransactionOutToTaggedKey) WriteArchive(w *Writer) {
+	w.WriteTag(0x03)
+	w.WriteBlob(tottk.PublicKey[:])
+	w.WriteTag(tottk.ViewTag)
+}
+
+func (tottk *TransactionOutToTaggedKey) ReadArchive(r *Reader) error {
+	if err := r.ExpectTag(0x03); err != nil {
+		return err
+	}
+	if err := r.ReadPOD(tottk.PublicKey[:]); err != nil {
+		return err
+	}
+	var err error
+	if tottk.ViewTag, err = r.ReadTag(); err != nil {
+		return err
+	}
+	tottk.Used = true
+	return nil
 }
 
 type TransactionOut struct {
@@ -77,15 +216,42 @@ type TransactionOut struct {
 	Script     TransactionOutToScript
 	ScriptHash TransactionOutToScriptHash
 	Key        TransactionOutToKey
+	TaggedKey  TransactionOutToTaggedKey
 }
 
 func (to TransactionOut) Serialize() []byte {
-	var s []byte
-	s = WriteUint(s, to.Amount)
+	w := NewWriter()
+	to.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (to TransactionOut) WriteArchive(w *Writer) {
+	w.WriteVarint(to.Amount)
 	if to.Key.Used {
-		s = append(s, to.Key.Serialize()...)
+		to.Key.WriteArchive(w)
+	}
+	if to.TaggedKey.Used {
+		to.TaggedKey.WriteArchive(w)
+	}
+}
+
+func (to *TransactionOut) ReadArchive(r *Reader) error {
+	var err error
+	if to.Amount, err = r.ReadVarint(); err != nil {
+		return err
 	}
-	return s
+	// Outputs are to a key (0x02), or from version 15 to a key with a view tag (0x03)
+	tag, err := r.PeekTag()
+	if err != nil {
+		return err
+	}
+	switch tag {
+	case 0x02:
+		return to.Key.ReadArchive(r)
+	case 0x03:
+		return to.TaggedKey.ReadArchive(r)
+	}
+	return r.Fail(UnsupportedOutput)
 }
 
 type TransactionPrefix struct {
@@ -97,35 +263,223 @@ type TransactionPrefix struct {
 }
 
 func (tp TransactionPrefix) Serialize() []byte {
+	w := NewWriter()
+	tp.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (tp TransactionPrefix) WriteArchive(w *Writer) {
 	// varint - Version
 	// varint - unlocked_time
 	// Vector store of the vin
 	// Vector store of the vout
 	// Slap on that extra data.  Mmmmm.  Extra.  Data.  Nomnom.
-	var s []byte
-	s = WriteUint(s, tp.Version)
-	s = WriteUint(s, tp.UnlockTime)
-	s = WriteUint(s, uint64(len(tp.TransactionsIn)))
+	w.WriteVarint(tp.Version)
+	tp.writeBody(w)
+}
+
+// writeBody writes everything after the version, which forks such as Oxen add their own fields around.
+func (tp TransactionPrefix) writeBody(w *Writer) {
+	w.WriteVarint(tp.UnlockTime)
+	w.WriteVarint(uint64(len(tp.TransactionsIn)))
 	for _, e := range tp.TransactionsIn {
-		s = append(s, e.Serialize()...)
+		e.WriteArchive(w)
 	}
-	s = WriteUint(s, uint64(len(tp.TransactionsOut)))
+	w.WriteVarint(uint64(len(tp.TransactionsOut)))
 	for _, e := range tp.TransactionsOut {
-		s = append(s, e.Serialize()...)
+		e.WriteArchive(w)
+	}
+	w.WriteVector(tp.Extra)
+}
+
+// ReadArchive reads the prefix.  The extra refers back into the blob.
+func (tp *TransactionPrefix) ReadArchive(r *Reader) error {
+	var err error
+	if tp.Version, err = r.ReadVarint(); err != nil {
+		return err
+	}
+	return tp.readBody(r)
+}
+
+func (tp *TransactionPrefix) readBody(r *Reader) error {
+	var err error
+	if tp.UnlockTime, err = r.ReadVarint(); err != nil {
+		return err
+	}
+
+	// Inputs are at least a tag and a varint, outputs an amount, a tag and a key
+	count, err := r.ReadCount(2)
+	if err != nil {
+		return err
+	}
+	tp.TransactionsIn = make([]TransactionIn, count)
+	for i := range tp.TransactionsIn {
+		if err = tp.TransactionsIn[i].ReadArchive(r); err != nil {
+			return err
+		}
+	}
+	if count, err = r.ReadCount(34); err != nil {
+		return err
+	}
+	tp.TransactionsOut = make([]TransactionOut, count)
+	for i := range tp.TransactionsOut {
+		if err = tp.TransactionsOut[i].ReadArchive(r); err != nil {
+			return err
+		}
 	}
-	s = WriteUint(s, uint64(len(tp.Extra)))
-	s = append(s, tp.Extra...)
-	return s
+
+	tp.Extra, err = r.ReadVector()
+	return err
+}
+
+// RingCT signature types.  Source: rctTypes.h
+const (
+	RctTypeNull            = 0
+	RctTypeFull            = 1
+	RctTypeSimple          = 2
+	RctTypeBulletproof     = 3
+	RctTypeBulletproof2    = 4
+	RctTypeCLSAG           = 5
+	RctTypeBulletproofPlus = 6
+)
+
+// RctSignatures is a transaction's RingCT data.  Only the parts the library needs are broken out, the whole lot is
+// kept serialized in Raw.
+type RctSignatures struct {
+	Type   uint8
+	TxnFee uint64
+	// BulletproofRounds is the size of the L vector of each bulletproof, a proof with n rounds covers 2^(n-6) outputs
+	BulletproofRounds []int
+	Raw               []byte // Everything from the type byte to the end of the transaction, nil for RctTypeNull
+}
+
+func (rs RctSignatures) WriteArchive(w *Writer) {
+	if len(rs.Raw) > 0 {
+		w.WriteBlob(rs.Raw)
+	} else {
+		w.WriteTag(RctTypeNull)
+	}
+}
+
+// read reads the RingCT type and fee, and the sizes of any bulletproofs in the prunable data, for a transaction with
+// the given number of outputs.  Anything but a null signature is taken to run to the end of the blob.
+// Original: rctSigBase::serialize_rctsig_base and rctSigPrunable::serialize_rctsig_prunable in rctTypes.h
+func (rs *RctSignatures) read(r *Reader, outputs int) error {
+	start := r.Offset()
+	var err error
+	if rs.Type, err = r.ReadTag(); err != nil {
+		return err
+	}
+	if rs.Type == RctTypeNull {
+		return nil
+	}
+	if err = rs.readBody(r, outputs); err != nil {
+		return err
+	}
+	r.off = start
+	rs.Raw = r.ReadRest()
+	return nil
+}
+
+func (rs *RctSignatures) readBody(r *Reader, outputs int) error {
+	var err error
+	if rs.TxnFee, err = r.ReadVarint(); err != nil {
+		return err
+	}
+	// Before Bulletproof2 the masks and amounts took 32 bytes each, now it's an 8 byte amount
+	ecdhSize := uint64(64)
+	if rs.Type >= RctTypeBulletproof2 {
+		ecdhSize = 8
+	}
+	// ecdhInfo and outPk, then the prunable data
+	if _, err = r.ReadBlob((ecdhSize + 32) * uint64(outputs)); err != nil {
+		return err
+	}
+
+	if rs.Type < RctTypeBulletproof || rs.Type > RctTypeBulletproofPlus {
+		return nil
+	}
+	var proofs uint64
+	if rs.Type == RctTypeBulletproof {
+		var n uint32
+		n, err = r.ReadUint32()
+		proofs = uint64(n)
+	} else {
+		proofs, err = r.ReadVarint()
+	}
+	if err != nil {
+		return err
+	}
+	if proofs > uint64(outputs) {
+		return r.Fail(InvalidBlobLength)
+	}
+
+	// Bulletproofs start with A, S, T1, T2, taux and mu, and bulletproofs+ with A, A1, B, r1, s1 and d1.  Both then
+	// have their L and R vectors, and bulletproofs end with a, b and t.
+	tail := uint64(3 * 32)
+	if rs.Type == RctTypeBulletproofPlus {
+		tail = 0
+	}
+	for ; proofs > 0; proofs-- {
+		if _, err = r.ReadBlob(6 * 32); err != nil {
+			return err
+		}
+		var rounds [2]uint64 // L and R, always the same size
+		for i := range rounds {
+			if rounds[i], err = r.ReadCount(32); err != nil {
+				return err
+			}
+			if _, err = r.ReadBlob(rounds[i] * 32); err != nil {
+				return err
+			}
+		}
+		if _, err = r.ReadBlob(tail); err != nil {
+			return err
+		}
+		rs.BulletproofRounds = append(rs.BulletproofRounds, int(rounds[0]))
+	}
+	return nil
 }
 
 type Transaction struct {
 	TransactionPrefix
-	Signatures [][32]byte
+	Signatures    [][32]byte
+	RctSignatures RctSignatures
 }
 
 func (t Transaction) Serialize() []byte {
-	// Cheating, as our ringCT sing type is 0
-	var s []byte = t.TransactionPrefix.Serialize()
-	s = append(s, 0)
-	return s
+	w := NewWriter()
+	t.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (t Transaction) WriteArchive(w *Writer) {
+	// Miner transactions are left with an empty Raw, their RingCT type is 0 and that's all there is.  Version 1 miner
+	// transactions have no signatures and no RingCT type.
+	t.TransactionPrefix.WriteArchive(w)
+	if t.Version > 1 {
+		t.RctSignatures.WriteArchive(w)
+	}
+}
+
+// ReadArchive reads the transaction.  RingCT data is only read as far as the bulletproofs, the rest is kept as is in
+// RctSignatures.Raw, so that a transaction with RingCT signatures has to be the last thing in the blob.
+func (t *Transaction) ReadArchive(r *Reader) error {
+	if err := t.TransactionPrefix.ReadArchive(r); err != nil {
+		return err
+	}
+	return t.readSignatures(r)
+}
+
+// readSignatures reads what follows the prefix.
+func (t *Transaction) readSignatures(r *Reader) error {
+	if t.Version == 1 {
+		for _, ti := range t.TransactionsIn {
+			if !ti.Genesis.Used {
+				return r.Fail(UnsupportedTransaction)
+			}
+		}
+		return nil
+	}
+	return t.RctSignatures.read(r, len(t.TransactionsOut))
 }
@@ -6,24 +6,39 @@ import (
 )
 
 var (
-	errorBadBlob     error = errors.New("bad blob provided, unable to continue")
-	errorUintTooLong error = errors.New("bad blob provided, uint is unable to be decoded, unable to continue")
+	VarintTruncated    = errors.New("blob ended in the middle of a varint")
+	VarintOverflow     = errors.New("varint doesn't fit in 64 bits")
+	VarintNotCanonical = errors.New("varint isn't in its shortest encoding")
 )
 
+// ReadUint reads a varint off the front of b, returning it and the rest of b.  Like the daemon it only takes a number's
+// shortest encoding, so a blob can't be changed without changing what it decodes to.  Original: read_varint in
+// varint.h
 func ReadUint(b []byte) (uint64, []byte, error) {
-	val, byteCount := binary.Uvarint(b)
-	if byteCount == 0 {
-		return 0, b, errorBadBlob
+	var val uint64
+	for i, shift := 0, uint(0); ; i, shift = i+1, shift+7 {
+		if i == len(b) {
+			return 0, b, VarintTruncated
+		}
+		c := b[i]
+		// The tenth byte only has room for the top bit
+		if shift+7 >= 64 && c >= 1<<(64-shift) {
+			return 0, b, VarintOverflow
+		}
+		// A trailing zero byte adds nothing, the shorter encoding would have stopped before it
+		if c == 0 && shift != 0 {
+			return 0, b, VarintNotCanonical
+		}
+		val |= uint64(c&0x7f) << shift
+		if c&0x80 == 0 {
+			return val, b[i+1:], nil
+		}
 	}
-	if byteCount <= 0 {
-		return 0, b, errorUintTooLong
-	}
-	return val, b[byteCount:], nil
 }
 
+// WriteUint appends v to b as a varint.
 func WriteUint(b []byte, v uint64) []byte {
-	var tempBlob []byte = make([]byte, 32)
-	bytesWritten := binary.PutUvarint(tempBlob, v)
-	b = append(b, tempBlob[0:bytesWritten]...)
-	return b
+	var buf [binary.MaxVarintLen64]byte
+	n := binary.PutUvarint(buf[:], v)
+	return append(b, buf[:n]...)
 }
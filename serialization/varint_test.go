@@ -0,0 +1,34 @@
+package serialization
+
+import (
+	"math"
+	"testing"
+)
+
+func TestReadUint(t *testing.T) {
+	for _, v := range []uint64{0, 1, 0x7f, 0x80, 0x3fff, 0x4000, 1<<56 - 1, 1 << 63, math.MaxUint64} {
+		b := WriteUint(nil, v)
+		got, rest, err := ReadUint(append(b, 0xaa))
+		if err != nil || got != v || len(rest) != 1 || rest[0] != 0xaa {
+			t.Fatalf("Expected %d back from %x, got %d, %x, %v", v, b, got, rest, err)
+		}
+	}
+
+	for _, c := range []struct {
+		blob []byte
+		err  error
+	}{
+		{nil, VarintTruncated},
+		{[]byte{0x80}, VarintTruncated},
+		{[]byte{0xff, 0xff}, VarintTruncated},
+		{[]byte{0x80, 0x00}, VarintNotCanonical},
+		{[]byte{0x81, 0x80, 0x00}, VarintNotCanonical},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x02}, VarintOverflow},
+		{[]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x81, 0x01}, VarintOverflow},
+		{[]byte{0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x01}, VarintOverflow},
+	} {
+		if _, rest, err := ReadUint(c.blob); err != c.err || len(rest) != len(c.blob) {
+			t.Fatalf("Expected %v reading %x, got %v", c.err, c.blob, err)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package serialization
+
+// WowneroMinerSignatureVersion is the block version Wownero block headers gained the miner's signature and vote at.
+// Source: BLOCK_HEADER_MINER_SIG in Wownero's cryptonote_config.h
+const WowneroMinerSignatureVersion = 18
+
+// WowneroBlock is a Wownero block, a Monero block whose header from WowneroMinerSignatureVersion carries a signature by
+// the miner and a vote after the nonce.
+type WowneroBlock struct {
+	Block
+	Signature [64]byte
+	Vote      uint16
+}
+
+// SerializeHeader returns the block's header, signature and vote included.
+func (b WowneroBlock) SerializeHeader() []byte {
+	w := NewWriter()
+	b.writeHeader(w)
+	return w.Bytes()
+}
+
+func (b WowneroBlock) writeHeader(w *Writer) {
+	b.BlockHeader.WriteArchive(w)
+	if b.MajorVersion >= WowneroMinerSignatureVersion {
+		w.WriteBlob(b.Signature[:])
+		w.WriteUint16(b.Vote)
+	}
+}
+
+func (b WowneroBlock) Serialize() []byte {
+	w := NewWriter()
+	b.WriteArchive(w)
+	return w.Bytes()
+}
+
+func (b WowneroBlock) WriteArchive(w *Writer) {
+	b.writeHeader(w)
+	b.MinerTxn.WriteArchive(w)
+	writeHashes(w, b.TxnHashes)
+}
+
+func (b *WowneroBlock) ReadArchive(r *Reader) error {
+	if err := b.BlockHeader.ReadArchive(r); err != nil {
+		return err
+	}
+	var err error
+	if b.MajorVersion >= WowneroMinerSignatureVersion {
+		if err = r.ReadPOD(b.Signature[:]); err != nil {
+			return err
+		}
+		if b.Vote, err = r.ReadUint16(); err != nil {
+			return err
+		}
+	}
+	if err = b.MinerTxn.ReadArchive(r); err != nil {
+		return err
+	}
+	b.TxnHashes, err = readHashes(r)
+	return err
+}
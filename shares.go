@@ -0,0 +1,152 @@
+package monerocnutils
+
+import (
+	"errors"
+	"math/big"
+	"sync"
+)
+
+var (
+	ResultHashMismatch = errors.New("share's result hash doesn't match its proof of work")
+	LowDifficultyShare = errors.New("share doesn't meet the job's difficulty")
+	ShareQueueFull     = errors.New("share queue is full")
+)
+
+// ShareJob is a job handed out to a miner, what its shares are checked against.
+type ShareJob struct {
+	Template          *Template
+	ExtraNonce        []byte // Written into the template's reserved space for this job
+	Height            uint64
+	SeedHash          [32]byte
+	ShareDifficulty   uint64
+	NetworkDifficulty *big.Int // Shares meeting it are blocks, none are if nil
+}
+
+// Share is a miner's submission for a job.
+type Share struct {
+	ID     uint64 // The caller's, passed through to the result
+	Job    *ShareJob
+	Nonce  [NonceSize]byte
+	Result [32]byte // The hash the miner claims the nonce gives
+}
+
+// ShareStatus is the outcome of validating a share.
+type ShareStatus int
+
+const (
+	ShareInvalid ShareStatus = iota
+	ShareValid
+	ShareBlockFound
+)
+
+// ShareResult is the outcome of validating a share.
+type ShareResult struct {
+	Share  Share
+	Status ShareStatus
+	Hash   [32]byte
+	Block  []byte // The block blob to submit, for ShareBlockFound
+	Err    error  // Why the share is invalid
+}
+
+// ShareValidator checks shares on a fixed number of goroutines, each with its own Hasher so that RandomX VMs are
+// reused from one share to the next.  Shares are queued up to the queue size, after which Submit blocks and TrySubmit
+// fails, and results come out of Results in no particular order.  Results has to be drained or the workers stall.
+type ShareValidator struct {
+	shares    chan Share
+	results   chan ShareResult
+	wg        sync.WaitGroup
+	closeOnce sync.Once
+}
+
+// NewShareValidator starts workers goroutines validating shares, each with a Hasher from newHasher.
+func NewShareValidator(workers, queueSize int, newHasher func() Hasher) *ShareValidator {
+	if workers < 1 {
+		workers = 1
+	}
+	v := &ShareValidator{
+		shares:  make(chan Share, queueSize),
+		results: make(chan ShareResult, queueSize),
+	}
+	v.wg.Add(workers)
+	for i := 0; i < workers; i++ {
+		go v.work(newHasher())
+	}
+	go func() {
+		v.wg.Wait()
+		close(v.results)
+	}()
+	return v
+}
+
+// Submit queues s, waiting for room in the queue.  It mustn't be called after Close.
+func (v *ShareValidator) Submit(s Share) {
+	v.shares <- s
+}
+
+// TrySubmit queues s, or returns ShareQueueFull rather than wait for room.  It mustn't be called after Close.
+func (v *ShareValidator) TrySubmit(s Share) error {
+	select {
+	case v.shares <- s:
+		return nil
+	default:
+		return ShareQueueFull
+	}
+}
+
+// Results returns the channel results are reported on, which is closed once the validator is closed and every queued
+// share has been checked.
+func (v *ShareValidator) Results() <-chan ShareResult {
+	return v.results
+}
+
+// Close stops taking shares.  The shares already queued are still checked.
+func (v *ShareValidator) Close() {
+	v.closeOnce.Do(func() {
+		close(v.shares)
+	})
+}
+
+func (v *ShareValidator) work(h Hasher) {
+	defer v.wg.Done()
+	for s := range v.shares {
+		v.results <- ValidateShare(h, s)
+	}
+}
+
+// ValidateShare rebuilds the hashing blob of s, hashes it with h and checks it against the job's difficulties.
+func ValidateShare(h Hasher, s Share) ShareResult {
+	r := ShareResult{Share: s}
+	job := s.Job
+	var hashingBlob []byte
+	if hashingBlob, r.Err = job.Template.HashingBlob(job.ExtraNonce); r.Err != nil {
+		return r
+	}
+	if r.Err = SetBlobNonce(hashingBlob, s.Nonce); r.Err != nil {
+		return r
+	}
+	if r.Hash, r.Err = h.Hash(hashingBlob, job.Height, job.SeedHash); r.Err != nil {
+		return r
+	}
+
+	if r.Hash != s.Result {
+		r.Err = ResultHashMismatch
+		return r
+	}
+	if !CheckHash(r.Hash, job.ShareDifficulty) {
+		r.Err = LowDifficultyShare
+		return r
+	}
+	r.Status = ShareValid
+
+	if job.NetworkDifficulty != nil && job.NetworkDifficulty.Sign() > 0 && CheckHash128(r.Hash, job.NetworkDifficulty) {
+		if r.Block, r.Err = job.Template.Blob(job.ExtraNonce); r.Err == nil {
+			r.Err = SetBlobNonce(r.Block, s.Nonce)
+		}
+		if r.Err != nil {
+			r.Status, r.Block = ShareInvalid, nil
+			return r
+		}
+		r.Status = ShareBlockFound
+	}
+	return r
+}
@@ -0,0 +1,137 @@
+package monerocnutils
+
+import (
+	"encoding/hex"
+	"github.com/snipa22/monerocnutils/crypto"
+	"math"
+	"math/big"
+	"sync/atomic"
+	"testing"
+)
+
+func testShareJob(t *testing.T) *ShareJob {
+	blob, _ := hex.DecodeString(minerTXBlockTemplate2)
+	b, _ := ParseBlock(blob)
+	offset, _, _ := extraNonceOffset(b)
+	tmpl, err := NewTemplate(blob, offset)
+	if err != nil {
+		t.Fatal("Error parsing the template,", err)
+	}
+	return &ShareJob{Template: tmpl, ExtraNonce: PoolNonce{Counter: 7}.Bytes(), ShareDifficulty: 1}
+}
+
+// testShare returns a share for job with a correct result hash.
+func testShare(job *ShareJob, nonce uint32) Share {
+	s := Share{ID: uint64(nonce), Job: job, Nonce: [NonceSize]byte{byte(nonce), byte(nonce >> 8)}}
+	hashingBlob, _ := job.Template.HashingBlob(job.ExtraNonce)
+	_ = SetBlobNonce(hashingBlob, s.Nonce)
+	s.Result = crypto.KeccakOneShot(hashingBlob)
+	return s
+}
+
+func TestValidateShare(t *testing.T) {
+	job := testShareJob(t)
+	s := testShare(job, 1)
+	if r := ValidateShare(&fakeHasher{}, s); r.Status != ShareValid || r.Err != nil || r.Hash != s.Result {
+		t.Fatalf("Expected a valid share, got %v, %v", r.Status, r.Err)
+	}
+
+	bad := s
+	bad.Result[0] ^= 1
+	if r := ValidateShare(&fakeHasher{}, bad); r.Status != ShareInvalid || r.Err != ResultHashMismatch {
+		t.Fatalf("Expected ResultHashMismatch, got %v", r.Err)
+	}
+
+	hard := *job
+	hard.ShareDifficulty = math.MaxUint64
+	s = testShare(&hard, 1)
+	if r := ValidateShare(&fakeHasher{}, s); r.Status != ShareInvalid || r.Err != LowDifficultyShare {
+		t.Fatalf("Expected LowDifficultyShare, got %v", r.Err)
+	}
+
+	// Network difficulties have been wider than 64 bits since block version 11, a hash with its top word set meets none
+	found := *job
+	found.NetworkDifficulty = new(big.Int).Lsh(big.NewInt(1), 64)
+	s = testShare(&found, 1)
+	if r := ValidateShare(&fakeHasher{}, s); r.Status != ShareValid || r.Err != nil || r.Block != nil {
+		t.Fatalf("Expected a share short of a 2^64 network difficulty, got %v, %v", r.Status, r.Err)
+	}
+
+	found.NetworkDifficulty = big.NewInt(1)
+	r := ValidateShare(&fakeHasher{}, s)
+	if r.Status != ShareBlockFound || r.Err != nil {
+		t.Fatalf("Expected a block, got %v, %v", r.Status, r.Err)
+	}
+	b, err := ParseBlock(r.Block)
+	if err != nil {
+		t.Fatal("Error parsing the found block,", err)
+	}
+	hashingBlob, _ := GetBlockHashingBlob(b)
+	if crypto.KeccakOneShot(hashingBlob) != r.Hash {
+		t.Fatal("Found block doesn't hash to the share's hash")
+	}
+}
+
+func TestShareValidator(t *testing.T) {
+	job := testShareJob(t)
+	var hashers int32
+	v := NewShareValidator(4, 8, func() Hasher {
+		atomic.AddInt32(&hashers, 1)
+		return &fakeHasher{}
+	})
+
+	const shares = 100
+	go func() {
+		for i := 0; i < shares; i++ {
+			s := testShare(job, uint32(i))
+			if i%2 == 1 {
+				s.Result[31] ^= 1
+			}
+			v.Submit(s)
+		}
+		v.Close()
+	}()
+
+	seen := make(map[uint64]bool)
+	for r := range v.Results() {
+		seen[r.Share.ID] = true
+		if valid := r.Share.ID%2 == 0; valid != (r.Status == ShareValid) {
+			t.Fatalf("Share %d came back %v, %v", r.Share.ID, r.Status, r.Err)
+		}
+	}
+	if len(seen) != shares {
+		t.Fatalf("Expected %d results, got %d", shares, len(seen))
+	}
+	if hashers != 4 {
+		t.Fatalf("Expected a hasher per worker, got %d", hashers)
+	}
+}
+
+func TestShareValidatorBackPressure(t *testing.T) {
+	job := testShareJob(t)
+	wait := make(chan struct{})
+	v := NewShareValidator(1, 2, func() Hasher { return &fakeHasher{wait: wait} })
+
+	// One share held by the worker and two queued, at most, before the queue is full
+	var queued int
+	for i := 0; i < 4; i++ {
+		if err := v.TrySubmit(testShare(job, uint32(i))); err == nil {
+			queued++
+		} else if err != ShareQueueFull {
+			t.Fatal("Error queueing a share,", err)
+		}
+	}
+	if queued == 4 {
+		t.Fatal("Expected the queue to fill up")
+	}
+
+	close(wait)
+	v.Close()
+	var results int
+	for range v.Results() {
+		results++
+	}
+	if results != queued {
+		t.Fatalf("Expected %d results, got %d", queued, results)
+	}
+}
@@ -0,0 +1,199 @@
+package monerocnutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"github.com/snipa22/monerocnutils/crypto"
+	"github.com/snipa22/monerocnutils/serialization"
+)
+
+// PoolNonceSize is the number of reserved bytes a PoolNonce needs, get_block_template should be called with a
+// reserve_size of at least this much.
+const PoolNonceSize = 16
+
+var (
+	InvalidReservedOffset = errors.New("reserved offset does not point at the miner transaction's extra nonce")
+	ReservedSizeExceeded  = errors.New("extra nonce does not fit in the reserved space")
+)
+
+// PoolNonce is the per job data a pool writes into the reserved space of a block template, so that every job it hands
+// out hashes differently.
+type PoolNonce struct {
+	PoolID   uint32
+	WorkerID uint32
+	Counter  uint64
+}
+
+// Bytes returns the little endian encoding of the nonce, PoolNonceSize bytes long.
+func (pn PoolNonce) Bytes() []byte {
+	b := make([]byte, PoolNonceSize)
+	binary.LittleEndian.PutUint32(b[0:4], pn.PoolID)
+	binary.LittleEndian.PutUint32(b[4:8], pn.WorkerID)
+	binary.LittleEndian.PutUint64(b[8:16], pn.Counter)
+	return b
+}
+
+// ReservedSpace returns the size of the reserved space at reservedOffset in a block template blob, checking that the
+// offset really does point at the start of the miner transaction's extra nonce.
+func ReservedSpace(blob []byte, reservedOffset int) (int, error) {
+	b, err := ParseBlock(blob)
+	if err != nil {
+		return 0, err
+	}
+	return reservedSpace(b, reservedOffset)
+}
+
+func reservedSpace(b serialization.Block, reservedOffset int) (int, error) {
+	offset, size, ok := extraNonceOffset(b)
+	if !ok || offset != reservedOffset {
+		return 0, InvalidReservedOffset
+	}
+	return size, nil
+}
+
+// extraNonceOffset returns the offset in the block blob and size of the miner transaction's extra nonce.
+func extraNonceOffset(b serialization.Block) (int, int, bool) {
+	nonce, nonceOffset, ok := b.MinerTxn.ExtraFields().Nonce()
+	if !ok {
+		return 0, 0, false
+	}
+
+	// The extra is the last thing in the miner transaction's prefix, which directly follows the header
+	extraOffset := len(b.BlockHeader.Serialize()) + len(b.MinerTxn.TransactionPrefix.Serialize()) - len(b.MinerTxn.Extra)
+	return extraOffset + nonceOffset, len(nonce.Nonce), true
+}
+
+// WriteReservedNonce writes nonce into the reserved space of a block template blob, as given by the reserved_offset
+// returned alongside it by get_block_template.  The template is left untouched, the updated template blob and its
+// hashing blob are returned.
+func WriteReservedNonce(blob []byte, reservedOffset int, nonce []byte) ([]byte, []byte, error) {
+	reserved, err := ReservedSpace(blob, reservedOffset)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(nonce) > reserved {
+		return nil, nil, ReservedSizeExceeded
+	}
+
+	newBlob := make([]byte, len(blob))
+	copy(newBlob, blob)
+	copy(newBlob[reservedOffset:], nonce)
+
+	// Re-parse the result so a template that doesn't serialize back to itself can't slip through
+	b, err := ParseBlock(newBlob)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !bytes.Equal(b.Serialize(), newBlob) {
+		return nil, nil, InvalidReservedOffset
+	}
+	hashingBlob, err := GetBlockHashingBlob(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return newBlob, hashingBlob, nil
+}
+
+// Template is a block template parsed once, so that pools can hand out jobs with different extra nonces without
+// reparsing it.  Only the miner transaction's hash changes with the nonce, so each hashing blob costs hashing the
+// miner transaction and then one hash per level of the merkle tree along the miner transaction's branch.  A Template
+// isn't changed once built and is safe for concurrent use.
+type Template struct {
+	blob           []byte
+	reservedOffset int
+	reservedSize   int
+	headerSize     int // The miner transaction starts here
+	prefixEnd      int // And its prefix ends here
+	minerTxnEnd    int
+	version        uint64 // The miner transaction's
+	baseHashes     [64]byte
+	txCount        []byte // The varint transaction count, miner transaction included
+	tree           crypto.TreeHasher
+}
+
+// NewTemplate parses a block template blob and the reserved_offset get_block_template returned alongside it.
+func NewTemplate(blob []byte, reservedOffset int) (*Template, error) {
+	b, err := ParseBlock(blob)
+	if err != nil {
+		return nil, err
+	}
+	size, err := reservedSpace(b, reservedOffset)
+	if err != nil {
+		return nil, err
+	}
+	// The offsets are worked out from the parsed block, so it has to serialize back to the very same blob
+	if !bytes.Equal(b.Serialize(), blob) {
+		return nil, InvalidReservedOffset
+	}
+
+	t := &Template{
+		blob:           append([]byte(nil), blob...),
+		reservedOffset: reservedOffset,
+		reservedSize:   size,
+		headerSize:     len(b.BlockHeader.Serialize()),
+		version:        b.MinerTxn.Version,
+		txCount:        serialization.WriteUint(nil, uint64(len(b.TxnHashes)+1)),
+	}
+	t.prefixEnd = t.headerSize + len(b.MinerTxn.TransactionPrefix.Serialize())
+	t.minerTxnEnd = t.headerSize + len(b.MinerTxn.Serialize())
+	// As getTransactionHash, the RingCT base hash of the miner transaction's null signatures and a zero prunable hash
+	baseHash := crypto.KeccakOneShot([]byte{0})
+	copy(t.baseHashes[:], baseHash[:])
+
+	t.tree.Hash(append([][32]byte{getTransactionHash(b.MinerTxn)}, b.TxnHashes...))
+	return t, nil
+}
+
+// ReservedSize returns the size of the template's reserved space, the most nonce bytes it takes.
+func (t *Template) ReservedSize() int {
+	return t.reservedSize
+}
+
+// Blob returns the template blob with nonce written into its reserved space, the block to submit once mined.
+func (t *Template) Blob(nonce []byte) ([]byte, error) {
+	if len(nonce) > t.reservedSize {
+		return nil, ReservedSizeExceeded
+	}
+	blob := append([]byte(nil), t.blob...)
+	copy(blob[t.reservedOffset:], nonce)
+	return blob, nil
+}
+
+// HashingBlob returns the hashing blob for the template with nonce written into its reserved space, as
+// WriteReservedNonce would.
+func (t *Template) HashingBlob(nonce []byte) ([]byte, error) {
+	if len(nonce) > t.reservedSize {
+		return nil, ReservedSizeExceeded
+	}
+	root := t.tree.UpdateFirst(t.minerTxnHash(nonce))
+
+	hashingBlob := make([]byte, 0, t.headerSize+len(root)+len(t.txCount))
+	hashingBlob = append(hashingBlob, t.blob[:t.headerSize]...)
+	hashingBlob = append(hashingBlob, root[:]...)
+	return append(hashingBlob, t.txCount...), nil
+}
+
+// minerTxnHash hashes the miner transaction with nonce in the reserved space, writing the blob around the nonce
+// straight into the hash rather than copying it.
+func (t *Template) minerTxnHash(nonce []byte) [32]byte {
+	end := t.prefixEnd
+	if t.version == 1 {
+		end = t.minerTxnEnd
+	}
+	h := crypto.NewHash()
+	h.Write(t.blob[t.headerSize:t.reservedOffset])
+	h.Write(nonce)
+	h.Write(t.blob[t.reservedOffset+len(nonce) : end])
+	var hash [32]byte
+	copy(hash[:], h.Sum(nil))
+	if t.version == 1 {
+		return hash
+	}
+
+	h = crypto.NewHash()
+	h.Write(hash[:])
+	h.Write(t.baseHashes[:])
+	copy(hash[:], h.Sum(nil))
+	return hash
+}
@@ -0,0 +1,113 @@
+package monerocnutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"fmt"
+	"testing"
+)
+
+func TestWriteReservedNonce(t *testing.T) {
+	blob, _ := hex.DecodeString(onlyMinerBlockTemplate)
+
+	reserved, err := ReservedSpace(blob, 128)
+	if err != nil {
+		t.Fatal("Error finding the reserved space,", err)
+	}
+	if reserved != 17 {
+		t.Fatalf("Expected 17 reserved bytes, got %d", reserved)
+	}
+
+	// Writing zeroes changes nothing, so we should get the daemon's own hashing blob back
+	newBlob, hashingBlob, err := WriteReservedNonce(blob, 128, make([]byte, reserved))
+	if err != nil {
+		t.Fatal("Error writing an empty nonce,", err)
+	}
+	if !bytes.Equal(newBlob, blob) || fmt.Sprintf("%x", hashingBlob) != onlyMinerBlockTemplateHashingBlob {
+		t.Fatal("Writing an empty nonce changed the template")
+	}
+
+	nonce := PoolNonce{PoolID: 1, WorkerID: 2, Counter: 3}
+	newBlob, hashingBlob, err = WriteReservedNonce(blob, 128, nonce.Bytes())
+	if err != nil {
+		t.Fatal("Error writing a pool nonce,", err)
+	}
+	if !bytes.Equal(newBlob[128:128+PoolNonceSize], nonce.Bytes()) {
+		t.Fatal("Pool nonce wasn't written at the reserved offset")
+	}
+	if bytes.Equal(newBlob, blob) || !bytes.Equal(blob[128:128+PoolNonceSize], make([]byte, PoolNonceSize)) {
+		t.Fatal("Writing a pool nonce didn't leave the original template alone")
+	}
+	b, _ := ParseBlock(newBlob)
+	expected, _ := GetBlockHashingBlob(b)
+	if !bytes.Equal(hashingBlob, expected) {
+		t.Fatal("Hashing blob doesn't match the updated template")
+	}
+}
+
+func TestWriteReservedNonceErrors(t *testing.T) {
+	blob, _ := hex.DecodeString(onlyMinerBlockTemplate)
+	if _, _, err := WriteReservedNonce(blob, 128, make([]byte, 18)); err != ReservedSizeExceeded {
+		t.Fatalf("Expected ReservedSizeExceeded, got %v", err)
+	}
+	if _, _, err := WriteReservedNonce(blob, 127, make([]byte, 4)); err != InvalidReservedOffset {
+		t.Fatalf("Expected InvalidReservedOffset, got %v", err)
+	}
+	if _, _, err := WriteReservedNonce(blob[:100], 128, make([]byte, 4)); err != InvalidBlobLength {
+		t.Fatalf("Expected InvalidBlobLength, got %v", err)
+	}
+}
+
+func TestTemplate(t *testing.T) {
+	templates := map[string]string{
+		onlyMinerBlockTemplate: onlyMinerBlockTemplateHashingBlob,
+		minerTXBlockTemplate2:  minerTXBlockTemplate2HashingBlob,
+	}
+	for templateHex, hashingHex := range templates {
+		blob, _ := hex.DecodeString(templateHex)
+		b, _ := ParseBlock(blob)
+		offset, _, _ := extraNonceOffset(b)
+
+		tmpl, err := NewTemplate(blob, offset)
+		if err != nil {
+			t.Fatal("Error parsing the template,", err)
+		}
+		hashingBlob, err := tmpl.HashingBlob(nil)
+		if err != nil {
+			t.Fatal("Error building a hashing blob,", err)
+		}
+		if fmt.Sprintf("%x", hashingBlob) != hashingHex {
+			t.Fatal("Hashing blob doesn't match the daemon's")
+		}
+
+		for i := uint64(0); i < 3; i++ {
+			nonce := PoolNonce{PoolID: 1, WorkerID: 2, Counter: i}.Bytes()
+			expectedBlob, expectedHashingBlob, _ := WriteReservedNonce(blob, offset, nonce)
+			newBlob, _ := tmpl.Blob(nonce)
+			hashingBlob, _ = tmpl.HashingBlob(nonce)
+			if !bytes.Equal(newBlob, expectedBlob) || !bytes.Equal(hashingBlob, expectedHashingBlob) {
+				t.Fatalf("Template doesn't match WriteReservedNonce for counter %d", i)
+			}
+		}
+
+		if _, err = tmpl.HashingBlob(make([]byte, tmpl.ReservedSize()+1)); err != ReservedSizeExceeded {
+			t.Fatalf("Expected ReservedSizeExceeded, got %v", err)
+		}
+		if _, err = NewTemplate(blob, offset-1); err != InvalidReservedOffset {
+			t.Fatalf("Expected InvalidReservedOffset, got %v", err)
+		}
+	}
+}
+
+func BenchmarkTemplateHashingBlob(b *testing.B) {
+	blob, _ := hex.DecodeString(minerTXBlockTemplate2)
+	block, _ := ParseBlock(blob)
+	offset, _, _ := extraNonceOffset(block)
+	tmpl, _ := NewTemplate(blob, offset)
+	b.ReportAllocs()
+	for i := 0; i < b.N; i++ {
+		if _, err := tmpl.HashingBlob(PoolNonce{Counter: uint64(i)}.Bytes()); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
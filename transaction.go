@@ -5,6 +5,12 @@ import (
 	"github.com/snipa22/monerocnutils/serialization"
 )
 
+var (
+	UnsupportedInput       = serialization.UnsupportedInput
+	UnsupportedOutput      = serialization.UnsupportedOutput
+	UnsupportedTransaction = serialization.UnsupportedTransaction
+)
+
 func getTransactionPrefixHash(t serialization.Transaction) [32]byte {
 	// Given a Transaction t, extract the TransactionPrefix TP and serialize it.
 	// Given the resulting serialized data, cn_fast_hash (keccak-256) it.
@@ -14,12 +20,29 @@ func getTransactionPrefixHash(t serialization.Transaction) [32]byte {
 
 func getTransactionHash(t serialization.Transaction) [32]byte {
 	// Original source: cryptonote_format_utils.cpp:617-ish
+	// Version 1 transactions are hashed whole, there's no RingCT base or prunable data to mix in.
+	if t.Version == 1 {
+		return crypto.KeccakOneShot(t.Serialize())
+	}
+	return getRctTransactionHash(getTransactionPrefixHash(t))
+}
+
+// getOxenTransactionHash is getTransactionHash for Loki and Oxen transactions, whose prefixes have extra fields.
+func getOxenTransactionHash(t serialization.OxenTransaction) [32]byte {
+	if t.Version == 1 {
+		return crypto.KeccakOneShot(t.Serialize())
+	}
+	return getRctTransactionHash(crypto.KeccakOneShot(t.SerializePrefix()))
+}
+
+// getRctTransactionHash returns the hash of a miner transaction from version 2 given its prefix hash.
+func getRctTransactionHash(prefixHash [32]byte) [32]byte {
 	// With hashes be three, may thee get the result thoust desire.
 	var hs [3][32]byte
 
 	// Thou must take tine prefix, and hash it!
 	// Original : get_transaction_prefix_hash(t (Transaction), hashes[0] (crypto::hash))
-	hs[0] = getTransactionPrefixHash(t)
+	hs[0] = prefixHash
 
 	// Base RingCT Transaction Hash Data - byte 0 for the main txn, due to RingCTType being null (0x0)
 	// So we're gonna short-cut this...
@@ -37,3 +60,12 @@ func getTransactionHash(t serialization.Transaction) [32]byte {
 	var h [32]byte = crypto.KeccakOneShot(ah)
 	return h
 }
+
+// ParseTransaction parses a transaction blob, such as one from the daemon's transaction pool.  RingCT data is only read
+// as far as the bulletproofs, the rest is kept as is in RctSignatures.Raw, so the transaction serializes back to blob.
+// The returned transaction refers back into blob.
+func ParseTransaction(blob []byte) (serialization.Transaction, error) {
+	var t serialization.Transaction
+	err := serialization.Unmarshal(blob, &t)
+	return t, archiveError(err)
+}
@@ -0,0 +1,57 @@
+package monerocnutils
+
+import "github.com/snipa22/monerocnutils/serialization"
+
+// TransactionWeight returns the weight of t, which is its size plus, for bulletproofs covering more than two
+// outputs, a clawback of most of the space aggregating the proofs saved.  Without it, many output transactions would
+// be cheaper than their verification cost.  Original: get_transaction_weight in cryptonote_format_utils.cpp
+func TransactionWeight(t serialization.Transaction) uint64 {
+	blobSize := uint64(len(t.Serialize()))
+	if t.Version < 2 {
+		return blobSize
+	}
+	rctType := t.RctSignatures.Type
+	if rctType < serialization.RctTypeBulletproof || rctType > serialization.RctTypeBulletproofPlus {
+		return blobSize
+	}
+
+	var paddedOutputs uint64
+	for _, rounds := range t.RctSignatures.BulletproofRounds {
+		if rounds >= 6 {
+			paddedOutputs += 1 << uint(rounds-6)
+		}
+	}
+	return blobSize + bulletproofClawback(paddedOutputs, rctType == serialization.RctTypeBulletproofPlus)
+}
+
+// bulletproofClawback is four fifths of the difference between proving paddedOutputs outputs with separate two output
+// proofs and the single aggregated proof.  Original: get_transaction_weight_clawback in cryptonote_format_utils.cpp
+func bulletproofClawback(paddedOutputs uint64, plus bool) uint64 {
+	if paddedOutputs <= 2 {
+		return 0
+	}
+	fixed := uint64(9) // Scalars and points outside of L and R
+	if plus {
+		fixed = 6
+	}
+	// A two output proof has 7 rounds, normalised to one output
+	base := 32 * (fixed + 7*2) / 2
+
+	rounds := uint64(0)
+	for uint64(1)<<rounds < paddedOutputs {
+		rounds++
+	}
+	rounds += 6
+	size := 32 * (fixed + 2*rounds)
+	return (base*paddedOutputs - size) * 4 / 5
+}
+
+// BlockWeight returns the weight of a block with the miner transaction minerTxn and the transactions txs, the sum of
+// their weights.  This is the weight BlockReward takes.
+func BlockWeight(minerTxn serialization.Transaction, txs []serialization.Transaction) uint64 {
+	weight := TransactionWeight(minerTxn)
+	for _, t := range txs {
+		weight += TransactionWeight(t)
+	}
+	return weight
+}
@@ -0,0 +1,122 @@
+package monerocnutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/snipa22/monerocnutils/serialization"
+	"testing"
+)
+
+// Mainnet transaction 793da061 from get_transaction_pool, two outputs with BP+ and CLSAG signatures, which the daemon
+// reported a weight of 1529 for
+const tx793da061 = "020001020010f0c2ca03c5be0af1cb4080d3058db20bdba801d38507f86adc32df2aac04d10aa603f703d00128fc5655d843ed8b30a3563bbff1d02b606b089b1725c717823b0898c52f0478730200030993e6ca2d66871e4869adb2c3a524ad7205fcd3e0b3339daafaea76fc5518ee1b000384f3dd9b4e7df18c5662606a4f6a11ceede3f0cefb41a8586e691baf2930a6fcff2c01b984318d464e56b443af22d5f880470606435172a3bad71966e2a4bae5d18a8002090190d13c4c7d9222d206b0b8ea288b2fe303da838a84779fe795bc0ba77509cd23fa0e8ec03a348ed6e80386c93c276ef69f1c223f811ffc6ce1e88c030a28ceaa373700ce1aeb4167861ec41494edf53f3d7b7568fa7ac05db0aaf324da012644a5380b8de1652a3d47654ecee118eca9506655e77fb0e339aef31da452dd360227a720fca490111110bb23126a49cf783cb67ab8cd91de4891db2e7898ab6923bc04f5917dbe17dd5e6ef9d248cd7bb01afb4675eef4bc8fb7707c7a470ae1bd93860a4ad45f2d1ca2bddefa4f1598cf20be56051cae5b61c3f379f6160e1298b6aeaa25fdfb8631a32dd9bf8efeb66387304516e8bd00599caaa8a77104600b39b3e3f9390e7f6cb61062021d2e8d7f6a6fbb7b04318f35077a3243390f07b8bdee2c2c2997f46c9dd024f5bad1004a52cc8cbcb051fcc63de46476962fd79bce03d001b6ed12d6417ae5871e2a05574316ac53050712cf4129c5b00534798facd82baf29aaa8a96dd3e04cf6c742544b3aa6b37bce394c416869be0bb145f64be9871eda186cdce9c8fefec3cda6a70574492c42ff4c998e82f494192f02f98a7ecc762c59608409508924bed2665b53c20b93fb3338c2edad582ca19ef77cc02f17f547386b014b1ad6a79df59130f71c05cf7f50abd447c01249afdd7ffafdf6f43138b4905838243884fe16216df87300e1bf5e20e78ecea69bc53e1a07c2da698b34dce738ec74a2cba0b130378d1cf15a3697566a59bbcea9a082cd16e72907754e50b6b3daa866f459634f8e53ba531953c227309cf8f7a7fbfaac2daa5a4811b347f89eb981f331b752313aa8dc7aad366a40bc3e2ef68c51733e0e228769927c8d8eaccd0640a02916604234e7a1b1cc7f7e8311815452668becfc3d76332ea1de6ee160660fc310148d49135b718e611d1ade4146dd813253928721c48f76ca5d59d19b257afdd8c2d5abfe1c905ec00c34d150b90a52683c58d33506f70f64346d5ca69a26007689eb79755e9953f21bce011087d065ca137e4bdfae579e248336f3d39f4a880823b68e571ca8c3adbbd91fb90be2f5c7832007b39e788f94f3ccd48dc6b09d87d3b3d71c0a6df53658969b5a18d7864be6a00ab356d93b50cd3aae005c891cb72047726b7a40228bd1ac547f08b0ba2b5b630a693582bb3a5e39ebe2a66b44d5fe856875efffec516e2ca5229fb9689a92c1087cfabb788fc5925f23a45b675e28ff696009d928d25e3edce01703135ffc6404159297800e32b019ee70b15e73d4d91d4c439ad13bde42eee8f59120aedf0607b95ba55a6497a52e476718d0f4c8353190418fe6b2f4cc7050ced06451fb6d049e92a46ad7d55fe6aaf07faa17d791d7ee8ca2ac49e98417392575857bcdc206c72d57a1933434c5cd8b5fa167cb7d8b512347956fd6bc60caeb269f30beb60e5991d37f9543d81b0cd4a04087b8fbc19eb98102d3b460608da705354ac28a0a923382f6792d746b9c7bc5f7f00b01bebcf3a173c78c268872feb49422d8840e541f7c83b4da45bf3289eb36772444e08e703347313ab0500614c8b571b35d07279006100ed62a32e592071e8e749895090e27c347f2567bfbace5a7823100007b29c0c7d11657ead227902d6a95e855cf38a63bdd963fe99f80c7a5da27fc0b7f7fd35f789b110cac086707a498f03b692ec210a2a52f90114827bb8b53da058f443440db05a72ccaa68ac8cc022b067e122c563b5c277703fecac7bb876609ef5c502d5ab8701c613b7ee3ed20069681e0e98b54169e4a0e2f165ee1fc9e0e6213c0f6e752de084e9f90a492d1a5b42fe2b82ebd4f1f1228dfcaea591d4271c39fb4de4c13906eb11eb2da196165ac075f5d797301cb5f88e80023532a063f"
+
+// fakeRctTransaction builds a one input transaction paying outputs outputs with a single bulletproof of rctType,
+// followed by filler standing in for the ring signatures.
+func fakeRctTransaction(outputs int, rctType uint8) []byte {
+	w := serialization.NewWriter()
+	w.WriteVarint(2) // Version
+	w.WriteVarint(0) // Unlock time
+	w.WriteVarint(1)
+	in := serialization.TransactionInToKey{KeyOffsets: make([]uint64, 16)}
+	for i := range in.KeyOffsets {
+		in.KeyOffsets[i] = uint64(1000 * i)
+	}
+	w.WriteBlob(in.Serialize())
+	w.WriteVarint(uint64(outputs))
+	for i := 0; i < outputs; i++ {
+		out := serialization.TransactionOut{TaggedKey: serialization.TransactionOutToTaggedKey{ViewTag: uint8(i), Used: true}}
+		out.TaggedKey.PublicKey[0] = uint8(i)
+		w.WriteBlob(out.Serialize())
+	}
+	w.WriteVector(append([]byte{0x01}, make([]byte, 32)...))
+
+	w.WriteTag(rctType)
+	w.WriteVarint(30720000) // Fee
+	w.WriteBlob(make([]byte, (8+32)*outputs))
+	w.WriteVarint(1)
+	w.WriteBlob(make([]byte, 6*32))
+	rounds := 6
+	for 1<<uint(rounds-6) < outputs {
+		rounds++
+	}
+	for i := 0; i < 2; i++ {
+		w.WriteVarint(uint64(rounds))
+		w.WriteBlob(make([]byte, 32*rounds))
+	}
+	if rctType != serialization.RctTypeBulletproofPlus {
+		w.WriteBlob(make([]byte, 3*32))
+	}
+	w.WriteBlob(bytes.Repeat([]byte{0xaa}, 700))
+	return w.Bytes()
+}
+
+func TestTransactionWeight(t *testing.T) {
+	blob, _ := hex.DecodeString(tx793da061)
+	tx, err := ParseTransaction(blob)
+	if err != nil {
+		t.Fatal("Error parsing transaction 793da061,", err)
+	}
+	if weight := TransactionWeight(tx); weight != 1529 {
+		t.Errorf("Expected the daemon's weight of 1529, got %d", weight)
+	}
+
+	// No recorded transactions with more than two outputs were at hand, so the clawback is checked on made up ones
+	tests := []struct {
+		outputs  int
+		rctType  uint8
+		rounds   int
+		clawback uint64
+	}{
+		{16, serialization.RctTypeBulletproofPlus, 10, 3430},
+		{3, serialization.RctTypeBulletproof2, 8, 537},
+		{3, serialization.RctTypeCLSAG, 8, 537},
+	}
+	for _, tt := range tests {
+		blob := fakeRctTransaction(tt.outputs, tt.rctType)
+		tx, err := ParseTransaction(blob)
+		if err != nil {
+			t.Fatalf("%d outputs, type %d: error parsing, %v", tt.outputs, tt.rctType, err)
+		}
+		if !bytes.Equal(tx.Serialize(), blob) {
+			t.Fatalf("%d outputs, type %d: transaction didn't serialize back to its blob", tt.outputs, tt.rctType)
+		}
+		if tx.RctSignatures.TxnFee != 30720000 || len(tx.RctSignatures.BulletproofRounds) != 1 || tx.RctSignatures.BulletproofRounds[0] != tt.rounds {
+			t.Fatalf("%d outputs, type %d: got RingCT data %+v", tt.outputs, tt.rctType, tx.RctSignatures)
+		}
+		if weight := TransactionWeight(tx); weight != uint64(len(blob))+tt.clawback {
+			t.Errorf("%d outputs, type %d: got weight %d for a %d byte transaction, expected a clawback of %d", tt.outputs, tt.rctType, weight, len(blob), tt.clawback)
+		}
+	}
+
+	// Everything after the bulletproofs, the CLSAGs and pseudo outputs, is kept as is
+	for _, n := range []int{10, 60, 300, 500, len(blob) - 16*32 - 2*32 - 32 - 1} {
+		if _, err := ParseTransaction(blob[:n]); err == nil {
+			t.Errorf("Parsing a transaction cut to %d bytes should fail", n)
+		}
+	}
+}
+
+func TestBlockWeight(t *testing.T) {
+	// The genesis block's miner transaction, everything between the header and the transaction count
+	blockBlob, _ := hex.DecodeString(genesisBlock)
+	minerBlob := blockBlob[39 : len(blockBlob)-1]
+	minerTxn, err := ParseTransaction(minerBlob)
+	if err != nil {
+		t.Fatal("Error parsing the genesis miner transaction,", err)
+	}
+	if _, err = ParseTransaction(blockBlob[39:]); err != serialization.TrailingData {
+		t.Fatalf("Expected %v with the transaction count after the transaction, got %v", serialization.TrailingData, err)
+	}
+	b, _ := ParseBlock(blockBlob)
+	if !bytes.Equal(minerTxn.Serialize(), b.MinerTxn.Serialize()) {
+		t.Fatal("ParseTransaction and ParseBlock disagree about the genesis miner transaction")
+	}
+
+	txBlob, _ := hex.DecodeString(tx793da061)
+	tx, _ := ParseTransaction(txBlob)
+	weight := BlockWeight(minerTxn, []serialization.Transaction{tx, tx})
+	if expected := uint64(len(minerBlob)) + 2*TransactionWeight(tx); weight != expected {
+		t.Fatalf("Got block weight %d, expected %d", weight, expected)
+	}
+}